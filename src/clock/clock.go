@@ -0,0 +1,52 @@
+// Package clock tells business logic what time it is, so tests can decide
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock gives the current time in UTC
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// System is the wall clock
+var System Clock = systemClock{}
+
+// Fake is a clock that only moves when told to. It is safe for concurrent use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake creates a fake clock stopped at now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now.UTC()}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set moves the clock to t, backwards too
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t.UTC()
+}
+
+// Advance moves the clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"error"
+	"fmt"
+	"github.com/satori/go.uuid"
+	"models"
+)
+
+// cart open|add|park|resume|abandon|checkout|list|show, carts are kept between runs so a cart parked at one
+// register can be resumed at another
+func (c *CommandController) cart(args []string) error {
+	if c.Carts == nil {
+		return errors.NewError(errors.CartError, "carts aren't set up")
+	}
+	if len(args) == 0 {
+		c.usage()
+		return errUsage
+	}
+
+	switch args[0] {
+	case "open":
+		return c.openCart(args[1:])
+	case "add":
+		return c.addToCart(args[1:])
+	case "park", "resume", "abandon":
+		return c.changeCart(args[0], args[1:])
+	case "checkout":
+		return c.checkoutCart(args[1:])
+	case "list":
+		return c.listCarts(args[1:])
+	case "show":
+		return c.showCart(args[1:])
+	}
+	c.usage()
+	return errUsage
+}
+
+func (c *CommandController) openCart(args []string) error {
+	flags := c.newFlagSet("cart open")
+	userRef := flags.String("user", "", "customer or employee id or name")
+	register := flags.String("register", c.Register, "register the cart is opened at")
+	var lines lineFlags
+	flags.Var(&lines, "line", "item id or name and quantity as <item>:<qty>, repeat for more lines")
+	locationRef := flags.String("location", "", locationUsage)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	userId, _, err := c.findUser(*userRef)
+	if err != nil {
+		return err
+	}
+	lineItems, err := c.parseLines(lines)
+	if err != nil {
+		return err
+	}
+	locationId, err := c.findLocation(*locationRef)
+	if err != nil {
+		return err
+	}
+	cart, err := c.Carts.Open(userId, locationId, *register)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "Cart "+cart.Id.String()+" opened at "+c.locationName(locationId))
+	return c.addLines(cart.Id, lineItems)
+}
+
+func (c *CommandController) addToCart(args []string) error {
+	flags := c.newFlagSet("cart add")
+	cartRef := flags.String("id", "", "id of the cart")
+	var lines lineFlags
+	flags.Var(&lines, "line", "item id or name and quantity as <item>:<qty>, repeat for more lines")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return errors.NewError(errors.InvalidInputError, "at least one --line is needed")
+	}
+
+	cartId, err := parseCartId(*cartRef)
+	if err != nil {
+		return err
+	}
+	lineItems, err := c.parseLines(lines)
+	if err != nil {
+		return err
+	}
+	return c.addLines(cartId, lineItems)
+}
+
+// add the lines one by one, every line reserves its stock, and print what the cart holds
+func (c *CommandController) addLines(cartId uuid.UUID, lineItems []models.OrderLineItem) error {
+	for _, line := range lineItems {
+		if _, err := c.Carts.Add(cartId, *line.Item, line.Quantity); err != nil {
+			return err
+		}
+	}
+	if len(lineItems) == 0 {
+		return nil
+	}
+	cart, err := c.Carts.Cart(cartId)
+	if err != nil {
+		return err
+	}
+	c.printCartLines(cart)
+	return nil
+}
+
+// park, resume or abandon a cart
+func (c *CommandController) changeCart(action string, args []string) error {
+	flags := c.newFlagSet("cart " + action)
+	cartRef := flags.String("id", "", "id of the cart")
+	register := c.Register
+	if action == "resume" {
+		flags.StringVar(&register, "register", c.Register, "register the cart is resumed at")
+	}
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	cartId, err := parseCartId(*cartRef)
+	if err != nil {
+		return err
+	}
+
+	var cart models.Cart
+	switch action {
+	case "park":
+		cart, err = c.Carts.Park(cartId)
+	case "resume":
+		cart, err = c.Carts.Resume(cartId, register)
+	default:
+		cart, err = c.Carts.Abandon(cartId)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "Cart "+cart.Id.String()+" "+cart.Status)
+	return nil
+}
+
+func (c *CommandController) checkoutCart(args []string) error {
+	flags := c.newFlagSet("cart checkout")
+	cartRef := flags.String("id", "", "id of the cart")
+	var tenders lineFlags
+	flags.Var(&tenders, "tender", tenderUsage+", the order is left unpaid without")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	cartId, err := parseCartId(*cartRef)
+	if err != nil {
+		return err
+	}
+
+	cart, err := c.Carts.Cart(cartId)
+	if err != nil {
+		return err
+	}
+	discount, _ := c.fakeModels.FindUserDiscount(cart.UserId)
+	order, err := c.Carts.Checkout(cartId, discount)
+	if uuid.Equal(order.Id, uuid.Nil) {
+		return err
+	}
+	if err != nil {
+		// the order is placed all the same
+		fmt.Fprintln(c.errOut, err.Error())
+	}
+	printReceipt(c.out, order)
+	fmt.Fprintln(c.out, "Order "+order.Id.String()+" placed, amount to pay "+order.TotalAmount.StringFixedCash(5))
+	if len(tenders) == 0 {
+		return nil
+	}
+	return c.pay(order.Id, tenders)
+}
+
+func (c *CommandController) listCarts(args []string) error {
+	flags := c.newFlagSet("cart list")
+	status := flags.String("status", models.ParkedCartStatus, "open, parked, checked-out or abandoned, empty for all")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	for _, cart := range c.Carts.Carts(*status) {
+		name := cart.UserId.String()
+		if user, ok := c.fakeModels.FindUser(cart.UserId); ok {
+			name = user.Name
+		}
+		fmt.Fprintf(c.out, "%s\t%s\t%d lines\t%s\t%s %s\n", cart.Id, name, len(cart.Lines), cart.Status,
+			cart.Register, cart.Modified.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+func (c *CommandController) showCart(args []string) error {
+	flags := c.newFlagSet("cart show")
+	cartRef := flags.String("id", "", "id of the cart")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	cartId, err := parseCartId(*cartRef)
+	if err != nil {
+		return err
+	}
+	cart, err := c.Carts.Cart(cartId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, "Cart "+cart.Id.String()+" at "+c.locationName(cart.LocationId)+" "+cart.Status)
+	c.printCartLines(cart)
+	return nil
+}
+
+func (c *CommandController) printCartLines(cart models.Cart) {
+	for _, line := range cart.Lines {
+		fmt.Fprintf(c.out, "%s\t%s\t%d\n", line.Item.Name, line.Item.Id, line.Quantity)
+	}
+}
+
+func parseCartId(ref string) (uuid.UUID, error) {
+	cartId, err := uuid.FromString(ref)
+	if err != nil {
+		return uuid.Nil, errors.NewError(errors.InvalidInputError, "bad cart id "+ref)
+	}
+	return cartId, nil
+}
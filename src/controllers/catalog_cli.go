@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"error"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gopkg.in/dixonwille/wmenu.v4"
+	"models"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"usecases"
+)
+
+// catalog menu action handler
+func CatalogMenuAction(opts []wmenu.Opt) error {
+	for _, opt := range opts {
+		switch opt.ID {
+		case 0:
+			Cli.ListCatalog()
+		case 1:
+			Cli.AddItem()
+		case 2:
+			Cli.ChangeItemPrice()
+		case 3:
+			Cli.ToggleItemBlocked()
+		case 4:
+			Cli.RetireItem()
+		case 5:
+			Cli.ChangeItemReordering()
+		case 6:
+			Cli.AddSKU()
+		case 7:
+			Cli.AddProductGroup()
+		case 8:
+			return errors.NewError(errors.MenuDoneBreak, "Done with catalog")
+		default:
+			fmt.Println("Bad choice hombre...")
+		}
+	}
+	return nil
+}
+
+func (c *CliController) CatalogMenu() {
+	// loop catalog menu until user goes back
+	for {
+		menu := wmenu.NewMenu("Catalog maintenance > ")
+		menu.Action(CatalogMenuAction)
+		menu.Option("List items", nil, true, nil)
+		menu.Option("Add item", nil, false, nil)
+		menu.Option("Change item price", nil, false, nil)
+		menu.Option("Block/unblock item", nil, false, nil)
+		menu.Option("Retire item", nil, false, nil)
+		menu.Option("Change item reordering", nil, false, nil)
+		menu.Option("Add SKU", nil, false, nil)
+		menu.Option("Add product group", nil, false, nil)
+		menu.Option("Back", nil, false, nil)
+		err := menu.Run()
+		if err != nil {
+			e, ok := err.(errors.ApplicationError)
+			if ok && e.ErrorType == errors.ErrorMap[errors.MenuDoneBreak].ErrorType {
+				// we are done with this menu
+				return
+			} else if wmenu.IsInvalidErr(err) {
+				fmt.Println("Bad choice hombre... " + err.Error())
+			} else {
+				panic(fmt.Sprintf("error in catalog cli menu... %s", err))
+			}
+		}
+	}
+}
+
+func (c *CliController) ListCatalog() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Item\tStatus\tPrice\tDiscount\tTax class\tReorder point\tReorder qty\tSKU\tProduct group\tUpcoming prices")
+	now := c.clock.Now()
+	for _, item := range c.catalog.Items() {
+		var upcoming []string
+		for _, change := range item.PriceChanges {
+			if change.EffectiveFrom.After(now) {
+				upcoming = append(upcoming, change.Price.StringFixedCash(5)+" from "+change.EffectiveFrom.Format(time.RFC3339))
+			}
+		}
+		taxClass := item.TaxClass
+		if taxClass == "" {
+			taxClass = item.SKU.TaxClass
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d%%\t%s\t%s\t%s\t%s\t%s\t%s\n", item.Name, item.Status,
+			item.Price.StringFixedCash(5), item.DiscountPercentage, taxClass, item.ReorderPoint, item.ReorderQuantity,
+			item.SKU.Name, item.ProductGroup.Name, strings.Join(upcoming, ", "))
+	}
+	w.Flush()
+}
+
+func (c *CliController) AddItem() {
+	item := models.Item{
+		Name:        readLine("Item name: "),
+		Description: readLine("Description: "),
+	}
+	var err error
+	if item.Price, err = decimal.NewFromString(readLine("Price: ")); err != nil {
+		fmt.Println("Bad price hombre... " + err.Error())
+		return
+	}
+	if item.DiscountPercentage, err = readPercentage("Item discount %: "); err != nil {
+		fmt.Println("Bad discount hombre... " + err.Error())
+		return
+	}
+	item.TaxClass = readLine("Tax class (" + strings.Join(usecases.TaxClasses, ", ") + ", empty for the SKU's): ")
+	if item.ReorderPoint, item.ReorderQuantity, err = readReordering(); err != nil {
+		fmt.Println("Bad quantity hombre... " + err.Error())
+		return
+	}
+	if sku, ok := c.chooseSKU(); ok {
+		item.SKU = sku
+	}
+	if group, ok := c.chooseProductGroup(); ok {
+		item.ProductGroup = group
+	}
+
+	created, err := c.catalog.CreateItem(item)
+	if err != nil {
+		fmt.Println("Item not added... " + err.Error())
+		return
+	}
+	fmt.Printf("Added %s(Id %s)\n", created.Name, created.Id)
+}
+
+func (c *CliController) ChangeItemPrice() {
+	item, ok := c.chooseItem()
+	if !ok {
+		return
+	}
+	price, err := decimal.NewFromString(readLine("New price: "))
+	if err != nil {
+		fmt.Println("Bad price hombre... " + err.Error())
+		return
+	}
+	effective := c.clock.Now()
+	if from := readLine("Effective from (RFC3339, empty for now): "); from != "" {
+		if effective, err = time.Parse(time.RFC3339, from); err != nil {
+			fmt.Println("Bad time hombre... " + err.Error())
+			return
+		}
+	}
+
+	if err := c.catalog.ChangePrice(item.Id, price, effective); err != nil {
+		fmt.Println("Price not changed... " + err.Error())
+		return
+	}
+	fmt.Printf("%s costs %s from %s\n", item.Name, price.StringFixedCash(5), effective.Format(time.RFC3339))
+}
+
+func (c *CliController) ChangeItemReordering() {
+	item, ok := c.chooseItem()
+	if !ok {
+		return
+	}
+	var err error
+	if item.ReorderPoint, item.ReorderQuantity, err = readReordering(); err != nil {
+		fmt.Println("Bad quantity hombre... " + err.Error())
+		return
+	}
+
+	if _, err := c.catalog.UpdateItem(item); err != nil {
+		fmt.Println("Reordering not changed... " + err.Error())
+		return
+	}
+	fmt.Printf("%s is reordered below %s, %s at least\n", item.Name, item.ReorderPoint, item.ReorderQuantity)
+}
+
+func (c *CliController) ToggleItemBlocked() {
+	item, ok := c.chooseItem()
+	if !ok {
+		return
+	}
+	status := models.BlockedItemStatus
+	if item.Status == models.BlockedItemStatus {
+		status = models.AvailableItemStatus
+	}
+
+	if err := c.catalog.SetItemStatus(item.Id, status); err != nil {
+		fmt.Println("Status not changed... " + err.Error())
+		return
+	}
+	fmt.Printf("%s is now %s\n", item.Name, status)
+}
+
+func (c *CliController) RetireItem() {
+	item, ok := c.chooseItem()
+	if !ok {
+		return
+	}
+	if err := c.catalog.RetireItem(item.Id); err != nil {
+		fmt.Println("Item not retired... " + err.Error())
+		return
+	}
+	fmt.Printf("%s is retired\n", item.Name)
+}
+
+func (c *CliController) AddSKU() {
+	sku := models.SKU{
+		Name:        readLine("SKU name: "),
+		Description: readLine("Description: "),
+	}
+	var err error
+	if sku.DiscountPercentage, err = readPercentage("SKU discount %: "); err != nil {
+		fmt.Println("Bad discount hombre... " + err.Error())
+		return
+	}
+	sku.TaxClass = readLine("Tax class (" + strings.Join(usecases.TaxClasses, ", ") + ", empty for standard): ")
+
+	created, err := c.catalog.CreateSKU(sku)
+	if err != nil {
+		fmt.Println("SKU not added... " + err.Error())
+		return
+	}
+	fmt.Printf("Added SKU %s(Id %s)\n", created.Name, created.SkuId)
+}
+
+func (c *CliController) AddProductGroup() {
+	group := models.ProductGroup{
+		Name:        readLine("Product group name: "),
+		Description: readLine("Description: "),
+	}
+	var err error
+	if group.DiscountPercentage, err = readPercentage("Product group discount %: "); err != nil {
+		fmt.Println("Bad discount hombre... " + err.Error())
+		return
+	}
+
+	created, err := c.catalog.CreateProductGroup(group)
+	if err != nil {
+		fmt.Println("Product group not added... " + err.Error())
+		return
+	}
+	fmt.Printf("Added product group %s(Id %s)\n", created.Name, created.ProductGroupId)
+}
+
+func (c *CliController) chooseItem() (models.Item, bool) {
+	item, err := c.catalog.FindItem(readLine("Item name or id: "))
+	if err != nil {
+		fmt.Println(err.Error())
+		return item, false
+	}
+	return item, true
+}
+
+func (c *CliController) chooseSKU() (models.SKU, bool) {
+	ref := readLine("SKU name or id (empty for none): ")
+	if ref == "" {
+		return models.SKU{}, false
+	}
+	for _, sku := range c.catalog.SKUs() {
+		if strings.EqualFold(sku.Name, ref) || sku.SkuId.String() == ref {
+			return sku, true
+		}
+	}
+	fmt.Println("No such SKU, item gets none")
+	return models.SKU{}, false
+}
+
+func (c *CliController) chooseProductGroup() (models.ProductGroup, bool) {
+	ref := readLine("Product group name or id (empty for none): ")
+	if ref == "" {
+		return models.ProductGroup{}, false
+	}
+	for _, group := range c.catalog.ProductGroups() {
+		if strings.EqualFold(group.Name, ref) || group.ProductGroupId.String() == ref {
+			return group, true
+		}
+	}
+	fmt.Println("No such product group, item gets none")
+	return models.ProductGroup{}, false
+}
+
+// reorder point and quantity, empty answers leave the item out of reordering
+func readReordering() (decimal.Decimal, decimal.Decimal, error) {
+	point, err := readQuantity("Reorder point (empty for none): ")
+	if err != nil {
+		return point, decimal.Zero, err
+	}
+	quantity, err := readQuantity("Reorder quantity: ")
+	return point, quantity, err
+}
+
+func readQuantity(prompt string) (decimal.Decimal, error) {
+	line := readLine(prompt)
+	if line == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(line)
+}
+
+func readPercentage(prompt string) (int, error) {
+	line := readLine(prompt)
+	if line == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(line)
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"bufio"
+	"clock"
 	"error"
 	"fmt"
 	"github.com/satori/go.uuid"
@@ -12,16 +13,41 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"time"
 	"usecases"
 )
 
 type CliController struct {
+	// Location is where the menus sell and restock, the inventory status shows it alone
+	Location uuid.UUID
+	// Register names this register in the carts it opens and resumes
+	Register string
+
+	// tells the menus what time it is, reports run up to now
+	clock      clock.Clock
+	repo       *usecases.InventoryUsecaseRepository
+	catalog    *usecases.CatalogUsecaseRepository
+	carts      *usecases.CartUsecaseRepository
+	fakeModels *models.Mocks
+	// the cart the purchase menu works on
+	cartId uuid.UUID
+}
+
+// NewCliController creates the interactive menus on top of the inventory, catalog and cart usecases, telling
+// the time by clk
+func NewCliController(repo *usecases.InventoryUsecaseRepository, catalog *usecases.CatalogUsecaseRepository,
+	carts *usecases.CartUsecaseRepository, fakeModels *models.Mocks, clk clock.Clock) *CliController {
+	return &CliController{
+		clock:      clk,
+		Location:   models.DefaultLocationId,
+		repo:       repo,
+		catalog:    catalog,
+		carts:      carts,
+		fakeModels: fakeModels,
+	}
 }
 
-var uRepo = new(usecases.InventoryUsecaseRepository)
-var Cli = new(CliController)
-var fakeModels = new(models.Mocks)
+// Cli is the controller driving the menus, main sets it up before the first menu runs
+var Cli *CliController
 
 // main menu action handler
 func MainMenuAction(opts []wmenu.Opt) error {
@@ -30,14 +56,20 @@ func MainMenuAction(opts []wmenu.Opt) error {
 		case 0:
 			Cli.ReplenishStock()
 		case 1:
-			// clear stale line items
-			fakeModels.ClearMockModelCache()
 			Cli.UserMenu()
 		case 2:
-			Cli.SalesSummary()
+			Cli.ResumeCart()
 		case 3:
-			Cli.InventoryStatus()
+			Cli.PayUnpaidOrder()
 		case 4:
+			Cli.ReturnItems()
+		case 5:
+			Cli.SalesSummary()
+		case 6:
+			Cli.InventoryStatus()
+		case 7:
+			Cli.CatalogMenu()
+		case 8:
 			fmt.Println("Bye!")
 			os.Exit(0)
 		default:
@@ -52,12 +84,12 @@ func (c *CliController) UserMenu() {
 	for {
 		menu := wmenu.NewMenu("Choose a user who is placing order > ")
 		menu.Action(UserMenuAction)
-		for _, customer := range fakeModels.Customers {
+		for _, customer := range c.fakeModels.Customers {
 			msg := fmt.Sprintf("%s Discount: %d%%", customer.Name, customer.DiscountPercentage)
 			menu.Option(msg, customer.Id, false, nil)
 		}
 
-		for _, employee := range fakeModels.Employees {
+		for _, employee := range c.fakeModels.Employees {
 			msg := fmt.Sprintf("%s (Employee) Discount: %d%%", employee.Name, employee.DiscountPercentage)
 			menu.Option(msg, employee.Id, false, nil)
 		}
@@ -79,25 +111,13 @@ func (c *CliController) UserMenu() {
 
 func UserMenuAction(opts []wmenu.Opt) error {
 	for _, opt := range opts {
-		optId := opt.Value.(uuid.UUID)
-
-		if strings.Contains(opt.Text, "Employee") {
-			for _, e := range fakeModels.Employees {
-				if uuid.Equal(e.Id, optId) {
-					fakeModels.PurchaseUserId = optId
-					fakeModels.PurchaseUserDiscount = e.DiscountPercentage
-					break
-				}
-			}
-		} else {
-			for _, c := range fakeModels.Customers {
-				if uuid.Equal(c.Id, optId) {
-					fakeModels.PurchaseUserId = c.Id
-					fakeModels.PurchaseUserDiscount = c.DiscountPercentage
-					break
-				}
-			}
+		// a cart of their own, customer or employee, the discount is looked up at checkout
+		cart, err := Cli.carts.Open(opt.Value.(uuid.UUID), Cli.Location, Cli.Register)
+		if err != nil {
+			fmt.Println("Can't start a purchase... " + err.Error())
+			return errors.NewError(errors.PurchaseDoneBreak, "Done with purchase order")
 		}
+		Cli.cartId = cart.Id
 	}
 
 	// now go to purchase menu
@@ -109,57 +129,108 @@ func UserMenuAction(opts []wmenu.Opt) error {
 // purchase menu action handler
 func PurchaseMenuAction(opts []wmenu.Opt) error {
 	for _, opt := range opts {
-		optId := opt.Value.(uuid.UUID)
-		if uuid.Equal(optId, uuid.Nil) {
+		switch opt.ID {
+		case 0:
 			return errors.NewError(errors.PurchaseDoneBreak, "Done with purchase order")
+		case 1:
+			Cli.ParkCart()
+			return errors.NewError(errors.MenuDoneBreak, "Cart parked")
 		}
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Print("Enter quantity: ")
-		qtyS, _ := reader.ReadString('\n')
-		qtyS = strings.TrimSpace(qtyS)
-		qty, _ := strconv.ParseInt(qtyS, 10, 64)
+		optId := opt.Value.(uuid.UUID)
+		qty, _ := strconv.ParseInt(readLine("Enter quantity: "), 10, 64)
 
 		Cli.AddToPurchaseOrder(optId, qty)
 	}
 	return nil
 }
 
+// prompt and read one trimmed line from stdin
+func readLine(prompt string) string {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print(prompt)
+	line, _ := reader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
 func (c *CliController) AddToPurchaseOrder(itemId uuid.UUID, qty int64) {
-	for _, item := range fakeModels.Items {
-		if uuid.Equal(item.Id, itemId) {
-			lineItem := new(models.OrderLineItem)
-			lineItem.Item = &item
-			lineItem.Quantity = qty
-			fakeModels.LineItems = append(fakeModels.LineItems, *lineItem)
-			break
+	item, err := c.catalog.GetItem(itemId)
+	if err != nil {
+		fmt.Println("Can't add item... " + err.Error())
+		return
+	}
+	// the cart holds the stock until the order is placed
+	if _, err := c.carts.Add(c.cartId, item, qty); err != nil {
+		fmt.Println("Can't add item... " + err.Error())
+	}
+}
+
+// set the cart aside, its stock stays reserved for a while
+func (c *CliController) ParkCart() {
+	cart, err := c.carts.Park(c.cartId)
+	if err != nil {
+		fmt.Println("Can't park cart... " + err.Error())
+		return
+	}
+	fmt.Println("Cart " + cart.Id.String() + " parked, resume it from any register")
+}
+
+// pick a parked cart, from this register or another one, and carry on with its purchase
+func (c *CliController) ResumeCart() {
+	parked := c.carts.Carts(models.ParkedCartStatus)
+	if len(parked) == 0 {
+		fmt.Println("No parked carts")
+		return
+	}
+	for _, cart := range parked {
+		name := cart.UserId.String()
+		if user, ok := c.fakeModels.FindUser(cart.UserId); ok {
+			name = user.Name
 		}
+		fmt.Printf("%s\t%s, %d lines, parked at %s %s\n", cart.Id, name, len(cart.Lines), cart.Register,
+			cart.Modified.Format("15:04"))
+	}
+	cartId, err := uuid.FromString(readLine("Cart id: "))
+	if err != nil {
+		fmt.Println("Bad cart id hombre... " + err.Error())
+		return
+	}
+	cart, err := c.carts.Resume(cartId, c.Register)
+	if err != nil {
+		fmt.Println("Can't resume cart... " + err.Error())
+		return
 	}
+	c.cartId = cart.Id
+	for _, line := range cart.Lines {
+		fmt.Printf("%s x %d\n", line.Item.Name, line.Quantity)
+	}
+	c.PurchaseMenu()
 }
 
 func (c *CliController) InventoryStatus() {
-	inventory := uRepo.InventorySummary(time.Now().UTC())
-	printInventory(inventory)
+	c.printInventory(c.repo.StockLevels(c.Location))
 }
 
 func (c *CliController) SalesSummary() {
-	inventory, total := uRepo.SaleSummary(time.Now().AddDate(0, 0, -1).UTC())
+	report, err := c.repo.SalesReportFor(usecases.DayPeriod, c.clock.Now())
+	if err != nil {
+		fmt.Println("Can't report sales... " + err.Error())
+		return
+	}
 
-	fmt.Println("*** Itemwise sales today so far ***")
-	printInventory(inventory)
-	fmt.Println("*** Total sales today so far ***")
-	fmt.Println(total.StringFixedCash(5))
+	fmt.Println("*** Sales of business day " + c.repo.Calendar.Day(report.From).Format("2006-01-02") + " so far ***")
+	printSalesReport(os.Stdout, report, c.fakeModels)
 }
 
-func printInventory(inventory map[uuid.UUID]decimal.Decimal) {
+// on hand, then what carts hold and what is left to sell
+func (c *CliController) printInventory(inventory map[uuid.UUID]usecases.StockLevel) {
 	for k, v := range inventory {
-		var item models.Item
-		for i := 0; i < len(fakeModels.Items); i++ {
-			if uuid.Equal(k, fakeModels.Items[i].Id) {
-				item = fakeModels.Items[i]
-				fmt.Printf("%s(Id %s, SKU: %s, Price %s) : %s\n",
-					item.Name, item.Id, item.SkuId, item.Price.StringFixedCash(5), v.String())
-			}
+		item, err := c.catalog.GetItem(k)
+		if err != nil {
+			fmt.Printf("Unknown item(Id %s) : %s, reserved %s, available %s\n", k, v.OnHand, v.Reserved, v.Available)
+			continue
 		}
+		fmt.Printf("%s(Id %s, SKU: %s, Price %s) : %s, reserved %s, available %s\n",
+			item.Name, item.Id, item.SkuId, item.Price.StringFixedCash(5), v.OnHand, v.Reserved, v.Available)
 	}
 }
 
@@ -169,7 +240,8 @@ func (c *CliController) PurchaseMenu() {
 		menu := wmenu.NewMenu("Choose items to purchase > ")
 		menu.Action(PurchaseMenuAction)
 		menu.Option("Done with purchase", uuid.Nil, false, nil)
-		for _, item := range fakeModels.Items {
+		menu.Option("Park cart", uuid.Nil, false, nil)
+		for _, item := range c.catalog.AvailableItems() {
 			menu.Option(item.Name, item.Id, false, nil)
 		}
 		err := menu.Run()
@@ -179,6 +251,9 @@ func (c *CliController) PurchaseMenu() {
 				c.PlaceOrder()
 				// we are done with this menu
 				return
+			} else if ok && e.ErrorType == errors.ErrorMap[errors.MenuDoneBreak].ErrorType {
+				// parked, back to the main menu
+				return
 			} else if wmenu.IsInvalidErr(err) {
 				fmt.Println("Bad choice hombre... " + err.Error())
 			} else {
@@ -189,13 +264,109 @@ func (c *CliController) PurchaseMenu() {
 }
 
 func (c *CliController) PlaceOrder() {
-	amt, err := uRepo.Purchase(&fakeModels.LineItems, fakeModels.PurchaseUserId, fakeModels.PurchaseUserDiscount)
-
+	cart, err := c.carts.Cart(c.cartId)
 	if err != nil {
+		fmt.Println("Purchase failed! Reason: " + err.Error())
+		return
+	}
+	discount, _ := c.fakeModels.FindUserDiscount(cart.UserId)
+	order, err := c.carts.Checkout(cart.Id, discount)
+
+	if uuid.Equal(order.Id, uuid.Nil) {
+		// the cart is set aside with what it holds, to be resumed and checked out again later
 		fmt.Println("Purchase failed!, retry again later. Reason: " + err.Error())
-	} else {
-		fmt.Println("Thanks for placing order! You need to pay " + amt.StringFixedCash(5))
+		c.ParkCart()
+		return
+	}
+	if err != nil {
+		// the order is placed all the same
+		fmt.Println("Order placed, but " + err.Error())
+	}
+	printReceipt(os.Stdout, order)
+	fmt.Println("Thanks for placing order " + order.Id.String() + "! You need to pay " +
+		order.TotalAmount.StringFixedCash(5))
+	c.TakePayment(order.Id)
+}
+
+// take tenders until the order is paid in full, or the customer pays the rest later
+func (c *CliController) TakePayment(orderId uuid.UUID) {
+	for {
+		tenderType := readLine("Tender cash, card or store-credit (empty to pay the rest later): ")
+		if tenderType == "" {
+			return
+		}
+		tendered, err := decimal.NewFromString(readLine("Amount: "))
+		if err != nil {
+			fmt.Println("Bad amount hombre... " + err.Error())
+			continue
+		}
+		payment, err := c.repo.Pay(orderId, models.Tender{Type: tenderType, Tendered: tendered})
+		if err != nil {
+			fmt.Println("Can't take payment... " + err.Error())
+			continue
+		}
+		printPayment(os.Stdout, payment)
+		if payment.Status != models.PendingOrderStatus {
+			return
+		}
+	}
+}
+
+// pick an order that isn't paid in full and take the rest of its payment
+func (c *CliController) PayUnpaidOrder() {
+	unpaid := c.repo.UnpaidOrders()
+	if len(unpaid) == 0 {
+		fmt.Println("Every order is paid")
+		return
+	}
+	for _, payment := range unpaid {
+		name := payment.UserId.String()
+		if user, ok := c.fakeModels.FindUser(payment.UserId); ok {
+			name = user.Name
+		}
+		fmt.Printf("%s\t%s, %s left to pay\n", payment.OrderId, name, payment.Outstanding().StringFixed(2))
+	}
+	orderId, err := uuid.FromString(readLine("Order id: "))
+	if err != nil {
+		fmt.Println("Bad order id hombre... " + err.Error())
+		return
+	}
+	c.TakePayment(orderId)
+}
+
+func (c *CliController) ReturnItems() {
+	orderId, err := uuid.FromString(readLine("Order id: "))
+	if err != nil {
+		fmt.Println("Bad order id hombre... " + err.Error())
+		return
+	}
+
+	var lineItems []models.OrderLineItem
+	for {
+		ref := readLine("Item name or id (empty when done): ")
+		if ref == "" {
+			break
+		}
+		item, err := c.catalog.FindItem(ref)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		qty, err := strconv.ParseInt(readLine("Quantity: "), 10, 64)
+		if err != nil {
+			fmt.Println("Bad quantity hombre... " + err.Error())
+			continue
+		}
+		lineItems = append(lineItems, models.OrderLineItem{Item: &item, Quantity: qty})
 	}
+
+	order, err := c.repo.Return(orderId, &lineItems)
+	if err != nil {
+		fmt.Println("Return failed! Reason: " + err.Error())
+		return
+	}
+	printReceipt(os.Stdout, order)
+	fmt.Println("Items are back in stock. Refund " + order.TotalAmount.StringFixedCash(5))
 }
 
 // main menu Cli
@@ -204,22 +375,47 @@ func (c *CliController) MainMenu() *wmenu.Menu {
 	menu.Action(MainMenuAction)
 	menu.Option("Replenish stock again", nil, true, nil)
 	menu.Option("Purchase", nil, false, nil)
+	menu.Option("Resume parked cart", nil, false, nil)
+	menu.Option("Pay unpaid order", nil, false, nil)
+	menu.Option("Return items", nil, false, nil)
 	menu.Option("Today's sales summary", nil, false, nil)
 	menu.Option("Inventory status", nil, false, nil)
+	menu.Option("Catalog maintenance", nil, false, nil)
 	menu.Option("Exit", nil, false, nil)
 
 	return menu
 }
 
-// create stock with dummy items
+// restock what the suggested purchase order asks for, items without a reorder point get dummy quantities
 func (c *CliController) ReplenishStock() {
-	fakeModels.InitInventory()
-	fakeModels.InitUsers()
+	c.fakeModels.InitUsers()
 
-	for _, item := range fakeModels.Items {
+	items := c.catalog.Items()
+	suggestions, err := c.repo.SuggestReorders(items, usecases.DefaultReorderWindow, usecases.DefaultReorderCover)
+	if err != nil {
+		fmt.Println("Replenish failed, try again later... " + err.Error())
+		return
+	}
+	quantities := make(map[uuid.UUID]decimal.Decimal, len(items))
+	for _, suggestion := range suggestions {
+		quantities[suggestion.Item.Id] = suggestion.Quantity
+	}
+	for _, item := range items {
+		if item.Status == models.RetiredItemStatus {
+			continue
+		}
+		if item.ReorderPoint.Sign() <= 0 {
+			quantities[item.Id] = decimal.New(rand.Int63n(10), 0)
+		}
+	}
+
+	for _, item := range items {
+		quantity, ok := quantities[item.Id]
+		if !ok {
+			continue
+		}
 		// add to inventory
-		rand.NewSource(time.Now().Unix())
-		ok, err := uRepo.Replenish(item, decimal.New(rand.Int63n(10), 0))
+		ok, err := c.repo.Replenish(c.Location, item, quantity)
 		if !ok || err != nil {
 			fmt.Println("Replenish failed, try again later...")
 			break
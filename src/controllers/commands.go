@@ -0,0 +1,672 @@
+package controllers
+
+import (
+	"clock"
+	"error"
+	"flag"
+	"fmt"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"io"
+	"models"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"usecases"
+)
+
+// Exit codes of the subcommands. Every kind of application error has a code of its own, so scripts can
+// tell failures apart. Errors that aren't application errors exit with ExitFailure.
+const (
+	ExitOk           = 0
+	ExitFailure      = 1
+	ExitUsage        = 2
+	ExitUnknown      = 9
+	ExitReplenish    = 10
+	ExitOrder        = 11
+	ExitNotFound     = 12
+	ExitInvalidInput = 13
+	ExitCatalog      = 14
+	ExitReturn       = 15
+	ExitAdjustment   = 16
+	ExitStockTake    = 17
+	ExitSupplier     = 18
+	ExitLocation     = 19
+	ExitTransfer     = 20
+	ExitReservation  = 21
+	ExitCart         = 22
+	ExitPayment      = 23
+	// the menu's breaks never leave it, they have codes all the same
+	ExitPurchaseDone = 30
+	ExitMenuDone     = 31
+)
+
+// exit code per ApplicationError.ErrorType
+var exitCodes = map[int]int{
+	errors.ErrorMap[errors.UnknownError].ErrorType:      ExitUnknown,
+	errors.ErrorMap[errors.ReplenishError].ErrorType:    ExitReplenish,
+	errors.ErrorMap[errors.OrderError].ErrorType:        ExitOrder,
+	errors.ErrorMap[errors.NotFoundError].ErrorType:     ExitNotFound,
+	errors.ErrorMap[errors.InvalidInputError].ErrorType: ExitInvalidInput,
+	errors.ErrorMap[errors.CatalogError].ErrorType:      ExitCatalog,
+	errors.ErrorMap[errors.ReturnError].ErrorType:       ExitReturn,
+	errors.ErrorMap[errors.AdjustmentError].ErrorType:   ExitAdjustment,
+	errors.ErrorMap[errors.StockTakeError].ErrorType:    ExitStockTake,
+	errors.ErrorMap[errors.SupplierError].ErrorType:     ExitSupplier,
+	errors.ErrorMap[errors.LocationError].ErrorType:     ExitLocation,
+	errors.ErrorMap[errors.TransferError].ErrorType:     ExitTransfer,
+	errors.ErrorMap[errors.ReservationError].ErrorType:  ExitReservation,
+	errors.ErrorMap[errors.CartError].ErrorType:         ExitCart,
+	errors.ErrorMap[errors.PaymentError].ErrorType:      ExitPayment,
+	errors.ErrorMap[errors.PurchaseDoneBreak].ErrorType: ExitPurchaseDone,
+	errors.ErrorMap[errors.MenuDoneBreak].ErrorType:     ExitMenuDone,
+}
+
+// CommandController runs one non-interactive subcommand, for scripting and cron
+type CommandController struct {
+	// StockTake runs the count subcommand, it is refused when nil
+	StockTake *usecases.StockTakeUsecaseRepository
+	// Suppliers runs the supplier and po subcommands, they are refused when nil
+	Suppliers *usecases.SupplierUsecaseRepository
+	// Transfers runs the transfer subcommand, it is refused when nil
+	Transfers *usecases.TransferUsecaseRepository
+	// Carts runs the cart subcommand, it is refused when nil
+	Carts *usecases.CartUsecaseRepository
+	// Register names this register in the carts it opens and resumes
+	Register string
+
+	// tells the subcommands what time it is, reports run up to now
+	clock      clock.Clock
+	repo       *usecases.InventoryUsecaseRepository
+	catalog    *usecases.CatalogUsecaseRepository
+	fakeModels *models.Mocks
+	out        io.Writer
+	errOut     io.Writer
+}
+
+// NewCommandController creates the subcommands on top of the inventory usecases, telling the time by clk
+func NewCommandController(repo *usecases.InventoryUsecaseRepository, catalog *usecases.CatalogUsecaseRepository,
+	fakeModels *models.Mocks, clk clock.Clock, out io.Writer, errOut io.Writer) *CommandController {
+	return &CommandController{
+		clock:      clk,
+		repo:       repo,
+		catalog:    catalog,
+		fakeModels: fakeModels,
+		out:        out,
+		errOut:     errOut,
+	}
+}
+
+// Run a subcommand, eg. ["replenish", "--item", "Dora", "--qty", "10"], and return its exit code
+func (c *CommandController) Run(args []string) int {
+	if len(args) == 0 {
+		c.usage()
+		return ExitUsage
+	}
+
+	var err error
+	switch args[0] {
+	case "replenish":
+		err = c.replenish(args[1:])
+	case "purchase":
+		err = c.purchase(args[1:])
+	case "return":
+		err = c.returnItems(args[1:])
+	case "sales":
+		err = c.sales(args[1:])
+	case "inventory":
+		err = c.inventory(args[1:])
+	case "movements":
+		err = c.movements(args[1:])
+	case "adjust":
+		err = c.adjust(args[1:])
+	case "shrinkage":
+		err = c.shrinkage(args[1:])
+	case "count":
+		err = c.count(args[1:])
+	case "reorder":
+		err = c.reorder(args[1:])
+	case "supplier":
+		err = c.supplier(args[1:])
+	case "po":
+		err = c.supplierOrder(args[1:])
+	case "location":
+		err = c.location(args[1:])
+	case "transfer":
+		err = c.transfer(args[1:])
+	case "cart":
+		err = c.cart(args[1:])
+	case "payment":
+		err = c.payment(args[1:])
+	default:
+		c.usage()
+		return ExitUsage
+	}
+
+	if err != nil && err != errUsage {
+		fmt.Fprintln(c.errOut, err.Error())
+	}
+	return ExitCode(err)
+}
+
+// ExitCode maps an error returned by a usecase to a process exit code
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOk
+	}
+	if err == errUsage {
+		return ExitUsage
+	}
+	e, ok := err.(errors.ApplicationError)
+	if !ok {
+		return ExitFailure
+	}
+	if code, ok := exitCodes[e.ErrorType]; ok {
+		return code
+	}
+	return ExitUnknown
+}
+
+// flag parsing already printed what went wrong
+var errUsage = errors.NewError(errors.InvalidInputError, "see usage above")
+
+func (c *CommandController) usage() {
+	fmt.Fprintln(c.errOut, `Usage: toy-store [-data dir] <command> [flags]
+
+Commands:
+  replenish --item <id|name> --qty <n> [--location <id|name>]
+                                                       add stock for an item
+  purchase  --user <id|name> --line <item>:<qty> ... [--tender <type>:<amount> ...] [--location <id|name>]
+                                                       place an order, and pay for it with the tenders
+  return    --order <id> --line <item>:<qty> ...       return items of an order and refund them
+  sales     [--since 24h | --period day|week|month [--date 2006-01-02]]
+                                                       sales in the given window or business period
+  inventory [--at <RFC3339 time>] [--location <id|name>]
+                                                       stock levels, now and summed across locations
+                                                       by default. Now shows what carts reserved and
+                                                       what is available to sell
+  movements [--period day|week|month] [--date 2006-01-02]
+                                                       stock moved in (+) and out (-) per order type
+  adjust    --item <id|name> --qty <n> --reason <reason> --employee <id|name> [--location <id|name>]
+                                                       correct or write off stock, reasons are damage,
+                                                       theft, count-correction and sample
+  shrinkage [--period day|week|month] [--date 2006-01-02]
+                                                       stock lost per item and reason, a week by default
+  count     start --employee <id|name> [--location <id|name>]
+                                                       start a stock take
+  count     record [--id <count>] --line <item>:<qty> ...
+                                                       record what is on the shelves, a recount replaces
+  count     show [--id <count>]                        variances of a count, or the open counts
+  count     approve [--id <count>] --employee <id|name>
+                                                       post the variances as count corrections
+  count     cancel [--id <count>]                      drop a count without touching stock
+  reorder   [--window 336h] [--cover 168h]              suggested purchase order from the sales of the
+                                                       window, enough to stay above reorder points for cover
+  supplier  add --name <name> [--contact <contact>]     add a supplier
+  supplier  list                                       suppliers and their ids
+  po        draft --supplier <id|name> --employee <id|name> --line <item>:<qty> ... [--location <id|name>]
+                                                       draft a purchase order to a supplier
+  po        send --id <order>                          mark a draft as sent to the supplier
+  po        receive --id <order> --employee <id|name> --line <item>:<qty> ...
+                                                       book a delivery, stock is credited as received
+  po        cancel --id <order>                        close an order that isn't fully received
+  po        show [--id <order>]                        lines of an order, or the orders still open
+  po        outstanding                                what each supplier still has to deliver
+  location  add --name <name> --kind store|warehouse|back-room
+                                                       add a place to keep stock
+  location  list                                       locations and their ids
+  transfer  ship --from <id|name> --to <id|name> --employee <id|name> --line <item>:<qty> ...
+                                                       send stock to another location, it is in transit
+                                                       until received
+  transfer  receive --id <transfer> --employee <id|name> [--line <item>:<qty> ...]
+                                                       book what arrived, everything shipped by default,
+                                                       differences are posted as transfer-discrepancy
+  transfer  show [--id <transfer>]                     lines of a transfer, or the ones in transit
+  transfer  in-transit                                 stock in transit by route
+  cart      open --user <id|name> [--line <item>:<qty> ...] [--register <name>] [--location <id|name>]
+                                                       start a cart, its stock is reserved for it
+  cart      add --id <cart> --line <item>:<qty> ...    put more in an open cart
+  cart      park|abandon --id <cart>                   set a cart aside, or drop it and free its stock
+  cart      resume --id <cart> [--register <name>]     carry on with a parked cart at this register
+  cart      checkout --id <cart> [--tender <type>:<amount> ...]
+                                                       place the order for an open cart
+  cart      list [--status parked]                     carts by status, the parked ones by default
+  cart      show --id <cart>                           lines of a cart
+  payment   take --order <order> --tender <type>:<amount> ...
+                                                       pay for an order in cash, by card or store credit,
+                                                       split over tenders. Cash over what is owed is
+                                                       given back as change
+  payment   show --order <order>                       tenders taken for an order and what is left to pay
+  payment   unpaid                                     orders not paid in full
+
+Stock is kept at the main store unless --location says otherwise.
+
+Run without a command for the interactive menu.`)
+}
+
+func (c *CommandController) newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.errOut)
+	return flags
+}
+
+func (c *CommandController) parse(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(c.errOut, "unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func (c *CommandController) replenish(args []string) error {
+	flags := c.newFlagSet("replenish")
+	itemRef := flags.String("item", "", "item id or name")
+	qty := flags.Int64("qty", 0, "quantity to add")
+	locationRef := flags.String("location", "", locationUsage)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if *qty <= 0 {
+		return errors.NewError(errors.InvalidInputError, "--qty must be positive")
+	}
+
+	item, err := c.catalog.FindItem(*itemRef)
+	if err != nil {
+		return err
+	}
+	locationId, err := c.findLocation(*locationRef)
+	if err != nil {
+		return err
+	}
+	if _, err := c.repo.Replenish(locationId, item, decimal.New(*qty, 0)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Replenished %s with %d\n", item.Name, *qty)
+	return nil
+}
+
+func (c *CommandController) adjust(args []string) error {
+	flags := c.newFlagSet("adjust")
+	itemRef := flags.String("item", "", "item id or name")
+	qty := flags.Int64("qty", 0, "quantity to add, negative to take out")
+	reason := flags.String("reason", "", strings.Join(models.AdjustmentReasons, ", "))
+	employeeRef := flags.String("employee", "", "id or name of the employee making the adjustment")
+	locationRef := flags.String("location", "", locationUsage)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	item, err := c.catalog.FindItem(*itemRef)
+	if err != nil {
+		return err
+	}
+	employeeId, err := c.findEmployee(*employeeRef)
+	if err != nil {
+		return err
+	}
+	locationId, err := c.findLocation(*locationRef)
+	if err != nil {
+		return err
+	}
+	order, err := c.repo.Adjust(locationId, item, decimal.New(*qty, 0), *reason, employeeId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Adjusted %s by %d for %s, order %s\n", item.Name, *qty, *reason, order.Id)
+	return nil
+}
+
+// repeatable --line item:qty
+type lineFlags []string
+
+func (l *lineFlags) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *lineFlags) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func (c *CommandController) purchase(args []string) error {
+	flags := c.newFlagSet("purchase")
+	userRef := flags.String("user", "", "customer or employee id or name")
+	var lines lineFlags
+	flags.Var(&lines, "line", "item id or name and quantity as <item>:<qty>, repeat for more lines")
+	var tenders lineFlags
+	flags.Var(&tenders, "tender", tenderUsage+", the order is left unpaid without")
+	locationRef := flags.String("location", "", locationUsage)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return errors.NewError(errors.InvalidInputError, "at least one --line is needed")
+	}
+
+	userId, discount, err := c.findUser(*userRef)
+	if err != nil {
+		return err
+	}
+
+	lineItems, err := c.parseLines(lines)
+	if err != nil {
+		return err
+	}
+	locationId, err := c.findLocation(*locationRef)
+	if err != nil {
+		return err
+	}
+
+	order, err := c.repo.PurchaseOrder(locationId, &lineItems, userId, discount)
+	if err != nil {
+		return err
+	}
+
+	printReceipt(c.out, order)
+	fmt.Fprintln(c.out, "Order "+order.Id.String()+" placed, amount to pay "+order.TotalAmount.StringFixedCash(5))
+	if len(tenders) == 0 {
+		return nil
+	}
+	return c.pay(order.Id, tenders)
+}
+
+func (c *CommandController) returnItems(args []string) error {
+	flags := c.newFlagSet("return")
+	orderRef := flags.String("order", "", "id of the purchase order the items were bought on")
+	var lines lineFlags
+	flags.Var(&lines, "line", "item id or name and quantity as <item>:<qty>, repeat for more lines")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return errors.NewError(errors.InvalidInputError, "at least one --line is needed")
+	}
+	orderId, err := uuid.FromString(*orderRef)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "bad order id "+*orderRef)
+	}
+
+	lineItems, err := c.parseLines(lines)
+	if err != nil {
+		return err
+	}
+
+	order, err := c.repo.Return(orderId, &lineItems)
+	if err != nil {
+		return err
+	}
+
+	printReceipt(c.out, order)
+	fmt.Fprintln(c.out, "Return "+order.Id.String()+" booked, amount to refund "+order.TotalAmount.StringFixedCash(5))
+	return nil
+}
+
+// order lines from --line flags
+func (c *CommandController) parseLines(lines lineFlags) ([]models.OrderLineItem, error) {
+	lineItems := make([]models.OrderLineItem, 0, len(lines))
+	for _, line := range lines {
+		sep := strings.LastIndex(line, ":")
+		if sep < 0 {
+			return nil, errors.NewError(errors.InvalidInputError, "--line "+line+" is not <item>:<qty>")
+		}
+		qty, err := strconv.ParseInt(line[sep+1:], 10, 64)
+		if err != nil || qty <= 0 {
+			return nil, errors.NewError(errors.InvalidInputError, "--line "+line+" needs a positive quantity")
+		}
+		item, err := c.catalog.FindItem(line[:sep])
+		if err != nil {
+			return nil, err
+		}
+		lineItems = append(lineItems, models.OrderLineItem{Item: &item, Quantity: qty})
+	}
+	return lineItems, nil
+}
+
+func (c *CommandController) sales(args []string) error {
+	flags := c.newFlagSet("sales")
+	since := flags.Duration("since", 24*time.Hour, "how far back to report")
+	period := flags.String("period", "", "report a whole business day, week or month instead")
+	date := flags.String("date", "", "a business day in the period as 2006-01-02, today by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if set["since"] && set["period"] {
+		fmt.Fprintln(c.errOut, "--since and --period can't be combined")
+		flags.Usage()
+		return errUsage
+	}
+	if set["date"] && !set["period"] {
+		fmt.Fprintln(c.errOut, "--date needs --period")
+		flags.Usage()
+		return errUsage
+	}
+
+	summary, totals := c.repo.SaleSummary(c.clock.Now().Add(-*since))
+	// up to and including now, like the sales
+	tenders := c.repo.TenderReport(c.clock.Now().Add(-*since), c.clock.Now().Add(time.Nanosecond))
+	if *period != "" {
+		at, err := c.day(*date)
+		if err != nil {
+			return err
+		}
+		sales, err := c.repo.SalesFor(usecases.ReportPeriod(*period), at)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "From "+sales.From.Format(time.RFC3339)+" till "+sales.Till.Format(time.RFC3339))
+		summary, totals = sales.Items, sales.Totals
+		tenders = c.repo.TenderReport(sales.From, sales.Till)
+	}
+	c.printSummary(summary)
+	fmt.Fprintln(c.out, "Net "+totals.Net.StringFixedCash(5))
+	fmt.Fprintln(c.out, "Tax "+totals.Tax.StringFixedCash(5))
+	fmt.Fprintln(c.out, "Total "+totals.Total.StringFixedCash(5))
+	if len(totals.Returned) > 0 {
+		fmt.Fprintln(c.out, "Returned")
+		c.printSummary(totals.Returned)
+		fmt.Fprintln(c.out, "Refunds "+totals.Refunds.StringFixedCash(5)+" (tax "+totals.RefundedTax.StringFixedCash(5)+")")
+	}
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	printTenderReport(tw, tenders)
+	tw.Flush()
+	return nil
+}
+
+func (c *CommandController) inventory(args []string) error {
+	flags := c.newFlagSet("inventory")
+	at := flags.String("at", "", "RFC3339 time to report stock levels at, now by default")
+	locationRef := flags.String("location", "", "location id or name, every location summed up by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	var locationIds []uuid.UUID
+	if *locationRef != "" {
+		location, err := c.repo.FindLocation(*locationRef)
+		if err != nil {
+			return err
+		}
+		locationIds = append(locationIds, location.Id)
+	}
+
+	// reservations are only known now
+	if *at == "" {
+		c.printStockLevels(c.repo.StockLevels(locationIds...))
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, *at)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "--at "+*at+" is not an RFC3339 time")
+	}
+	if len(locationIds) == 0 {
+		c.printSummary(c.repo.InventoryAt(t.UTC()))
+		return nil
+	}
+	c.printSummary(c.repo.InventorySummary(locationIds[0], t.UTC()))
+	return nil
+}
+
+func (c *CommandController) movements(args []string) error {
+	flags := c.newFlagSet("movements")
+	period := flags.String("period", string(usecases.DayPeriod), "business day, week or month to report")
+	date := flags.String("date", "", "a business day in the period as 2006-01-02, today by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	at, err := c.day(*date)
+	if err != nil {
+		return err
+	}
+	from, till, err := c.repo.Calendar.Period(usecases.ReportPeriod(*period), at)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, "From "+from.Format(time.RFC3339)+" till "+till.Format(time.RFC3339))
+	movements := c.repo.StockMovements(from, till)
+	for _, orderType := range models.OrderTypes {
+		if len(movements[orderType]) == 0 {
+			continue
+		}
+		fmt.Fprintln(c.out, string(orderType))
+		in := make(map[uuid.UUID]decimal.Decimal)
+		for id, movement := range movements[orderType] {
+			in[id] = movement.In.Sub(movement.Out)
+		}
+		c.printSummary(in)
+	}
+	return nil
+}
+
+func (c *CommandController) shrinkage(args []string) error {
+	flags := c.newFlagSet("shrinkage")
+	period := flags.String("period", string(usecases.WeekPeriod), "business day, week or month to report")
+	date := flags.String("date", "", "a business day in the period as 2006-01-02, today by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	at, err := c.day(*date)
+	if err != nil {
+		return err
+	}
+	report, err := c.repo.ShrinkageReportFor(usecases.ReportPeriod(*period), at)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, "From "+report.From.Format(time.RFC3339)+" till "+report.Till.Format(time.RFC3339))
+	units := make(map[uuid.UUID]decimal.Decimal, len(report.Items))
+	for id, line := range report.Items {
+		units[id] = line.Units
+	}
+	c.printSummary(units)
+	for _, reason := range models.AdjustmentReasons {
+		if line, ok := report.Reasons[reason]; ok {
+			fmt.Fprintf(c.out, "%s\t%s\t%s\n", reason, line.Units, line.Value.StringFixed(2))
+		}
+	}
+	fmt.Fprintf(c.out, "Total\t%s\t%s\n", report.Total.Units, report.Total.Value.StringFixed(2))
+	return nil
+}
+
+func (c *CommandController) reorder(args []string) error {
+	flags := c.newFlagSet("reorder")
+	window := flags.Duration("window", usecases.DefaultReorderWindow, "how far back sales tell how fast items sell")
+	cover := flags.Duration("cover", usecases.DefaultReorderCover, "how long the stock ordered should last")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	suggestions, err := c.repo.SuggestReorders(c.catalog.Items(), *window, *cover)
+	if err != nil {
+		return err
+	}
+	for _, suggestion := range suggestions {
+		fmt.Fprintf(c.out, "%s\t%s\torder %s\tin stock %s\tsells %s/day\n", suggestion.Item.Name,
+			suggestion.Item.Id, suggestion.Quantity, suggestion.Balance, suggestion.Velocity)
+	}
+	return nil
+}
+
+// the business day named by date, today when it's empty
+func (c *CommandController) day(date string) (time.Time, error) {
+	if date == "" {
+		return c.clock.Now(), nil
+	}
+	return c.repo.Calendar.ParseDay(date)
+}
+
+// one "name id value" line per item, sorted by name so the output diffs well
+func (c *CommandController) printSummary(summary map[uuid.UUID]decimal.Decimal) {
+	lines := make([]string, 0, len(summary))
+	for id, v := range summary {
+		name := "unknown"
+		if item, err := c.catalog.GetItem(id); err == nil {
+			name = item.Name
+		}
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s", name, id, v))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		fmt.Fprintln(c.out, line)
+	}
+}
+
+// one "name id on-hand reserved available" line per item, sorted by name like printSummary
+func (c *CommandController) printStockLevels(levels map[uuid.UUID]usecases.StockLevel) {
+	lines := make([]string, 0, len(levels))
+	for id, level := range levels {
+		name := "unknown"
+		if item, err := c.catalog.GetItem(id); err == nil {
+			name = item.Name
+		}
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s\treserved %s\tavailable %s", name, id, level.OnHand,
+			level.Reserved, level.Available))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		fmt.Fprintln(c.out, line)
+	}
+}
+
+func (c *CommandController) findEmployee(ref string) (uuid.UUID, error) {
+	for _, employee := range c.fakeModels.Employees {
+		if uuid.Equal(employee.Id, uuid.FromStringOrNil(ref)) || strings.EqualFold(employee.Name, ref) {
+			return employee.Id, nil
+		}
+	}
+	return uuid.Nil, errors.NewError(errors.NotFoundError, "employee "+ref)
+}
+
+func (c *CommandController) findUser(ref string) (uuid.UUID, int, error) {
+	if id, err := uuid.FromString(ref); err == nil {
+		if discount, ok := c.fakeModels.FindUserDiscount(id); ok {
+			return id, discount, nil
+		}
+	}
+	for _, customer := range c.fakeModels.Customers {
+		if strings.EqualFold(customer.Name, ref) {
+			return customer.Id, customer.DiscountPercentage, nil
+		}
+	}
+	for _, employee := range c.fakeModels.Employees {
+		if strings.EqualFold(employee.Name, ref) {
+			return employee.Id, employee.DiscountPercentage, nil
+		}
+	}
+	return uuid.Nil, 0, errors.NewError(errors.NotFoundError, "user "+ref)
+}
@@ -0,0 +1,506 @@
+package controllers
+
+import (
+	"bytes"
+	"clock"
+	"error"
+	"models"
+	"regexp"
+	"stores"
+	"strings"
+	"testing"
+	"time"
+	"usecases"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestCommandController_Run(t *testing.T) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	now := clock.NewFake(time.Date(2017, 6, 6, 12, 0, 0, 0, time.UTC))
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), now)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	catalog.Seed(fM.Items)
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "Test no command", args: nil, wantCode: ExitUsage},
+		{name: "Test unknown command", args: []string{"restock"}, wantCode: ExitUsage},
+		{name: "Test bad flag", args: []string{"replenish", "--count", "3"}, wantCode: ExitUsage},
+		{name: "Test replenish by name", args: []string{"replenish", "--item", "dora", "--qty", "5"}, wantCode: ExitOk, wantOut: "Replenished Dora with 5"},
+		{name: "Test replenish by id", args: []string{"replenish", "--item", fM.GetMockedItem(1).Id.String(), "--qty", "2"}, wantCode: ExitOk},
+		{name: "Test replenish unknown item", args: []string{"replenish", "--item", "Elmo", "--qty", "5"}, wantCode: 12},
+		{name: "Test replenish zero", args: []string{"replenish", "--item", "Dora", "--qty", "0"}, wantCode: 13},
+		{name: "Test purchase", args: []string{"purchase", "--user", "Alpha", "--line", "Dora:2", "--line", "Teddy:1"}, wantCode: ExitOk, wantOut: "amount to pay"},
+		{name: "Test purchase over stock", args: []string{"purchase", "--user", "Anna", "--line", "Dora:4"}, wantCode: 11},
+		{name: "Test purchase bad line", args: []string{"purchase", "--user", "Anna", "--line", "Dora"}, wantCode: 13},
+		{name: "Test sales", args: []string{"sales", "--since", "1h"}, wantCode: ExitOk, wantOut: "Dora\t" + fM.GetMockedItem(0).Id.String() + "\t2"},
+		{name: "Test sales of a day", args: []string{"sales", "--period", "day", "--date", "2017-06-06"}, wantCode: ExitOk, wantOut: "Dora\t" + fM.GetMockedItem(0).Id.String() + "\t2"},
+		{name: "Test sales of another week", args: []string{"sales", "--period", "week", "--date", "2017-06-13"}, wantCode: ExitOk, wantOut: "Total 0.00"},
+		{name: "Test sales bad period", args: []string{"sales", "--period", "year"}, wantCode: 13},
+		{name: "Test sales since and period", args: []string{"sales", "--since", "1h", "--period", "day"}, wantCode: ExitUsage},
+		{name: "Test sales date without period", args: []string{"sales", "--date", "2017-06-06"}, wantCode: ExitUsage},
+		{name: "Test sales bad date", args: []string{"sales", "--period", "day", "--date", "6/6/2017"}, wantCode: 13},
+		{name: "Test movements", args: []string{"movements", "--date", "2017-06-06"}, wantCode: ExitOk, wantOut: "purchase\nDora\t" + fM.GetMockedItem(0).Id.String() + "\t-2"},
+		{name: "Test movements of another month", args: []string{"movements", "--period", "month", "--date", "2017-07-01"}, wantCode: ExitOk, wantOut: "From 2017-07-01T00:00:00Z"},
+		{name: "Test inventory", args: []string{"inventory"}, wantCode: ExitOk, wantOut: "Dora\t" + fM.GetMockedItem(0).Id.String() + "\t3"},
+		{name: "Test inventory bad time", args: []string{"inventory", "--at", "monday"}, wantCode: 13},
+		{name: "Test adjust", args: []string{"adjust", "--item", "Dora", "--qty", "-1", "--reason", "damage", "--employee", "anna"}, wantCode: ExitOk, wantOut: "Adjusted Dora by -1 for damage"},
+		{name: "Test adjust without reason", args: []string{"adjust", "--item", "Dora", "--qty", "-1", "--employee", "Anna"}, wantCode: 16},
+		{name: "Test adjust by a customer", args: []string{"adjust", "--item", "Dora", "--qty", "-1", "--reason", "theft", "--employee", "Alpha"}, wantCode: 12},
+		{name: "Test shrinkage", args: []string{"shrinkage", "--date", "2017-06-06"}, wantCode: ExitOk, wantOut: "damage\t1\t"},
+		{name: "Test reorder", args: []string{"reorder"}, wantCode: ExitOk, wantOut: "Dora\t" + fM.GetMockedItem(0).Id.String() + "\torder 10\tin stock 2\tsells 0.14/day"},
+		{name: "Test reorder without a window", args: []string{"reorder", "--window", "0s"}, wantCode: 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			commands := NewCommandController(repo, catalog, fM, now, &out, &errOut)
+			if got := commands.Run(tt.args); got != tt.wantCode {
+				t.Errorf("CommandController.Run(%v) = %d, want %d (%s)", tt.args, got, tt.wantCode, errOut.String())
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("CommandController.Run(%v) printed %q, want it to contain %q", tt.args, out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	seen := map[int]string{ExitOk: "ok", ExitFailure: "failure", ExitUsage: "usage"}
+	for errorType, e := range errors.ErrorMap {
+		code := ExitCode(errors.NewError(errorType, ""))
+		if other, ok := seen[code]; ok {
+			t.Errorf("ExitCode(%q) = %d, the code of %q", e.Message, code, other)
+		}
+		seen[code] = e.Message
+	}
+}
+
+func TestCommandController_Return(t *testing.T) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	// paid in cash, a mocked price could be nothing to pay
+	fM.Items[1].Price = decimal.New(10, 0)
+	catalog.Seed(fM.Items)
+
+	run := func(args ...string) (int, string) {
+		var out, errOut bytes.Buffer
+		code := NewCommandController(repo, catalog, fM, clock.System, &out, &errOut).Run(args)
+		return code, out.String() + errOut.String()
+	}
+	run("replenish", "--item", "Teddy", "--qty", "5")
+	code, out := run("purchase", "--user", "Anna", "--line", "Teddy:2", "--tender", "cash:1000")
+	match := regexp.MustCompile(`Order (\S+) placed`).FindStringSubmatch(out)
+	if code != ExitOk || match == nil {
+		t.Fatalf("CommandController.Run(purchase) = %d, %q", code, out)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+	}{
+		{name: "Test return", args: []string{"return", "--order", match[1], "--line", "Teddy:1"}, wantCode: ExitOk},
+		{name: "Test return more than left", args: []string{"return", "--order", match[1], "--line", "Teddy:2"}, wantCode: 15},
+		{name: "Test return bad order id", args: []string{"return", "--order", "last", "--line", "Teddy:1"}, wantCode: 13},
+		{name: "Test return unknown order", args: []string{"return", "--order", fM.GetMockedUser(0).Id.String(), "--line", "Teddy:1"}, wantCode: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, out := run(tt.args...); got != tt.wantCode {
+				t.Errorf("CommandController.Run(%v) = %d, want %d (%s)", tt.args, got, tt.wantCode, out)
+			}
+		})
+	}
+
+	if _, out := run("sales"); !strings.Contains(out, "Returned\nTeddy\t"+fM.GetMockedItem(1).Id.String()+"\t1") {
+		t.Errorf("CommandController.Run(sales) printed %q, want one Teddy returned", out)
+	}
+}
+
+func TestCommandController_Count(t *testing.T) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	catalog.Seed(fM.Items)
+	stockTake := usecases.NewStockTakeUsecaseRepository(repo, stores.NewMemoryStockCountStore())
+
+	// every run is a session of its own, like separate invocations of the binary
+	run := func(args ...string) (int, string) {
+		var out, errOut bytes.Buffer
+		commands := NewCommandController(repo, catalog, fM, clock.System, &out, &errOut)
+		commands.StockTake = stockTake
+		code := commands.Run(args)
+		return code, out.String() + errOut.String()
+	}
+	run("replenish", "--item", "Dora", "--qty", "5")
+	run("replenish", "--item", "Teddy", "--qty", "2")
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "Test record before starting", args: []string{"count", "record", "--line", "Dora:4"}, wantCode: 13},
+		{name: "Test start", args: []string{"count", "start", "--employee", "Anna"}, wantCode: ExitOk, wantOut: "started"},
+		{name: "Test record", args: []string{"count", "record", "--line", "Dora:4"}, wantCode: ExitOk, wantOut: "counted 4\texpected 5\tvariance -1"},
+		{name: "Test record in another session", args: []string{"count", "record", "--line", "Teddy:0"}, wantCode: ExitOk, wantOut: "variance -2"},
+		{name: "Test record negative", args: []string{"count", "record", "--line", "Teddy:-1"}, wantCode: 17},
+		{name: "Test show open", args: []string{"count", "show"}, wantCode: ExitOk, wantOut: "2 items counted"},
+		{name: "Test approve", args: []string{"count", "approve", "--employee", "Boris"}, wantCode: ExitOk, wantOut: "variances posted"},
+		{name: "Test approve again", args: []string{"count", "approve", "--employee", "Boris"}, wantCode: 13},
+		{name: "Test unknown action", args: []string{"count", "finish"}, wantCode: ExitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, out := run(tt.args...)
+			if got != tt.wantCode {
+				t.Errorf("CommandController.Run(%v) = %d, want %d (%s)", tt.args, got, tt.wantCode, out)
+			}
+			if !strings.Contains(out, tt.wantOut) {
+				t.Errorf("CommandController.Run(%v) printed %q, want it to contain %q", tt.args, out, tt.wantOut)
+			}
+		})
+	}
+
+	if _, out := run("inventory"); !strings.Contains(out, "Dora\t"+fM.GetMockedItem(0).Id.String()+"\t4") ||
+		!strings.Contains(out, "Teddy\t"+fM.GetMockedItem(1).Id.String()+"\t0") {
+		t.Errorf("CommandController.Run(inventory) printed %q, want the counted stock", out)
+	}
+}
+
+func TestCommandController_SupplierOrders(t *testing.T) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	catalog.Seed(fM.Items)
+	suppliers := usecases.NewSupplierUsecaseRepository(repo, stores.NewMemorySupplierStore())
+
+	run := func(args ...string) (int, string) {
+		var out, errOut bytes.Buffer
+		commands := NewCommandController(repo, catalog, fM, clock.System, &out, &errOut)
+		commands.Suppliers = suppliers
+		code := commands.Run(args)
+		return code, out.String() + errOut.String()
+	}
+	run("supplier", "add", "--name", "Toys Ltd")
+	_, out := run("po", "draft", "--supplier", "toys ltd", "--employee", "Anna", "--line", "Dora:5", "--line", "Teddy:2")
+	match := regexp.MustCompile(`Order (\S+) to Toys Ltd drafted`).FindStringSubmatch(out)
+	if match == nil {
+		t.Fatalf("CommandController.Run(po draft) printed %q, want the order id", out)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "Test add supplier twice", args: []string{"supplier", "add", "--name", "Toys Ltd"}, wantCode: 18},
+		{name: "Test list suppliers", args: []string{"supplier", "list"}, wantCode: ExitOk, wantOut: "Toys Ltd\t"},
+		{name: "Test draft for unknown supplier", args: []string{"po", "draft", "--supplier", "Acme", "--employee", "Anna", "--line", "Dora:1"}, wantCode: 12},
+		{name: "Test receive a draft", args: []string{"po", "receive", "--id", match[1], "--employee", "Anna", "--line", "Dora:1"}, wantCode: 18},
+		{name: "Test send", args: []string{"po", "send", "--id", match[1]}, wantCode: ExitOk, wantOut: "sent"},
+		{name: "Test receive part", args: []string{"po", "receive", "--id", match[1], "--employee", "Anna", "--line", "Dora:3"}, wantCode: ExitOk, wantOut: "Dora\t" + fM.GetMockedItem(0).Id.String() + "\tordered 5\treceived 3\toutstanding 2"},
+		{name: "Test receive too many", args: []string{"po", "receive", "--id", match[1], "--employee", "Anna", "--line", "Teddy:3"}, wantCode: 18},
+		{name: "Test outstanding", args: []string{"po", "outstanding"}, wantCode: ExitOk, wantOut: "Toys Ltd\n" + match[1] + "\tDora\t" + fM.GetMockedItem(0).Id.String() + "\toutstanding 2"},
+		{name: "Test show open", args: []string{"po", "show"}, wantCode: ExitOk, wantOut: match[1] + "\tToys Ltd\tpartially-received"},
+		{name: "Test cancel", args: []string{"po", "cancel", "--id", match[1]}, wantCode: ExitOk, wantOut: "cancelled"},
+		{name: "Test nothing outstanding once cancelled", args: []string{"po", "outstanding"}, wantCode: ExitOk},
+		{name: "Test bad order id", args: []string{"po", "show", "--id", "last"}, wantCode: 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, out := run(tt.args...)
+			if got != tt.wantCode {
+				t.Errorf("CommandController.Run(%v) = %d, want %d (%s)", tt.args, got, tt.wantCode, out)
+			}
+			if !strings.Contains(out, tt.wantOut) {
+				t.Errorf("CommandController.Run(%v) printed %q, want it to contain %q", tt.args, out, tt.wantOut)
+			}
+		})
+	}
+
+	if _, out := run("inventory"); !strings.Contains(out, "Dora\t"+fM.GetMockedItem(0).Id.String()+"\t3") {
+		t.Errorf("CommandController.Run(inventory) printed %q, want the 3 Dora received", out)
+	}
+}
+
+func TestCommandController_Locations(t *testing.T) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	catalog.Seed(fM.Items)
+
+	run := func(args ...string) (int, string) {
+		var out, errOut bytes.Buffer
+		code := NewCommandController(repo, catalog, fM, clock.System, &out, &errOut).Run(args)
+		return code, out.String() + errOut.String()
+	}
+	dora := fM.GetMockedItem(0).Id.String()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "Test add location", args: []string{"location", "add", "--name", "Warehouse", "--kind", "warehouse"}, wantCode: ExitOk, wantOut: "Location Warehouse added"},
+		{name: "Test add location twice", args: []string{"location", "add", "--name", "warehouse"}, wantCode: 19},
+		{name: "Test add unknown kind", args: []string{"location", "add", "--name", "Van", "--kind", "van"}, wantCode: 19},
+		{name: "Test list locations", args: []string{"location", "list"}, wantCode: ExitOk, wantOut: "Main store\t" + models.DefaultLocationId.String() + "\tstore"},
+		{name: "Test replenish the warehouse", args: []string{"replenish", "--item", "Dora", "--qty", "5", "--location", "Warehouse"}, wantCode: ExitOk},
+		{name: "Test replenish the store", args: []string{"replenish", "--item", "Dora", "--qty", "1"}, wantCode: ExitOk},
+		{name: "Test replenish unknown location", args: []string{"replenish", "--item", "Dora", "--qty", "1", "--location", "Attic"}, wantCode: 12},
+		{name: "Test purchase more than the store has", args: []string{"purchase", "--user", "Anna", "--line", "Dora:2"}, wantCode: 11},
+		{name: "Test purchase from the warehouse", args: []string{"purchase", "--user", "Anna", "--line", "Dora:2", "--location", "warehouse"}, wantCode: ExitOk},
+		{name: "Test inventory of the warehouse", args: []string{"inventory", "--location", "Warehouse"}, wantCode: ExitOk, wantOut: "Dora\t" + dora + "\t3"},
+		{name: "Test inventory of the store", args: []string{"inventory", "--location", "main store"}, wantCode: ExitOk, wantOut: "Dora\t" + dora + "\t1"},
+		{name: "Test inventory across locations", args: []string{"inventory"}, wantCode: ExitOk, wantOut: "Dora\t" + dora + "\t4\treserved 0\tavailable 4"},
+		{name: "Test unknown action", args: []string{"location", "move"}, wantCode: ExitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, out := run(tt.args...)
+			if got != tt.wantCode {
+				t.Errorf("CommandController.Run(%v) = %d, want %d (%s)", tt.args, got, tt.wantCode, out)
+			}
+			if !strings.Contains(out, tt.wantOut) {
+				t.Errorf("CommandController.Run(%v) printed %q, want it to contain %q", tt.args, out, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestCommandController_Transfers(t *testing.T) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	catalog.Seed(fM.Items)
+	transfers := usecases.NewTransferUsecaseRepository(repo, stores.NewMemoryTransferStore())
+
+	run := func(args ...string) (int, string) {
+		var out, errOut bytes.Buffer
+		commands := NewCommandController(repo, catalog, fM, clock.System, &out, &errOut)
+		commands.Transfers = transfers
+		code := commands.Run(args)
+		return code, out.String() + errOut.String()
+	}
+	run("location", "add", "--name", "Warehouse", "--kind", "warehouse")
+	run("replenish", "--item", "Dora", "--qty", "10", "--location", "Warehouse")
+	_, out := run("transfer", "ship", "--from", "Warehouse", "--to", "Main store", "--employee", "Anna", "--line", "Dora:4")
+	match := regexp.MustCompile(`Transfer (\S+) from Warehouse to Main store shipped`).FindStringSubmatch(out)
+	if match == nil {
+		t.Fatalf("CommandController.Run(transfer ship) printed %q, want the transfer id", out)
+	}
+	dora := fM.GetMockedItem(0).Id.String()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "Test ship more than in stock", args: []string{"transfer", "ship", "--from", "Warehouse", "--to", "Main store", "--employee", "Anna", "--line", "Dora:7"}, wantCode: 20},
+		{name: "Test ship to unknown location", args: []string{"transfer", "ship", "--from", "Warehouse", "--to", "Attic", "--employee", "Anna", "--line", "Dora:1"}, wantCode: 12},
+		{name: "Test show in transit", args: []string{"transfer", "show"}, wantCode: ExitOk, wantOut: match[1] + "\tWarehouse -> Main store"},
+		{name: "Test in transit", args: []string{"transfer", "in-transit"}, wantCode: ExitOk, wantOut: "Warehouse -> Main store\nDora\t" + dora + "\t4"},
+		{name: "Test inventory while in transit", args: []string{"inventory"}, wantCode: ExitOk, wantOut: "Dora\t" + dora + "\t6"},
+		{name: "Test receive less", args: []string{"transfer", "receive", "--id", match[1], "--employee", "Boris", "--line", "Dora:3"}, wantCode: ExitOk, wantOut: "Dora\t" + dora + "\tshipped 4\treceived 3\tdiscrepancy -1"},
+		{name: "Test receive again", args: []string{"transfer", "receive", "--id", match[1], "--employee", "Boris"}, wantCode: 20},
+		{name: "Test nothing in transit", args: []string{"transfer", "in-transit"}, wantCode: ExitOk},
+		{name: "Test inventory of the store", args: []string{"inventory", "--location", "Main store"}, wantCode: ExitOk, wantOut: "Dora\t" + dora + "\t3"},
+		{name: "Test bad transfer id", args: []string{"transfer", "show", "--id", "last"}, wantCode: 13},
+		{name: "Test unknown action", args: []string{"transfer", "return"}, wantCode: ExitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, out := run(tt.args...)
+			if got != tt.wantCode {
+				t.Errorf("CommandController.Run(%v) = %d, want %d (%s)", tt.args, got, tt.wantCode, out)
+			}
+			if !strings.Contains(out, tt.wantOut) {
+				t.Errorf("CommandController.Run(%v) printed %q, want it to contain %q", tt.args, out, tt.wantOut)
+			}
+		})
+	}
+
+	if _, out := run("shrinkage", "--period", "day"); !strings.Contains(out, "transfer-discrepancy\t1\t") {
+		t.Errorf("CommandController.Run(shrinkage) printed %q, want the Dora lost in transit", out)
+	}
+}
+
+func TestCommandController_Reservations(t *testing.T) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	catalog.Seed(fM.Items)
+	dora := fM.GetMockedItem(0)
+
+	run := func(args ...string) (int, string) {
+		var out, errOut bytes.Buffer
+		code := NewCommandController(repo, catalog, fM, clock.System, &out, &errOut).Run(args)
+		return code, out.String() + errOut.String()
+	}
+	run("replenish", "--item", "Dora", "--qty", "5")
+	// a cart at another register holds some of it
+	if _, err := repo.Reserve(uuid.NewV4(), models.DefaultLocationId, *dora, 3); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+
+	if code, out := run("purchase", "--user", "Anna", "--line", "Dora:3"); code != 11 {
+		t.Errorf("CommandController.Run(purchase) of reserved stock = %d, want 11 (%s)", code, out)
+	}
+	want := "Dora\t" + dora.Id.String() + "\t5\treserved 3\tavailable 2"
+	if _, out := run("inventory"); !strings.Contains(out, want) {
+		t.Errorf("CommandController.Run(inventory) printed %q, want it to contain %q", out, want)
+	}
+}
+
+func TestCommandController_Carts(t *testing.T) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	catalog.Seed(fM.Items)
+	carts := usecases.NewCartUsecaseRepository(repo, stores.NewMemoryCartStore())
+
+	run := func(register string, args ...string) (int, string) {
+		var out, errOut bytes.Buffer
+		commands := NewCommandController(repo, catalog, fM, clock.System, &out, &errOut)
+		commands.Carts = carts
+		commands.Register = register
+		code := commands.Run(args)
+		return code, out.String() + errOut.String()
+	}
+	run("front", "replenish", "--item", "Dora", "--qty", "5")
+	_, out := run("front", "cart", "open", "--user", "Anna", "--line", "Dora:2")
+	match := regexp.MustCompile(`Cart (\S+) opened at Main store`).FindStringSubmatch(out)
+	if match == nil {
+		t.Fatalf("CommandController.Run(cart open) printed %q, want the cart id", out)
+	}
+	dora := fM.GetMockedItem(0).Id.String()
+
+	tests := []struct {
+		name     string
+		register string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "Test add more than available", register: "front", args: []string{"cart", "add", "--id", match[1], "--line", "Dora:4"}, wantCode: 21},
+		{name: "Test add", register: "front", args: []string{"cart", "add", "--id", match[1], "--line", "Dora:1"}, wantCode: ExitOk, wantOut: "Dora\t" + dora + "\t3"},
+		{name: "Test park", register: "front", args: []string{"cart", "park", "--id", match[1]}, wantCode: ExitOk, wantOut: "Cart " + match[1] + " parked"},
+		{name: "Test list parked", register: "back", args: []string{"cart", "list"}, wantCode: ExitOk, wantOut: match[1] + "\tAnna\t1 lines\tparked\tfront"},
+		{name: "Test checkout parked", register: "back", args: []string{"cart", "checkout", "--id", match[1]}, wantCode: 22},
+		{name: "Test parked stock stays reserved", register: "back", args: []string{"inventory"}, wantCode: ExitOk, wantOut: "Dora\t" + dora + "\t5\treserved 3\tavailable 2"},
+		{name: "Test resume at another register", register: "back", args: []string{"cart", "resume", "--id", match[1]}, wantCode: ExitOk, wantOut: "Cart " + match[1] + " open"},
+		{name: "Test list open", register: "back", args: []string{"cart", "list", "--status", "open"}, wantCode: ExitOk, wantOut: "\topen\tback"},
+		{name: "Test checkout", register: "back", args: []string{"cart", "checkout", "--id", match[1]}, wantCode: ExitOk, wantOut: "placed, amount to pay"},
+		{name: "Test show", register: "back", args: []string{"cart", "show", "--id", match[1]}, wantCode: ExitOk, wantOut: "checked-out\nDora\t" + dora + "\t3"},
+		{name: "Test abandon checked out", register: "back", args: []string{"cart", "abandon", "--id", match[1]}, wantCode: 22},
+		{name: "Test unknown cart", register: "back", args: []string{"cart", "show", "--id", dora}, wantCode: 12},
+		{name: "Test bad cart id", register: "back", args: []string{"cart", "park", "--id", "last"}, wantCode: 13},
+		{name: "Test unknown action", register: "back", args: []string{"cart", "empty"}, wantCode: ExitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, out := run(tt.register, tt.args...)
+			if got != tt.wantCode {
+				t.Errorf("CommandController.Run(%v) = %d, want %d (%s)", tt.args, got, tt.wantCode, out)
+			}
+			if !strings.Contains(out, tt.wantOut) {
+				t.Errorf("CommandController.Run(%v) printed %q, want it to contain %q", tt.args, out, tt.wantOut)
+			}
+		})
+	}
+
+	// abandoning frees what a cart holds
+	_, out = run("front", "cart", "open", "--user", "Boris", "--line", "Dora:2")
+	match = regexp.MustCompile(`Cart (\S+) opened`).FindStringSubmatch(out)
+	if code, out := run("front", "cart", "abandon", "--id", match[1]); code != ExitOk {
+		t.Fatalf("CommandController.Run(cart abandon) = %d (%s)", code, out)
+	}
+	if _, out := run("front", "inventory"); !strings.Contains(out, "\t2\treserved 0\tavailable 2") {
+		t.Errorf("CommandController.Run(inventory) after abandoning printed %q, want nothing reserved", out)
+	}
+}
+
+func TestCommandController_Payments(t *testing.T) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	// mocked prices are random and can be 0, Dora must cost more than the store credit tendered
+	fM.Items[0].Price = decimal.New(10, 0)
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	catalog.Seed(fM.Items)
+
+	run := func(args ...string) (int, string) {
+		var out, errOut bytes.Buffer
+		code := NewCommandController(repo, catalog, fM, clock.System, &out, &errOut).Run(args)
+		return code, out.String() + errOut.String()
+	}
+	run("replenish", "--item", "Dora", "--qty", "5")
+	_, out := run("purchase", "--user", "Anna", "--line", "Dora:1", "--tender", "store-credit:1")
+	match := regexp.MustCompile(`Order (\S+) placed`).FindStringSubmatch(out)
+	if match == nil || !strings.Contains(out, "store-credit\t1.00\nPaid 1.00 of ") {
+		t.Fatalf("CommandController.Run(purchase --tender) printed %q, want the order paid in part", out)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "Test unpaid", args: []string{"payment", "unpaid"}, wantCode: ExitOk, wantOut: match[1] + "\tAnna\t"},
+		{name: "Test too much by card", args: []string{"payment", "take", "--order", match[1], "--tender", "card:1000"}, wantCode: 23},
+		{name: "Test unknown tender", args: []string{"payment", "take", "--order", match[1], "--tender", "cheque:5"}, wantCode: 23},
+		{name: "Test bad tender", args: []string{"payment", "take", "--order", match[1], "--tender", "cash"}, wantCode: 13},
+		{name: "Test cash with change", args: []string{"payment", "take", "--order", match[1], "--tender", "card:1", "--tender", "cash:1000"}, wantCode: ExitOk, wantOut: "is paid in full"},
+		{name: "Test show", args: []string{"payment", "show", "--order", match[1]}, wantCode: ExitOk, wantOut: "card\t1.00\ncash\t1000.00\tchange "},
+		{name: "Test pay a paid order", args: []string{"payment", "take", "--order", match[1], "--tender", "cash:1"}, wantCode: 23},
+		{name: "Test unknown order", args: []string{"payment", "show", "--order", fM.GetMockedItem(0).Id.String()}, wantCode: 12},
+		{name: "Test tenders in the sales", args: []string{"sales"}, wantCode: ExitOk, wantOut: "store-credit  1      1.00"},
+		{name: "Test unknown action", args: []string{"payment", "refund"}, wantCode: ExitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, out := run(tt.args...)
+			if got != tt.wantCode {
+				t.Errorf("CommandController.Run(%v) = %d, want %d (%s)", tt.args, got, tt.wantCode, out)
+			}
+			if !strings.Contains(out, tt.wantOut) {
+				t.Errorf("CommandController.Run(%v) printed %q, want it to contain %q", tt.args, out, tt.wantOut)
+			}
+		})
+	}
+
+	if _, out := run("payment", "unpaid"); strings.Contains(out, match[1]) {
+		t.Errorf("CommandController.Run(payment unpaid) printed %q, want the paid order left out", out)
+	}
+}
@@ -0,0 +1,554 @@
+// Package http exposes the inventory usecases as a JSON API
+package http
+
+import (
+	"clock"
+	"encoding/json"
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"net/http"
+	"strings"
+	"time"
+	"usecases"
+)
+
+// Server routes:
+//
+//	POST /items/{id}/replenish  {"quantity": "10", "locationId": "..."}
+//	POST /orders                {"userId": "...", "locationId": "...", "lines": [{"itemId": "...", "quantity": 2}]}
+//	POST /orders/{id}/returns   {"lines": [{"itemId": "...", "quantity": 1}]}
+//	POST /orders/{id}/payments  {"tenders": [{"type": "cash", "amount": "20"}]}, cash, card or store-credit
+//	GET  /orders/{id}/payments  what was paid for an order, it is pending until paid in full
+//	GET  /reports/sales?from=   sales and tenders since an RFC3339 time, the last 24 hours by default
+//	GET  /reports/sales?period=day|week|month&date=2006-01-02
+//	                            sales of a business period, the current one by default
+//	GET  /inventory?till=&location=
+//	                            stock levels at an RFC3339 time, at a location or summed across
+//	                            locations. Now by default, with what carts reserved and what is available
+//
+// Money and stock levels are encoded as decimal strings. Stock is replenished and sold at the default
+// location when a request has no locationId.
+type Server struct {
+	// decides the default time range of the reports
+	clock      clock.Clock
+	repo       *usecases.InventoryUsecaseRepository
+	catalog    *usecases.CatalogUsecaseRepository
+	fakeModels *models.Mocks
+	mux        *http.ServeMux
+}
+
+// NewServer creates the API on top of the inventory and catalog usecases, telling the time by clk
+func NewServer(repo *usecases.InventoryUsecaseRepository, catalog *usecases.CatalogUsecaseRepository,
+	fakeModels *models.Mocks, clk clock.Clock) *Server {
+	s := &Server{
+		clock:      clk,
+		repo:       repo,
+		catalog:    catalog,
+		fakeModels: fakeModels,
+		mux:        http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/items/", s.replenish)
+	s.mux.HandleFunc("/orders", s.purchase)
+	s.mux.HandleFunc("/orders/", s.order)
+	s.mux.HandleFunc("/reports/sales", s.saleSummary)
+	s.mux.HandleFunc("/inventory", s.inventorySummary)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type replenishRequest struct {
+	Quantity   decimal.Decimal `json:"quantity"`
+	LocationId uuid.UUID       `json:"locationId"`
+}
+
+type replenishResponse struct {
+	ItemId   uuid.UUID       `json:"itemId"`
+	Quantity decimal.Decimal `json:"quantity"`
+}
+
+type orderLineRequest struct {
+	ItemId   uuid.UUID `json:"itemId"`
+	Quantity int64     `json:"quantity"`
+}
+
+type orderRequest struct {
+	UserId     uuid.UUID          `json:"userId"`
+	LocationId uuid.UUID          `json:"locationId"`
+	Lines      []orderLineRequest `json:"lines"`
+}
+
+type discountResponse struct {
+	Source     string          `json:"source"`
+	Percentage int             `json:"percentage"`
+	Amount     decimal.Decimal `json:"amount"`
+}
+
+type orderLineResponse struct {
+	ItemId    uuid.UUID          `json:"itemId"`
+	UnitPrice decimal.Decimal    `json:"unitPrice"`
+	Quantity  int64              `json:"quantity"`
+	Gross     decimal.Decimal    `json:"gross"`
+	Discounts []discountResponse `json:"discounts"`
+	Rounding  decimal.Decimal    `json:"rounding"`
+	Net       decimal.Decimal    `json:"net"`
+	TaxClass  string             `json:"taxClass"`
+	TaxRate   decimal.Decimal    `json:"taxRate"`
+	Tax       decimal.Decimal    `json:"tax"`
+}
+
+type returnRequest struct {
+	Lines []orderLineRequest `json:"lines"`
+}
+
+type orderResponse struct {
+	Id uuid.UUID `json:"id"`
+	// Pending until a purchase order is paid in full
+	Status      string              `json:"status"`
+	NetAmount   decimal.Decimal     `json:"netAmount"`
+	TaxAmount   decimal.Decimal     `json:"taxAmount"`
+	TotalAmount decimal.Decimal     `json:"totalAmount"`
+	Lines       []orderLineResponse `json:"lines"`
+}
+
+type returnResponse struct {
+	orderResponse
+	OriginalOrderId uuid.UUID `json:"originalOrderId"`
+}
+
+type salesResponse struct {
+	From  time.Time                  `json:"from"`
+	Till  time.Time                  `json:"till"`
+	Items map[string]decimal.Decimal `json:"items"`
+	Net   decimal.Decimal            `json:"net"`
+	Tax   decimal.Decimal            `json:"tax"`
+	Total decimal.Decimal            `json:"total"`
+	// Returns, apart from the sales above
+	Returned    map[string]decimal.Decimal `json:"returned"`
+	Refunds     decimal.Decimal            `json:"refunds"`
+	RefundedTax decimal.Decimal            `json:"refundedTax"`
+	// Taken per tender type, and left to pay on the orders of the period
+	Tenders map[string]decimal.Decimal `json:"tenders"`
+	Unpaid  decimal.Decimal            `json:"unpaid"`
+}
+
+type inventoryResponse struct {
+	Till time.Time `json:"till"`
+	// LocationId is left out of the stock summed across locations
+	LocationId *uuid.UUID `json:"locationId,omitempty"`
+	// Stock on hand, and for now what carts reserved of it and what is left to sell
+	Items     map[string]decimal.Decimal `json:"items"`
+	Reserved  map[string]decimal.Decimal `json:"reserved,omitempty"`
+	Available map[string]decimal.Decimal `json:"available,omitempty"`
+}
+
+type tenderRequest struct {
+	Type   string          `json:"type"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+type paymentRequest struct {
+	Tenders []tenderRequest `json:"tenders"`
+}
+
+type tenderResponse struct {
+	Type     string          `json:"type"`
+	Tendered decimal.Decimal `json:"tendered"`
+	Amount   decimal.Decimal `json:"amount"`
+	Change   decimal.Decimal `json:"change"`
+}
+
+type paymentResponse struct {
+	OrderId     uuid.UUID        `json:"orderId"`
+	Status      string           `json:"status"`
+	Due         decimal.Decimal  `json:"due"`
+	Paid        decimal.Decimal  `json:"paid"`
+	Outstanding decimal.Decimal  `json:"outstanding"`
+	Tenders     []tenderResponse `json:"tenders"`
+}
+
+type errorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// POST /items/{id}/replenish
+func (s *Server) replenish(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[2] != "replenish" {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	item, err := s.findItem(parts[1])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req replenishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewError(errors.InvalidInputError, err.Error()))
+		return
+	}
+	if req.Quantity.Sign() <= 0 {
+		writeError(w, errors.NewError(errors.InvalidInputError, "quantity must be positive"))
+		return
+	}
+
+	if _, err := s.repo.Replenish(orDefaultLocation(req.LocationId), item, req.Quantity); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, replenishResponse{ItemId: item.Id, Quantity: req.Quantity})
+}
+
+// POST /orders
+func (s *Server) purchase(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req orderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewError(errors.InvalidInputError, err.Error()))
+		return
+	}
+
+	discount, ok := s.fakeModels.FindUserDiscount(req.UserId)
+	if !ok {
+		writeError(w, errors.NewError(errors.NotFoundError, "user "+req.UserId.String()))
+		return
+	}
+
+	lineItems, err := s.lineItems(req.Lines)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	order, err := s.repo.PurchaseOrder(orDefaultLocation(req.LocationId), &lineItems, req.UserId, discount)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newOrderResponse(order))
+}
+
+// /orders/{id}/returns and /orders/{id}/payments
+func (s *Server) order(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 3 && parts[2] == "payments" {
+		s.payment(w, r)
+		return
+	}
+	s.returnItems(w, r)
+}
+
+// POST /orders/{id}/returns
+func (s *Server) returnItems(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[2] != "returns" {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	orderId, err := uuid.FromString(parts[1])
+	if err != nil {
+		writeError(w, errors.NewError(errors.InvalidInputError, "bad order id "+parts[1]))
+		return
+	}
+	var req returnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewError(errors.InvalidInputError, err.Error()))
+		return
+	}
+	lineItems, err := s.lineItems(req.Lines)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	order, err := s.repo.Return(orderId, &lineItems)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, returnResponse{orderResponse: newOrderResponse(order),
+		OriginalOrderId: order.OriginalOrderId})
+}
+
+// POST or GET /orders/{id}/payments
+func (s *Server) payment(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	orderId, err := uuid.FromString(parts[1])
+	if err != nil {
+		writeError(w, errors.NewError(errors.InvalidInputError, "bad order id "+parts[1]))
+		return
+	}
+	if r.Method == http.MethodGet {
+		payment, err := s.repo.Payment(orderId)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newPaymentResponse(payment))
+		return
+	}
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req paymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewError(errors.InvalidInputError, err.Error()))
+		return
+	}
+	tenders := make([]models.Tender, 0, len(req.Tenders))
+	for _, tender := range req.Tenders {
+		tenders = append(tenders, models.Tender{Type: tender.Type, Tendered: tender.Amount})
+	}
+	payment, err := s.repo.Pay(orderId, tenders...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newPaymentResponse(payment))
+}
+
+func newPaymentResponse(payment models.Payment) paymentResponse {
+	resp := paymentResponse{OrderId: payment.OrderId, Status: payment.Status, Due: payment.Due, Paid: payment.Paid,
+		Outstanding: payment.Outstanding(), Tenders: []tenderResponse{}}
+	for _, tender := range payment.Tenders {
+		resp.Tenders = append(resp.Tenders, tenderResponse{Type: tender.Type, Tendered: tender.Tendered,
+			Amount: tender.Amount, Change: tender.Change})
+	}
+	return resp
+}
+
+func (s *Server) lineItems(lines []orderLineRequest) ([]models.OrderLineItem, error) {
+	lineItems := make([]models.OrderLineItem, 0, len(lines))
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, errors.NewError(errors.InvalidInputError, "quantity must be positive")
+		}
+		item, err := s.findItem(line.ItemId.String())
+		if err != nil {
+			return nil, err
+		}
+		lineItems = append(lineItems, models.OrderLineItem{Item: &item, Quantity: line.Quantity})
+	}
+	return lineItems, nil
+}
+
+func newOrderResponse(order models.Order) orderResponse {
+	resp := orderResponse{Id: order.Id, Status: order.Status, NetAmount: order.NetAmount, TaxAmount: order.TaxAmount,
+		TotalAmount: order.TotalAmount, Lines: []orderLineResponse{}}
+	for _, line := range order.Breakdown {
+		discounts := make([]discountResponse, 0, len(line.Discounts))
+		for _, d := range line.Discounts {
+			discounts = append(discounts, discountResponse{Source: d.Source, Percentage: d.Percentage, Amount: d.Amount})
+		}
+		resp.Lines = append(resp.Lines, orderLineResponse{ItemId: line.ItemId, UnitPrice: line.UnitPrice,
+			Quantity: line.Quantity, Gross: line.Gross, Discounts: discounts, Rounding: line.Rounding, Net: line.Net,
+			TaxClass: line.TaxClass, TaxRate: line.TaxRate, Tax: line.Tax})
+	}
+	return resp
+}
+
+// GET /reports/sales?from=
+func (s *Server) saleSummary(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if period := r.URL.Query().Get("period"); period != "" {
+		s.periodSales(w, usecases.ReportPeriod(period), r.URL.Query().Get("date"))
+		return
+	}
+	from, err := parseTime(r.URL.Query().Get("from"), s.clock.Now().AddDate(0, 0, -1))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	items, totals := s.repo.SaleSummary(from)
+	// up to and including now, like the sales
+	tenders := s.repo.TenderReport(from, s.clock.Now().Add(time.Nanosecond))
+	writeJSON(w, http.StatusOK, salesResponse{From: from, Till: s.clock.Now(), Items: stringKeys(items),
+		Net: totals.Net, Tax: totals.Tax, Total: totals.Total, Returned: stringKeys(totals.Returned),
+		Refunds: totals.Refunds, RefundedTax: totals.RefundedTax, Tenders: tenderAmounts(tenders),
+		Unpaid: tenders.Unpaid})
+}
+
+func (s *Server) periodSales(w http.ResponseWriter, period usecases.ReportPeriod, date string) {
+	at := s.clock.Now()
+	if date != "" {
+		day, err := s.repo.Calendar.ParseDay(date)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		at = day
+	}
+
+	sales, err := s.repo.SalesFor(period, at)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	totals := sales.Totals
+	tenders := s.repo.TenderReport(sales.From, sales.Till)
+	writeJSON(w, http.StatusOK, salesResponse{From: sales.From, Till: sales.Till, Items: stringKeys(sales.Items),
+		Net: totals.Net, Tax: totals.Tax, Total: totals.Total, Returned: stringKeys(totals.Returned),
+		Refunds: totals.Refunds, RefundedTax: totals.RefundedTax, Tenders: tenderAmounts(tenders),
+		Unpaid: tenders.Unpaid})
+}
+
+// GET /inventory?till=&location=
+func (s *Server) inventorySummary(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	till, err := parseTime(r.URL.Query().Get("till"), s.clock.Now())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := inventoryResponse{Till: till}
+	var locationIds []uuid.UUID
+	if ref := r.URL.Query().Get("location"); ref != "" {
+		locationId, err := uuid.FromString(ref)
+		if err != nil {
+			writeError(w, errors.NewError(errors.InvalidInputError, "bad location id "+ref))
+			return
+		}
+		if _, ok := s.repo.Locations.Location(locationId); !ok {
+			writeError(w, errors.NewError(errors.NotFoundError, "location "+ref))
+			return
+		}
+		resp.LocationId = &locationId
+		locationIds = append(locationIds, locationId)
+	}
+
+	// reservations are only known now
+	if r.URL.Query().Get("till") == "" {
+		levels := s.repo.StockLevels(locationIds...)
+		resp.Items = make(map[string]decimal.Decimal, len(levels))
+		resp.Reserved = make(map[string]decimal.Decimal, len(levels))
+		resp.Available = make(map[string]decimal.Decimal, len(levels))
+		for id, level := range levels {
+			resp.Items[id.String()] = level.OnHand
+			resp.Reserved[id.String()] = level.Reserved
+			resp.Available[id.String()] = level.Available
+		}
+	} else if resp.LocationId == nil {
+		resp.Items = stringKeys(s.repo.InventoryAt(till))
+	} else {
+		resp.Items = stringKeys(s.repo.InventorySummary(*resp.LocationId, till))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// requests without a location are for the default one
+func orDefaultLocation(locationId uuid.UUID) uuid.UUID {
+	if uuid.Equal(locationId, uuid.Nil) {
+		return models.DefaultLocationId
+	}
+	return locationId
+}
+
+func (s *Server) findItem(id string) (models.Item, error) {
+	itemId, err := uuid.FromString(id)
+	if err != nil {
+		return models.Item{}, errors.NewError(errors.InvalidInputError, "bad item id "+id)
+	}
+	return s.catalog.GetItem(itemId)
+}
+
+func parseTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, errors.NewError(errors.InvalidInputError, "bad time "+value)
+	}
+	return t.UTC(), nil
+}
+
+// uuid keys would be encoded as objects, JSON wants strings
+func stringKeys(summary map[uuid.UUID]decimal.Decimal) map[string]decimal.Decimal {
+	items := make(map[string]decimal.Decimal, len(summary))
+	for k, v := range summary {
+		items[k.String()] = v
+	}
+	return items
+}
+
+// what each tender type took
+func tenderAmounts(report usecases.TenderReport) map[string]decimal.Decimal {
+	amounts := make(map[string]decimal.Decimal, len(report.Tenders))
+	for tenderType, line := range report.Tenders {
+		amounts[tenderType] = line.Amount
+	}
+	return amounts
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Message: "method not allowed"})
+		return false
+	}
+	return true
+}
+
+// HTTP status for each ApplicationError type, like the exit codes of the subcommands. Requests the state of
+// the store refuses are unprocessable, those that clash with what other registers hold are conflicts.
+var errorStatus = map[int]int{
+	errors.ErrorMap[errors.ReplenishError].ErrorType:    http.StatusUnprocessableEntity,
+	errors.ErrorMap[errors.OrderError].ErrorType:        http.StatusUnprocessableEntity,
+	errors.ErrorMap[errors.NotFoundError].ErrorType:     http.StatusNotFound,
+	errors.ErrorMap[errors.InvalidInputError].ErrorType: http.StatusBadRequest,
+	errors.ErrorMap[errors.CatalogError].ErrorType:      http.StatusUnprocessableEntity,
+	errors.ErrorMap[errors.ReturnError].ErrorType:       http.StatusUnprocessableEntity,
+	errors.ErrorMap[errors.AdjustmentError].ErrorType:   http.StatusUnprocessableEntity,
+	errors.ErrorMap[errors.StockTakeError].ErrorType:    http.StatusUnprocessableEntity,
+	errors.ErrorMap[errors.SupplierError].ErrorType:     http.StatusUnprocessableEntity,
+	errors.ErrorMap[errors.LocationError].ErrorType:     http.StatusUnprocessableEntity,
+	errors.ErrorMap[errors.TransferError].ErrorType:     http.StatusUnprocessableEntity,
+	errors.ErrorMap[errors.ReservationError].ErrorType:  http.StatusConflict,
+	errors.ErrorMap[errors.CartError].ErrorType:         http.StatusConflict,
+	errors.ErrorMap[errors.PaymentError].ErrorType:      http.StatusUnprocessableEntity,
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(errors.ApplicationError)
+	if !ok {
+		e = errors.NewError(errors.UnknownError, err.Error())
+	}
+	status, ok := errorStatus[e.ErrorType]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, errorResponse{Code: e.ErrorType, Message: e.Message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,400 @@
+package http
+
+import (
+	"clock"
+	"encoding/json"
+	"error"
+	"models"
+	"net/http"
+	"net/http/httptest"
+	"stores"
+	"strings"
+	"testing"
+	"usecases"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func newTestServer() (*Server, *models.Mocks) {
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	repo := usecases.NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	catalog := usecases.NewCatalogUsecaseRepository(stores.NewMemoryCatalogStore(), clock.System)
+	catalog.Seed(fM.Items)
+	return NewServer(repo, catalog, fM, clock.System), fM
+}
+
+func TestServer(t *testing.T) {
+	server, fM := newTestServer()
+	item := fM.GetMockedItem(0)
+	user := fM.GetMockedUser(0)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Test replenish",
+			method:     "POST",
+			path:       "/items/" + item.Id.String() + "/replenish",
+			body:       `{"quantity": "5"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"quantity":"5"`,
+		},
+		{
+			name:       "Test replenish unknown item",
+			method:     "POST",
+			path:       "/items/" + user.Id.String() + "/replenish",
+			body:       `{"quantity": "5"}`,
+			wantStatus: http.StatusNotFound,
+			wantBody:   `"code":102`,
+		},
+		{
+			name:       "Test replenish bad quantity",
+			method:     "POST",
+			path:       "/items/" + item.Id.String() + "/replenish",
+			body:       `{"quantity": "-5"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":103`,
+		},
+		{
+			name:       "Test replenish wrong method",
+			method:     "GET",
+			path:       "/items/" + item.Id.String() + "/replenish",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "Test purchase",
+			method:     "POST",
+			path:       "/orders",
+			body:       `{"userId": "` + user.Id.String() + `", "lines": [{"itemId": "` + item.Id.String() + `", "quantity": 2}]}`,
+			wantStatus: http.StatusCreated,
+			wantBody:   `"quantity":2,"gross":"`,
+		},
+		{
+			name:       "Test purchase more than in stock",
+			method:     "POST",
+			path:       "/orders",
+			body:       `{"userId": "` + user.Id.String() + `", "lines": [{"itemId": "` + item.Id.String() + `", "quantity": 4}]}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `"code":101`,
+		},
+		{
+			name:       "Test purchase unknown user",
+			method:     "POST",
+			path:       "/orders",
+			body:       `{"userId": "` + item.Id.String() + `", "lines": [{"itemId": "` + item.Id.String() + `", "quantity": 1}]}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Test purchase malformed",
+			method:     "POST",
+			path:       "/orders",
+			body:       `{"userId": `,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Test sales report",
+			method:     "GET",
+			path:       "/reports/sales?from=2000-01-01T00:00:00Z",
+			wantStatus: http.StatusOK,
+			wantBody:   `"` + item.Id.String() + `":"2"`,
+		},
+		{
+			name:       "Test sales report bad time",
+			method:     "GET",
+			path:       "/reports/sales?from=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Test inventory",
+			method:     "GET",
+			path:       "/inventory",
+			wantStatus: http.StatusOK,
+			wantBody:   `"` + item.Id.String() + `":"3"`,
+		},
+		{
+			name:       "Test inventory before any stock",
+			method:     "GET",
+			path:       "/inventory?till=2000-01-01T00:00:00Z",
+			wantStatus: http.StatusOK,
+			wantBody:   `"items":{}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d, want %d (%s)", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s body = %s, want it to contain %s", tt.method, tt.path, rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	for errorType, e := range errors.ErrorMap {
+		if errorType == errors.UnknownError || errorType == errors.PurchaseDoneBreak ||
+			errorType == errors.MenuDoneBreak {
+			continue
+		}
+		rec := httptest.NewRecorder()
+		writeError(rec, errors.NewError(errorType, ""))
+		if rec.Code < 400 || rec.Code >= 500 {
+			t.Errorf("writeError(%q) status = %d, want a client error", e.Message, rec.Code)
+		}
+	}
+}
+
+func TestServer_PurchaseAmount(t *testing.T) {
+	server, fM := newTestServer()
+	item := fM.GetMockedItem(4)
+	user := fM.GetMockedUser(1)
+	server.repo.Replenish(models.DefaultLocationId, *item, decimal.New(10, 0))
+
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(
+		`{"userId": "`+user.Id.String()+`", "lines": [{"itemId": "`+item.Id.String()+`", "quantity": 3}]}`))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	var got orderResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding order response: %v", err)
+	}
+	want, _ := usecases.CalcOrderAmounts(&[]models.OrderLineItem{{Item: item, Quantity: 3}}, user.DiscountPercentage)
+	if !got.NetAmount.Equal(want) {
+		t.Errorf("POST /orders netAmount = %s, want %s", got.NetAmount, want)
+	}
+	if len(got.Lines) != 1 || got.Lines[0].Quantity != 3 || !got.Lines[0].Net.Equal(want) {
+		t.Errorf("POST /orders lines = %+v, want one line of 3 with net %s", got.Lines, want)
+	}
+}
+
+func TestServer_Return(t *testing.T) {
+	server, fM := newTestServer()
+	item := fM.GetMockedItem(2)
+	user := fM.GetMockedUser(0)
+	// paid in cash, a mocked price could be nothing to pay
+	if err := server.catalog.ChangePrice(item.Id, decimal.New(10, 0), clock.System.Now()); err != nil {
+		t.Fatalf("ChangePrice() error = %v", err)
+	}
+	server.repo.Replenish(models.DefaultLocationId, *item, decimal.New(10, 0))
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return rec
+	}
+	rec := post("/orders", `{"userId": "`+user.Id.String()+`", "lines": [{"itemId": "`+item.Id.String()+`", "quantity": 3}]}`)
+	var order orderResponse
+	if err := json.NewDecoder(rec.Body).Decode(&order); err != nil {
+		t.Fatalf("decoding order response: %v", err)
+	}
+	paid := post("/orders/"+order.Id.String()+"/payments", `{"tenders": [{"type": "cash", "amount": "1000"}]}`)
+	if paid.Code != http.StatusCreated {
+		t.Fatalf("POST /orders/{id}/payments status = %d, want %d (%s)", paid.Code, http.StatusCreated, paid.Body)
+	}
+
+	lines := `{"lines": [{"itemId": "` + item.Id.String() + `", "quantity": 3}]}`
+	rec = post("/orders/"+order.Id.String()+"/returns", lines)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /orders/{id}/returns status = %d, want %d (%s)", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var got returnResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding return response: %v", err)
+	}
+	if !uuid.Equal(got.OriginalOrderId, order.Id) || !got.TotalAmount.Equal(order.TotalAmount) {
+		t.Errorf("POST /orders/{id}/returns = %s refunding %s, want order %s refunding %s", got.OriginalOrderId,
+			got.TotalAmount, order.Id, order.TotalAmount)
+	}
+
+	// everything is back already
+	if rec := post("/orders/"+order.Id.String()+"/returns", lines); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /orders/{id}/returns again status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if rec := post("/orders/"+user.Id.String()+"/returns", lines); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /orders/{id}/returns of an unknown order status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestServer_Locations(t *testing.T) {
+	server, fM := newTestServer()
+	item := fM.GetMockedItem(1)
+	user := fM.GetMockedUser(0)
+	warehouse, err := server.repo.AddLocation("Warehouse", models.WarehouseLocationKind)
+	if err != nil {
+		t.Fatalf("AddLocation() error = %v", err)
+	}
+	server.repo.Replenish(models.DefaultLocationId, *item, decimal.New(2, 0))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Test replenish the warehouse",
+			method:     "POST",
+			path:       "/items/" + item.Id.String() + "/replenish",
+			body:       `{"quantity": "5", "locationId": "` + warehouse.Id.String() + `"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test replenish unknown location",
+			method:     "POST",
+			path:       "/items/" + item.Id.String() + "/replenish",
+			body:       `{"quantity": "5", "locationId": "` + user.Id.String() + `"}`,
+			wantStatus: http.StatusNotFound,
+			wantBody:   `"code":102`,
+		},
+		{
+			name:   "Test purchase more than the store has",
+			method: "POST",
+			path:   "/orders",
+			body: `{"userId": "` + user.Id.String() + `", "lines": [{"itemId": "` + item.Id.String() +
+				`", "quantity": 3}]}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "Test purchase from the warehouse",
+			method: "POST",
+			path:   "/orders",
+			body: `{"userId": "` + user.Id.String() + `", "locationId": "` + warehouse.Id.String() +
+				`", "lines": [{"itemId": "` + item.Id.String() + `", "quantity": 3}]}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Test inventory at the warehouse",
+			method:     "GET",
+			path:       "/inventory?location=" + warehouse.Id.String(),
+			wantStatus: http.StatusOK,
+			wantBody:   `"` + item.Id.String() + `":"2"`,
+		},
+		{
+			name:       "Test inventory across locations",
+			method:     "GET",
+			path:       "/inventory",
+			wantStatus: http.StatusOK,
+			wantBody:   `"` + item.Id.String() + `":"4"`,
+		},
+		{
+			name:       "Test inventory unknown location",
+			method:     "GET",
+			path:       "/inventory?location=" + user.Id.String(),
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d, want %d (%s)", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s body = %s, want it to contain %s", tt.method, tt.path, rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServer_Reservations(t *testing.T) {
+	server, fM := newTestServer()
+	item := fM.GetMockedItem(3)
+	user := fM.GetMockedUser(0)
+	server.repo.Replenish(models.DefaultLocationId, *item, decimal.New(5, 0))
+	if _, err := server.repo.Reserve(uuid.NewV4(), models.DefaultLocationId, *item, 4); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/inventory", nil))
+	var got inventoryResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding inventory response: %v", err)
+	}
+	id := item.Id.String()
+	if !got.Items[id].Equal(decimal.New(5, 0)) || !got.Reserved[id].Equal(decimal.New(4, 0)) ||
+		!got.Available[id].Equal(decimal.New(1, 0)) {
+		t.Errorf("GET /inventory = %+v, want 5 on hand, 4 reserved and 1 available", got)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("POST", "/orders", strings.NewReader(
+		`{"userId": "`+user.Id.String()+`", "lines": [{"itemId": "`+id+`", "quantity": 2}]}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /orders of reserved stock status = %d, want %d (%s)", rec.Code, http.StatusUnprocessableEntity,
+			rec.Body)
+	}
+}
+
+func TestServer_Payments(t *testing.T) {
+	server, fM := newTestServer()
+	item := fM.GetMockedItem(2)
+	user := fM.GetMockedUser(0)
+	// mocked prices are random and can be 0, the order must cost more than the card tendered
+	if err := server.catalog.ChangePrice(item.Id, decimal.New(10, 0), clock.System.Now()); err != nil {
+		t.Fatalf("ChangePrice() error = %v", err)
+	}
+	server.repo.Replenish(models.DefaultLocationId, *item, decimal.New(10, 0))
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return rec
+	}
+	rec := post("/orders", `{"userId": "`+user.Id.String()+`", "lines": [{"itemId": "`+item.Id.String()+`", "quantity": 1}]}`)
+	var order orderResponse
+	if err := json.NewDecoder(rec.Body).Decode(&order); err != nil {
+		t.Fatalf("decoding order response: %v", err)
+	}
+	if order.Status != models.PendingOrderStatus {
+		t.Errorf("POST /orders status = %q, want it pending until paid", order.Status)
+	}
+	payments := "/orders/" + order.Id.String() + "/payments"
+
+	if rec := post(payments, `{"tenders": [{"type": "card", "amount": "1000"}]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /orders/{id}/payments of too much by card status = %d, want %d", rec.Code,
+			http.StatusUnprocessableEntity)
+	}
+	rec = post(payments, `{"tenders": [{"type": "card", "amount": "1"}, {"type": "cash", "amount": "1000"}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /orders/{id}/payments status = %d, want %d (%s)", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var got paymentResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding payment response: %v", err)
+	}
+	change := decimal.New(1001, 0).Sub(order.TotalAmount)
+	if got.Status != models.CompletedOrderStatus || len(got.Tenders) != 2 || !got.Tenders[1].Change.Equal(change) {
+		t.Errorf("POST /orders/{id}/payments = %+v, want it paid with %s change", got, change)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/reports/sales", nil))
+	var sales salesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&sales); err != nil {
+		t.Fatalf("decoding sales response: %v", err)
+	}
+	if !sales.Tenders[models.CardTenderType].Equal(decimal.New(1, 0)) || !sales.Unpaid.Equal(decimal.Zero) {
+		t.Errorf("GET /reports/sales tenders = %v unpaid %s, want 1 by card and nothing unpaid", sales.Tenders,
+			sales.Unpaid)
+	}
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/satori/go.uuid"
+	"models"
+	"strings"
+)
+
+const locationUsage = "location id or name, the main store by default"
+
+// location add|list
+func (c *CommandController) location(args []string) error {
+	if len(args) == 0 {
+		c.usage()
+		return errUsage
+	}
+
+	switch args[0] {
+	case "add":
+		flags := c.newFlagSet("location add")
+		name := flags.String("name", "", "name of the location")
+		kind := flags.String("kind", models.StoreLocationKind, strings.Join(models.LocationKinds, ", "))
+		if err := c.parse(flags, args[1:]); err != nil {
+			return err
+		}
+		location, err := c.repo.AddLocation(*name, *kind)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "Location "+location.Name+" added with id "+location.Id.String())
+		return nil
+	case "list":
+		if err := c.parse(c.newFlagSet("location list"), args[1:]); err != nil {
+			return err
+		}
+		for _, location := range c.repo.Locations.Locations() {
+			fmt.Fprintf(c.out, "%s\t%s\t%s\n", location.Name, location.Id, location.Kind)
+		}
+		return nil
+	}
+	c.usage()
+	return errUsage
+}
+
+// the location named by a --location flag, the default location when it's empty
+func (c *CommandController) findLocation(ref string) (uuid.UUID, error) {
+	if ref == "" {
+		return models.DefaultLocationId, nil
+	}
+	location, err := c.repo.FindLocation(ref)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return location.Id, nil
+}
+
+func (c *CommandController) locationName(id uuid.UUID) string {
+	if location, ok := c.repo.Locations.Location(id); ok {
+		return location.Name
+	}
+	return "unknown"
+}
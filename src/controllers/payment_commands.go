@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"error"
+	"fmt"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"strings"
+)
+
+const tenderUsage = "tender type and amount as <type>:<amount>, cash, card or store-credit, repeat to split"
+
+// payment take|show|unpaid, an order is pending until its tenders cover its total
+func (c *CommandController) payment(args []string) error {
+	if len(args) == 0 {
+		c.usage()
+		return errUsage
+	}
+
+	switch args[0] {
+	case "take":
+		return c.takePayment(args[1:])
+	case "show":
+		return c.showPayment(args[1:])
+	case "unpaid":
+		return c.unpaidOrders(args[1:])
+	}
+	c.usage()
+	return errUsage
+}
+
+func (c *CommandController) takePayment(args []string) error {
+	flags := c.newFlagSet("payment take")
+	orderRef := flags.String("order", "", "id of the purchase order")
+	var tenders lineFlags
+	flags.Var(&tenders, "tender", tenderUsage)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if len(tenders) == 0 {
+		return errors.NewError(errors.InvalidInputError, "at least one --tender is needed")
+	}
+	orderId, err := uuid.FromString(*orderRef)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "bad order id "+*orderRef)
+	}
+	return c.pay(orderId, tenders)
+}
+
+// take the tenders given for an order and print what was paid
+func (c *CommandController) pay(orderId uuid.UUID, tenders lineFlags) error {
+	parsed := make([]models.Tender, 0, len(tenders))
+	for _, tender := range tenders {
+		sep := strings.LastIndex(tender, ":")
+		if sep < 0 {
+			return errors.NewError(errors.InvalidInputError, "--tender "+tender+" is not <type>:<amount>")
+		}
+		tendered, err := decimal.NewFromString(tender[sep+1:])
+		if err != nil {
+			return errors.NewError(errors.InvalidInputError, "--tender "+tender+" needs an amount")
+		}
+		parsed = append(parsed, models.Tender{Type: tender[:sep], Tendered: tendered})
+	}
+
+	payment, err := c.repo.Pay(orderId, parsed...)
+	if err != nil {
+		return err
+	}
+	printPayment(c.out, payment)
+	return nil
+}
+
+func (c *CommandController) showPayment(args []string) error {
+	flags := c.newFlagSet("payment show")
+	orderRef := flags.String("order", "", "id of the purchase order")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	orderId, err := uuid.FromString(*orderRef)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "bad order id "+*orderRef)
+	}
+	payment, err := c.repo.Payment(orderId)
+	if err != nil {
+		return err
+	}
+	printPayment(c.out, payment)
+	return nil
+}
+
+func (c *CommandController) unpaidOrders(args []string) error {
+	if err := c.parse(c.newFlagSet("payment unpaid"), args); err != nil {
+		return err
+	}
+
+	for _, payment := range c.repo.UnpaidOrders() {
+		name := payment.UserId.String()
+		if user, ok := c.fakeModels.FindUser(payment.UserId); ok {
+			name = user.Name
+		}
+		fmt.Fprintf(c.out, "%s\t%s\t%s\tpaid %s of %s\n", payment.OrderId, name,
+			payment.Created.Format("2006-01-02 15:04"), payment.Paid.StringFixed(2), payment.Due.StringFixed(2))
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/satori/go.uuid"
+	"io"
+	"models"
+	"strings"
+	"text/tabwriter"
+)
+
+// Print how every line of an order was priced and taxed, one row per line, then the order totals
+func printReceipt(w io.Writer, order models.Order) {
+	names := make(map[uuid.UUID]string)
+	for _, line := range order.LineItems {
+		names[line.Item.Id] = line.Item.Name
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Item\tQty\tUnit price\tGross\tDiscounts\tRounding\tNet\tTax")
+	for _, line := range order.Breakdown {
+		discounts := make([]string, 0, len(line.Discounts))
+		for _, discount := range line.Discounts {
+			discounts = append(discounts, fmt.Sprintf("%s %d%% -%s", discount.Source, discount.Percentage,
+				discount.Amount.String()))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s %s%% %s\n", names[line.ItemId], line.Quantity,
+			line.UnitPrice.StringFixed(2), line.Gross.StringFixed(2), strings.Join(discounts, ", "),
+			line.Rounding.String(), line.Net.StringFixed(2), line.TaxClass, line.TaxRate.String(), line.Tax.String())
+	}
+	fmt.Fprintf(tw, "Net\t\t\t\t\t\t%s\n", order.NetAmount.StringFixed(2))
+	fmt.Fprintf(tw, "Tax\t\t\t\t\t\t%s\n", order.TaxAmount.StringFixed(2))
+	fmt.Fprintf(tw, "Total\t\t\t\t\t\t%s\n", order.TotalAmount.StringFixed(2))
+	tw.Flush()
+}
+
+// Print the tenders taken for an order, with the change given, and what is left to pay
+func printPayment(w io.Writer, payment models.Payment) {
+	for _, tender := range payment.Tenders {
+		if tender.Change.Sign() > 0 {
+			fmt.Fprintf(w, "%s\t%s\tchange %s\n", tender.Type, tender.Tendered.StringFixed(2), tender.Change.StringFixed(2))
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", tender.Type, tender.Tendered.StringFixed(2))
+	}
+	if payment.Status != models.PendingOrderStatus {
+		fmt.Fprintln(w, "Paid "+payment.Paid.StringFixed(2)+", order "+payment.OrderId.String()+" is paid in full")
+		return
+	}
+	fmt.Fprintln(w, "Paid "+payment.Paid.StringFixed(2)+" of "+payment.Due.StringFixed(2)+", "+
+		payment.Outstanding().StringFixed(2)+" left to pay on order "+payment.OrderId.String())
+}
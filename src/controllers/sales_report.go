@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/satori/go.uuid"
+	"io"
+	"models"
+	"sort"
+	"text/tabwriter"
+	"usecases"
+)
+
+// Print a sales report as one aligned table, a section each for items, SKUs, product groups and customers,
+// then the totals, returns, replenishments and tenders
+func printSalesReport(w io.Writer, report usecases.SalesReport, users *models.Mocks) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	section := func(title string, lines map[uuid.UUID]usecases.SalesLine) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(tw, "%s\tUnits\tGross\tDiscounts\tNet\t\n", title)
+		for _, id := range sortedLines(lines) {
+			printSalesLine(tw, lines[id])
+		}
+		fmt.Fprintln(tw, "\t\t\t\t\t")
+	}
+
+	customers := make(map[uuid.UUID]usecases.SalesLine, len(report.Customers))
+	for id, line := range report.Customers {
+		line.Name = id.String()
+		if user, ok := users.FindUser(id); ok {
+			line.Name = user.Name
+		}
+		customers[id] = line
+	}
+
+	section("Item", report.Items)
+	section("SKU", report.SKUs)
+	section("Product group", report.ProductGroups)
+	section("Customer", customers)
+	report.Total.Name = "Total"
+	printSalesLine(tw, report.Total)
+	fmt.Fprintf(tw, "Tax\t\t\t\t%s\t\n", report.Tax.StringFixed(2))
+	fmt.Fprintln(tw, "\t\t\t\t\t")
+	section("Returned", report.Returns)
+	if len(report.Returns) > 0 {
+		report.Refunds.Name = "Refunds"
+		printSalesLine(tw, report.Refunds)
+		fmt.Fprintf(tw, "Refunded tax\t\t\t\t%s\t\n", report.RefundedTax.StringFixed(2))
+		fmt.Fprintln(tw, "\t\t\t\t\t")
+	}
+	if len(report.Replenished) > 0 {
+		fmt.Fprintln(tw, "Replenished\tUnits\t\t\t\t")
+		for _, id := range sortedLines(report.Replenished) {
+			fmt.Fprintf(tw, "%s\t%s\t\t\t\t\n", report.Replenished[id].Name, report.Replenished[id].Units.String())
+		}
+		fmt.Fprintln(tw, "\t\t\t\t\t")
+	}
+	printTenderReport(tw, report.Tenders)
+	tw.Flush()
+}
+
+func printSalesLine(w io.Writer, line usecases.SalesLine) {
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", line.Name, line.Units.String(), line.Gross.StringFixed(2),
+		line.Discounts.StringFixed(2), line.Net.StringFixed(2))
+}
+
+// ids of the lines sorted by name, so the table reads the same every time
+func sortedLines(lines map[uuid.UUID]usecases.SalesLine) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(lines))
+	for id := range lines {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		if lines[ids[a]].Name != lines[ids[b]].Name {
+			return lines[ids[a]].Name < lines[ids[b]].Name
+		}
+		return ids[a].String() < ids[b].String()
+	})
+	return ids
+}
+
+// what each tender type took, in the order of models.TenderTypes, then what is left to pay
+func printTenderReport(w io.Writer, report usecases.TenderReport) {
+	fmt.Fprintln(w, "Tender\tCount\tAmount\tChange\t\t")
+	for _, tenderType := range models.TenderTypes {
+		if line, ok := report.Tenders[tenderType]; ok {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t\t\n", tenderType, line.Count, line.Amount.StringFixed(2),
+				line.Change.StringFixed(2))
+		}
+	}
+	fmt.Fprintf(w, "Paid\t\t%s\t\t\t\n", report.Total.StringFixed(2))
+	fmt.Fprintf(w, "Unpaid\t\t%s\t\t\t\n", report.Unpaid.StringFixed(2))
+}
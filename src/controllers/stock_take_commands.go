@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"error"
+	"fmt"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"sort"
+	"strings"
+)
+
+// count start|record|show|approve|cancel, a count is kept between runs so it can span several sessions
+func (c *CommandController) count(args []string) error {
+	if c.StockTake == nil {
+		return errors.NewError(errors.StockTakeError, "stock takes aren't set up")
+	}
+	if len(args) == 0 {
+		c.usage()
+		return errUsage
+	}
+
+	switch args[0] {
+	case "start":
+		return c.startCount(args[1:])
+	case "record":
+		return c.recordCount(args[1:])
+	case "show":
+		return c.showCount(args[1:])
+	case "approve":
+		return c.approveCount(args[1:])
+	case "cancel":
+		return c.cancelCount(args[1:])
+	}
+	c.usage()
+	return errUsage
+}
+
+func (c *CommandController) startCount(args []string) error {
+	flags := c.newFlagSet("count start")
+	employeeRef := flags.String("employee", "", "id or name of the employee counting")
+	locationRef := flags.String("location", "", locationUsage)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	employeeId, err := c.findEmployee(*employeeRef)
+	if err != nil {
+		return err
+	}
+	locationId, err := c.findLocation(*locationRef)
+	if err != nil {
+		return err
+	}
+	count, err := c.StockTake.StartCount(locationId, employeeId)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "Count "+count.Id.String()+" started")
+	return nil
+}
+
+func (c *CommandController) recordCount(args []string) error {
+	flags := c.newFlagSet("count record")
+	countRef := flags.String("id", "", "id of the count, the only open one by default")
+	var lines lineFlags
+	flags.Var(&lines, "line", "item id or name and quantity counted as <item>:<qty>, repeat for more items")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return errors.NewError(errors.InvalidInputError, "at least one --line is needed")
+	}
+	countId, err := c.findCount(*countRef)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		sep := strings.LastIndex(line, ":")
+		if sep < 0 {
+			return errors.NewError(errors.InvalidInputError, "--line "+line+" is not <item>:<qty>")
+		}
+		counted, err := decimal.NewFromString(line[sep+1:])
+		if err != nil {
+			return errors.NewError(errors.InvalidInputError, "--line "+line+" needs a quantity")
+		}
+		item, err := c.catalog.FindItem(line[:sep])
+		if err != nil {
+			return err
+		}
+		recorded, err := c.StockTake.RecordCount(countId, item, counted)
+		if err != nil {
+			return err
+		}
+		c.printCountLine(recorded)
+	}
+	return nil
+}
+
+func (c *CommandController) showCount(args []string) error {
+	flags := c.newFlagSet("count show")
+	countRef := flags.String("id", "", "id of the count, every open one by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	if *countRef == "" {
+		for _, count := range c.StockTake.OpenCounts() {
+			fmt.Fprintf(c.out, "%s\tstarted %s\t%d items counted\n", count.Id, count.Created.Format("2006-01-02 15:04"),
+				len(count.Lines))
+		}
+		return nil
+	}
+	countId, err := uuid.FromString(*countRef)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "bad count id "+*countRef)
+	}
+	count, err := c.StockTake.Count(countId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, "Count "+count.Id.String()+" at "+c.locationName(count.LocationId)+" "+count.Status)
+	lines := append([]models.StockCountLine(nil), count.Lines...)
+	sort.Slice(lines, func(a, b int) bool {
+		return lines[a].Item.Name < lines[b].Item.Name
+	})
+	for _, line := range lines {
+		c.printCountLine(line)
+	}
+	return nil
+}
+
+func (c *CommandController) approveCount(args []string) error {
+	flags := c.newFlagSet("count approve")
+	countRef := flags.String("id", "", "id of the count, the only open one by default")
+	employeeRef := flags.String("employee", "", "id or name of the employee approving")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	countId, err := c.findCount(*countRef)
+	if err != nil {
+		return err
+	}
+	employeeId, err := c.findEmployee(*employeeRef)
+	if err != nil {
+		return err
+	}
+	count, err := c.StockTake.ApproveCount(countId, employeeId)
+	if err != nil {
+		return err
+	}
+
+	if uuid.Equal(count.AdjustmentOrderId, uuid.Nil) {
+		fmt.Fprintln(c.out, "Count "+count.Id.String()+" approved, no variances")
+		return nil
+	}
+	fmt.Fprintln(c.out, "Count "+count.Id.String()+" approved, variances posted on order "+
+		count.AdjustmentOrderId.String())
+	return nil
+}
+
+func (c *CommandController) cancelCount(args []string) error {
+	flags := c.newFlagSet("count cancel")
+	countRef := flags.String("id", "", "id of the count, the only open one by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	countId, err := c.findCount(*countRef)
+	if err != nil {
+		return err
+	}
+	if err := c.StockTake.CancelCount(countId); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "Count "+countId.String()+" cancelled")
+	return nil
+}
+
+// the count with the given id, or the only open count when no id is given
+func (c *CommandController) findCount(ref string) (uuid.UUID, error) {
+	if ref != "" {
+		countId, err := uuid.FromString(ref)
+		if err != nil {
+			return uuid.Nil, errors.NewError(errors.InvalidInputError, "bad count id "+ref)
+		}
+		return countId, nil
+	}
+	open := c.StockTake.OpenCounts()
+	if len(open) != 1 {
+		return uuid.Nil, errors.NewError(errors.InvalidInputError, fmt.Sprintf("%d counts are open, give --id",
+			len(open)))
+	}
+	return open[0].Id, nil
+}
+
+func (c *CommandController) printCountLine(line models.StockCountLine) {
+	fmt.Fprintf(c.out, "%s\t%s\tcounted %s\texpected %s\tvariance %s\n", line.Item.Name, line.Item.Id, line.Counted,
+		line.Expected, line.Variance())
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"error"
+	"fmt"
+	"github.com/satori/go.uuid"
+	"models"
+)
+
+// supplier add|list
+func (c *CommandController) supplier(args []string) error {
+	if c.Suppliers == nil {
+		return errors.NewError(errors.SupplierError, "suppliers aren't set up")
+	}
+	if len(args) == 0 {
+		c.usage()
+		return errUsage
+	}
+
+	switch args[0] {
+	case "add":
+		flags := c.newFlagSet("supplier add")
+		name := flags.String("name", "", "name of the supplier")
+		contact := flags.String("contact", "", "how to reach the supplier, eg. an email address")
+		if err := c.parse(flags, args[1:]); err != nil {
+			return err
+		}
+		supplier, err := c.Suppliers.AddSupplier(*name, *contact)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "Supplier "+supplier.Name+" added with id "+supplier.Id.String())
+		return nil
+	case "list":
+		if err := c.parse(c.newFlagSet("supplier list"), args[1:]); err != nil {
+			return err
+		}
+		for _, supplier := range c.Suppliers.Suppliers() {
+			fmt.Fprintf(c.out, "%s\t%s\t%s\n", supplier.Name, supplier.Id, supplier.Contact)
+		}
+		return nil
+	}
+	c.usage()
+	return errUsage
+}
+
+// po draft|send|receive|cancel|show|outstanding, purchase orders to suppliers kept between runs
+func (c *CommandController) supplierOrder(args []string) error {
+	if c.Suppliers == nil {
+		return errors.NewError(errors.SupplierError, "suppliers aren't set up")
+	}
+	if len(args) == 0 {
+		c.usage()
+		return errUsage
+	}
+
+	switch args[0] {
+	case "draft":
+		return c.draftSupplierOrder(args[1:])
+	case "send":
+		return c.changeSupplierOrder("send", args[1:])
+	case "receive":
+		return c.receiveSupplierOrder(args[1:])
+	case "cancel":
+		return c.changeSupplierOrder("cancel", args[1:])
+	case "show":
+		return c.showSupplierOrders(args[1:])
+	case "outstanding":
+		return c.outstanding(args[1:])
+	}
+	c.usage()
+	return errUsage
+}
+
+func (c *CommandController) draftSupplierOrder(args []string) error {
+	flags := c.newFlagSet("po draft")
+	supplierRef := flags.String("supplier", "", "id or name of the supplier")
+	employeeRef := flags.String("employee", "", "id or name of the employee ordering")
+	var lines lineFlags
+	flags.Var(&lines, "line", "item id or name and quantity as <item>:<qty>, repeat for more lines")
+	locationRef := flags.String("location", "", "location id or name to deliver to, the main store by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return errors.NewError(errors.InvalidInputError, "at least one --line is needed")
+	}
+
+	supplier, err := c.Suppliers.FindSupplier(*supplierRef)
+	if err != nil {
+		return err
+	}
+	employeeId, err := c.findEmployee(*employeeRef)
+	if err != nil {
+		return err
+	}
+	lineItems, err := c.parseLines(lines)
+	if err != nil {
+		return err
+	}
+	locationId, err := c.findLocation(*locationRef)
+	if err != nil {
+		return err
+	}
+	order, err := c.Suppliers.DraftOrder(supplier.Id, locationId, employeeId, lineItems)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "Order "+order.Id.String()+" to "+supplier.Name+" drafted")
+	return nil
+}
+
+// send or cancel an order
+func (c *CommandController) changeSupplierOrder(action string, args []string) error {
+	flags := c.newFlagSet("po " + action)
+	orderRef := flags.String("id", "", "id of the order")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	orderId, err := uuid.FromString(*orderRef)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "bad order id "+*orderRef)
+	}
+
+	change := c.Suppliers.SendOrder
+	if action == "cancel" {
+		change = c.Suppliers.CancelOrder
+	}
+	order, err := change(orderId)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "Order "+order.Id.String()+" "+order.Status)
+	return nil
+}
+
+func (c *CommandController) receiveSupplierOrder(args []string) error {
+	flags := c.newFlagSet("po receive")
+	orderRef := flags.String("id", "", "id of the order")
+	employeeRef := flags.String("employee", "", "id or name of the employee receiving")
+	var lines lineFlags
+	flags.Var(&lines, "line", "item id or name and quantity delivered as <item>:<qty>, repeat for more items")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return errors.NewError(errors.InvalidInputError, "at least one --line is needed")
+	}
+	orderId, err := uuid.FromString(*orderRef)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "bad order id "+*orderRef)
+	}
+
+	employeeId, err := c.findEmployee(*employeeRef)
+	if err != nil {
+		return err
+	}
+	lineItems, err := c.parseLines(lines)
+	if err != nil {
+		return err
+	}
+	order, err := c.Suppliers.ReceiveOrder(orderId, lineItems, employeeId)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "Order "+order.Id.String()+" "+order.Status)
+	for _, line := range order.Lines {
+		c.printSupplierOrderLine(line)
+	}
+	return nil
+}
+
+func (c *CommandController) showSupplierOrders(args []string) error {
+	flags := c.newFlagSet("po show")
+	orderRef := flags.String("id", "", "id of the order, every order still open by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	if *orderRef == "" {
+		for _, order := range c.Suppliers.Orders() {
+			if order.Status == models.ReceivedSupplierOrderStatus ||
+				order.Status == models.CancelledSupplierOrderStatus {
+				continue
+			}
+			fmt.Fprintf(c.out, "%s\t%s\t%s\tdrafted %s\n", order.Id, c.supplierName(order.SupplierId), order.Status,
+				order.Created.Format("2006-01-02 15:04"))
+		}
+		return nil
+	}
+	orderId, err := uuid.FromString(*orderRef)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "bad order id "+*orderRef)
+	}
+	order, err := c.Suppliers.Order(orderId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, "Order "+order.Id.String()+" to "+c.supplierName(order.SupplierId)+" for "+
+		c.locationName(order.LocationId)+" "+order.Status)
+	for _, line := range order.Lines {
+		c.printSupplierOrderLine(line)
+	}
+	return nil
+}
+
+func (c *CommandController) outstanding(args []string) error {
+	if err := c.parse(c.newFlagSet("po outstanding"), args); err != nil {
+		return err
+	}
+
+	outstanding := c.Suppliers.Outstanding()
+	for _, supplier := range c.Suppliers.Suppliers() {
+		if len(outstanding[supplier.Id]) == 0 {
+			continue
+		}
+		fmt.Fprintln(c.out, supplier.Name)
+		for _, line := range outstanding[supplier.Id] {
+			fmt.Fprintf(c.out, "%s\t%s\t%s\toutstanding %s\n", line.OrderId, line.Item.Name, line.Item.Id,
+				line.Outstanding())
+		}
+	}
+	return nil
+}
+
+func (c *CommandController) supplierName(id uuid.UUID) string {
+	if supplier, err := c.Suppliers.FindSupplier(id.String()); err == nil {
+		return supplier.Name
+	}
+	return "unknown"
+}
+
+func (c *CommandController) printSupplierOrderLine(line models.SupplierOrderLine) {
+	fmt.Fprintf(c.out, "%s\t%s\tordered %s\treceived %s\toutstanding %s\n", line.Item.Name, line.Item.Id,
+		line.Ordered, line.Received, line.Outstanding())
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"error"
+	"fmt"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"sort"
+)
+
+// transfer ship|receive|show|in-transit, transfers are kept between runs so stock can travel for days
+func (c *CommandController) transfer(args []string) error {
+	if c.Transfers == nil {
+		return errors.NewError(errors.TransferError, "transfers aren't set up")
+	}
+	if len(args) == 0 {
+		c.usage()
+		return errUsage
+	}
+
+	switch args[0] {
+	case "ship":
+		return c.shipTransfer(args[1:])
+	case "receive":
+		return c.receiveTransfer(args[1:])
+	case "show":
+		return c.showTransfers(args[1:])
+	case "in-transit":
+		return c.inTransit(args[1:])
+	}
+	c.usage()
+	return errUsage
+}
+
+func (c *CommandController) shipTransfer(args []string) error {
+	flags := c.newFlagSet("transfer ship")
+	fromRef := flags.String("from", "", "id or name of the location the stock leaves")
+	toRef := flags.String("to", "", "id or name of the location the stock goes to")
+	employeeRef := flags.String("employee", "", "id or name of the employee shipping")
+	var lines lineFlags
+	flags.Var(&lines, "line", "item id or name and quantity as <item>:<qty>, repeat for more lines")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return errors.NewError(errors.InvalidInputError, "at least one --line is needed")
+	}
+
+	from, err := c.repo.FindLocation(*fromRef)
+	if err != nil {
+		return err
+	}
+	to, err := c.repo.FindLocation(*toRef)
+	if err != nil {
+		return err
+	}
+	employeeId, err := c.findEmployee(*employeeRef)
+	if err != nil {
+		return err
+	}
+	lineItems, err := c.parseLines(lines)
+	if err != nil {
+		return err
+	}
+	transfer, err := c.Transfers.Ship(from.Id, to.Id, employeeId, lineItems)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "Transfer "+transfer.Id.String()+" from "+from.Name+" to "+to.Name+" shipped")
+	return nil
+}
+
+func (c *CommandController) receiveTransfer(args []string) error {
+	flags := c.newFlagSet("transfer receive")
+	transferRef := flags.String("id", "", "id of the transfer")
+	employeeRef := flags.String("employee", "", "id or name of the employee receiving")
+	var lines lineFlags
+	flags.Var(&lines, "line", "item id or name and quantity that arrived as <item>:<qty>, repeat for more lines, "+
+		"everything shipped by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	transferId, err := uuid.FromString(*transferRef)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "bad transfer id "+*transferRef)
+	}
+
+	employeeId, err := c.findEmployee(*employeeRef)
+	if err != nil {
+		return err
+	}
+	var lineItems []models.OrderLineItem
+	if len(lines) == 0 {
+		transfer, err := c.Transfers.Transfer(transferId)
+		if err != nil {
+			return err
+		}
+		for n := range transfer.Lines {
+			lineItems = append(lineItems, models.OrderLineItem{Item: &transfer.Lines[n].Item,
+				Quantity: transfer.Lines[n].Shipped.IntPart()})
+		}
+	} else if lineItems, err = c.parseLines(lines); err != nil {
+		return err
+	}
+	transfer, err := c.Transfers.Receive(transferId, lineItems, employeeId)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "Transfer "+transfer.Id.String()+" "+transfer.Status+" at "+c.locationName(transfer.ToLocationId))
+	for _, line := range transfer.Lines {
+		c.printTransferLine(transfer, line)
+	}
+	return nil
+}
+
+func (c *CommandController) showTransfers(args []string) error {
+	flags := c.newFlagSet("transfer show")
+	transferRef := flags.String("id", "", "id of the transfer, every transfer in transit by default")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	if *transferRef == "" {
+		for _, transfer := range c.Transfers.Transfers() {
+			if transfer.Status != models.InTransitTransferStatus {
+				continue
+			}
+			fmt.Fprintf(c.out, "%s\t%s -> %s\tshipped %s\n", transfer.Id, c.locationName(transfer.FromLocationId),
+				c.locationName(transfer.ToLocationId), transfer.Created.Format("2006-01-02 15:04"))
+		}
+		return nil
+	}
+	transferId, err := uuid.FromString(*transferRef)
+	if err != nil {
+		return errors.NewError(errors.InvalidInputError, "bad transfer id "+*transferRef)
+	}
+	transfer, err := c.Transfers.Transfer(transferId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, "Transfer "+transfer.Id.String()+" from "+c.locationName(transfer.FromLocationId)+" to "+
+		c.locationName(transfer.ToLocationId)+" "+transfer.Status)
+	for _, line := range transfer.Lines {
+		c.printTransferLine(transfer, line)
+	}
+	return nil
+}
+
+// stock in transit by route, routes sorted by the names of their locations
+func (c *CommandController) inTransit(args []string) error {
+	if err := c.parse(c.newFlagSet("transfer in-transit"), args); err != nil {
+		return err
+	}
+
+	inTransit := c.Transfers.InTransit()
+	routes := make([]string, 0, len(inTransit))
+	byName := make(map[string]map[uuid.UUID]decimal.Decimal, len(inTransit))
+	for route, items := range inTransit {
+		name := c.locationName(route.FromLocationId) + " -> " + c.locationName(route.ToLocationId)
+		routes = append(routes, name)
+		byName[name] = items
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		fmt.Fprintln(c.out, route)
+		c.printSummary(byName[route])
+	}
+	return nil
+}
+
+// nothing is received while a transfer is in transit
+func (c *CommandController) printTransferLine(transfer models.Transfer, line models.TransferLine) {
+	if transfer.Status == models.InTransitTransferStatus {
+		fmt.Fprintf(c.out, "%s\t%s\tshipped %s\n", line.Item.Name, line.Item.Id, line.Shipped)
+		return
+	}
+	fmt.Fprintf(c.out, "%s\t%s\tshipped %s\treceived %s\tdiscrepancy %s\n", line.Item.Name, line.Item.Id,
+		line.Shipped, line.Received, line.Discrepancy())
+}
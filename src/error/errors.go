@@ -30,7 +30,20 @@ var (
 		UnknownError:      {999, "Unknown Error - "},
 		ReplenishError:    {100, "Can't replenish inventory - "},
 		OrderError:        {101, "Error placing order - "},
+		NotFoundError:     {102, "Not found - "},
+		InvalidInputError: {103, "Invalid input - "},
+		CatalogError:      {104, "Can't change catalog - "},
+		ReturnError:       {105, "Can't return items - "},
+		AdjustmentError:   {106, "Can't adjust stock - "},
+		StockTakeError:    {107, "Can't take stock - "},
+		SupplierError:     {108, "Supplier order failed - "},
+		LocationError:     {109, "Can't change locations - "},
+		TransferError:     {110, "Can't transfer stock - "},
+		ReservationError:  {111, "Can't reserve stock - "},
+		CartError:         {112, "Cart failed - "},
+		PaymentError:      {113, "Can't take payment - "},
 		PurchaseDoneBreak: {200, "All done, place order - "},
+		MenuDoneBreak:     {201, "All done, back to main menu - "},
 	}
 )
 
@@ -39,6 +52,19 @@ const (
 	ReplenishError
 	OrderError
 	PurchaseDoneBreak
+	NotFoundError
+	InvalidInputError
+	CatalogError
+	MenuDoneBreak
+	ReturnError
+	AdjustmentError
+	StockTakeError
+	SupplierError
+	LocationError
+	TransferError
+	ReservationError
+	CartError
+	PaymentError
 )
 
 // Error to format errors
@@ -1,12 +1,169 @@
 package main
 
 import (
+	"clock"
 	"controllers"
+	"controllers/http"
+	"flag"
 	"fmt"
 	"gopkg.in/dixonwille/wmenu.v4"
+	"models"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"stores"
+	"usecases"
 )
 
 func main() {
+	dataDir := flag.String("data", "data", "directory the ledger and catalog are kept in, empty keeps them in memory only")
+	httpAddr := flag.String("http", "", "serve the HTTP API on this address instead of the interactive menu")
+	discounts := flag.String("discounts", "stacked", "how item, SKU, product group and user discounts combine: best-of, stacked or first-match")
+	taxRates := flag.String("tax-rates", "", "tax percentage per tax class, eg. standard=20,reduced=5, classes left out aren't taxed")
+	taxInclusive := flag.Bool("tax-inclusive", false, "prices already include tax")
+	taxRounding := flag.String("tax-rounding", "line", "round tax per line or per invoice")
+	timeZone := flag.String("time-zone", "UTC", "IANA time zone of the store, eg. Europe/London, business days follow it")
+	dayCutoff := flag.Int("day-cutoff", 0, "hour of the day a business day starts at, late sales count towards the day before")
+	menuLocation := flag.String("location", "", "id or name of the location the interactive menu sells and restocks at, the main store by default")
+	register := flag.String("register", defaultRegister(), "name of this register in the carts it parks and resumes")
+	reservationTTL := flag.Duration("reservation-ttl", usecases.DefaultReservationTTL, "how long stock stays held for a cart nobody touches")
+	flag.Parse()
+
+	discountPolicy, err := usecases.NewDiscountPolicy(*discounts)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(2)
+	}
+	rates, err := usecases.ParseTaxRates(*taxRates)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(2)
+	}
+	taxCalculator, err := usecases.NewTaxCalculator(rates, *taxInclusive, usecases.TaxRounding(*taxRounding))
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(2)
+	}
+
+	calendar, err := usecases.NewBusinessCalendar(*timeZone, *dayCutoff)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(2)
+	}
+
+	ledger, err := openLedger(*dataDir)
+	if err != nil {
+		fmt.Println("Can't open the ledger... " + err.Error())
+		os.Exit(1)
+	}
+	catalogStore, err := openCatalog(*dataDir)
+	if err != nil {
+		fmt.Println("Can't open the catalog... " + err.Error())
+		os.Exit(1)
+	}
+	counts, err := openCounts(*dataDir)
+	if err != nil {
+		fmt.Println("Can't open the stock counts... " + err.Error())
+		os.Exit(1)
+	}
+	supplierStore, err := openSuppliers(*dataDir)
+	if err != nil {
+		fmt.Println("Can't open the suppliers... " + err.Error())
+		os.Exit(1)
+	}
+	locations, err := openLocations(*dataDir)
+	if err != nil {
+		fmt.Println("Can't open the locations... " + err.Error())
+		os.Exit(1)
+	}
+	transferStore, err := openTransfers(*dataDir)
+	if err != nil {
+		fmt.Println("Can't open the transfers... " + err.Error())
+		os.Exit(1)
+	}
+	reservations, err := openReservations(*dataDir)
+	if err != nil {
+		fmt.Println("Can't open the reservations... " + err.Error())
+		os.Exit(1)
+	}
+	cartStore, err := openCarts(*dataDir)
+	if err != nil {
+		fmt.Println("Can't open the carts... " + err.Error())
+		os.Exit(1)
+	}
+	payments, err := openPayments(*dataDir)
+	if err != nil {
+		fmt.Println("Can't open the payments... " + err.Error())
+		os.Exit(1)
+	}
+	// everything tells the time by the one clock
+	clk := clock.System
+	repo := usecases.NewInventoryUsecaseRepository(ledger, clk)
+	repo.Locations = locations
+	repo.Reservations = reservations
+	repo.ReservationTTL = *reservationTTL
+	repo.Payments = payments
+	repo.Catalog = catalogStore
+	// orders placed before payments were taken are paid, not unpaid
+	if _, err := repo.SettlePastOrders(); err != nil {
+		fmt.Println("Can't settle the past orders... " + err.Error())
+		os.Exit(1)
+	}
+	// nor is there anything to pay for orders that were never placed
+	if _, err := repo.DropOrphanedPayments(); err != nil {
+		fmt.Println("Can't drop the payments of orders never placed... " + err.Error())
+		os.Exit(1)
+	}
+	repo.DiscountPolicy = discountPolicy
+	repo.TaxCalculator = taxCalculator
+	repo.Calendar = calendar
+	repo.LowStock = func(alert usecases.LowStockAlert) {
+		location, _ := locations.Location(alert.LocationId)
+		fmt.Fprintf(os.Stderr, "Low stock at %s: %s down to %s, reorder point %s\n", location.Name, alert.Item.Name,
+			alert.Balance, alert.Item.ReorderPoint)
+	}
+	catalog := usecases.NewCatalogUsecaseRepository(catalogStore, clk)
+	stockTake := usecases.NewStockTakeUsecaseRepository(repo, counts)
+	suppliers := usecases.NewSupplierUsecaseRepository(repo, supplierStore)
+	transfers := usecases.NewTransferUsecaseRepository(repo, transferStore)
+	carts := usecases.NewCartUsecaseRepository(repo, cartStore)
+	fakeModels := models.NewMocks(clk)
+	fakeModels.InitInventory()
+	fakeModels.InitUsers()
+	// first start: stock the catalog with the mocked toys
+	if err := catalog.Seed(fakeModels.Items); err != nil {
+		fmt.Println("Can't seed the catalog... " + err.Error())
+		os.Exit(1)
+	}
+	controllers.Cli = controllers.NewCliController(repo, catalog, carts, fakeModels, clk)
+	controllers.Cli.Register = *register
+
+	if flag.NArg() > 0 {
+		commands := controllers.NewCommandController(repo, catalog, fakeModels, clk, os.Stdout, os.Stderr)
+		commands.StockTake = stockTake
+		commands.Suppliers = suppliers
+		commands.Transfers = transfers
+		commands.Carts = carts
+		commands.Register = *register
+		os.Exit(commands.Run(flag.Args()))
+	}
+
+	if *httpAddr != "" {
+		fmt.Println("Toy store API listening on " + *httpAddr)
+		err := nethttp.ListenAndServe(*httpAddr, http.NewServer(repo, catalog, fakeModels, clk))
+		fmt.Println("API stopped... " + err.Error())
+		os.Exit(1)
+	}
+
+	if *menuLocation != "" {
+		location, err := repo.FindLocation(*menuLocation)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(2)
+		}
+		controllers.Cli.Location = location.Id
+	}
+
 	fmt.Println("Welcome to the toy store!")
 
 	// Replenish stock at beginning
@@ -25,3 +182,75 @@ func main() {
 		}
 	}
 }
+
+func openLedger(dir string) (stores.LedgerStore, error) {
+	if dir == "" {
+		return stores.NewMemoryLedgerStore(), nil
+	}
+	return stores.OpenFileLedgerStore(dir)
+}
+
+func openCatalog(dir string) (stores.CatalogStore, error) {
+	if dir == "" {
+		return stores.NewMemoryCatalogStore(), nil
+	}
+	return stores.OpenFileCatalogStore(filepath.Join(dir, "catalog.json"))
+}
+
+func openCounts(dir string) (stores.StockCountStore, error) {
+	if dir == "" {
+		return stores.NewMemoryStockCountStore(), nil
+	}
+	return stores.OpenFileStockCountStore(filepath.Join(dir, "counts.json"))
+}
+
+func openSuppliers(dir string) (stores.SupplierStore, error) {
+	if dir == "" {
+		return stores.NewMemorySupplierStore(), nil
+	}
+	return stores.OpenFileSupplierStore(filepath.Join(dir, "suppliers.json"))
+}
+
+func openLocations(dir string) (stores.LocationStore, error) {
+	if dir == "" {
+		return stores.NewMemoryLocationStore(), nil
+	}
+	return stores.OpenFileLocationStore(filepath.Join(dir, "locations.json"))
+}
+
+func openTransfers(dir string) (stores.TransferStore, error) {
+	if dir == "" {
+		return stores.NewMemoryTransferStore(), nil
+	}
+	return stores.OpenFileTransferStore(filepath.Join(dir, "transfers.json"))
+}
+
+func openReservations(dir string) (stores.ReservationStore, error) {
+	if dir == "" {
+		return stores.NewMemoryReservationStore(), nil
+	}
+	return stores.OpenFileReservationStore(filepath.Join(dir, "reservations.json"))
+}
+
+func openCarts(dir string) (stores.CartStore, error) {
+	if dir == "" {
+		return stores.NewMemoryCartStore(), nil
+	}
+	return stores.OpenFileCartStore(filepath.Join(dir, "carts.json"))
+}
+
+func openPayments(dir string) (stores.PaymentStore, error) {
+	if dir == "" {
+		return stores.NewMemoryPaymentStore(), nil
+	}
+	return stores.OpenFilePaymentStore(filepath.Join(dir, "payments.json"))
+}
+
+// registers are told apart by the host they run on unless they are named
+func defaultRegister() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "register"
+	}
+	return host
+}
@@ -2,6 +2,7 @@
 package models
 
 import (
+	"clock"
 	"github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 	"math/rand"
@@ -12,27 +13,45 @@ import (
 
 type Mocks struct {
 	Items     []Item
-	LineItems []OrderLineItem
 	Customers []Customer
 	Employees []Employee
 
-	PurchaseUserId       uuid.UUID
-	PurchaseUserDiscount int
+	// stamps the mocks, the wall clock if nil
+	clock clock.Clock
+}
+
+// NewMocks creates empty mocks stamped by clk
+func NewMocks(clk clock.Clock) *Mocks {
+	return &Mocks{clock: clk}
+}
+
+func (m *Mocks) now() time.Time {
+	if m.clock == nil {
+		return clock.System.Now()
+	}
+	return m.clock.Now()
+}
+
+// ids are derived from names so they stay the same across restarts and match a persisted ledger
+func mockId(kind string, name string) uuid.UUID {
+	return uuid.NewV5(uuid.NamespaceOID, "toy-store/"+kind+"/"+name)
 }
 
 func (m *Mocks) InitInventory() {
 	if len(m.Items) == 0 {
 		// fake fill Items
 		itemNames := []string{"Dora", "Teddy", "Superman", "Spiderman", "Batman"}
-		superHeroSkuId := uuid.NewV4()
+		superHeroSkuId := mockId("sku", "SuperHeroToy")
 		superHeroDiscount := rand.Intn(50)
 
 		// init Items first time around
 		for i := 0; i < len(itemNames); i++ {
 			item := new(Item)
 			item.Name = itemNames[i]
-			item.Id = uuid.NewV4()
+			item.Id = mockId("item", item.Name)
 			item.Price = decimal.New(rand.Int63n(100), 0)
+			item.ReorderPoint = decimal.New(3, 0)
+			item.ReorderQuantity = decimal.New(10, 0)
 			item.SKU = *new(SKU)
 			if strings.Contains(item.Name, "man") {
 				// assign a superhero sku with a discount on sku
@@ -41,12 +60,12 @@ func (m *Mocks) InitInventory() {
 				item.SKU.DiscountPercentage = superHeroDiscount
 			} else {
 				// no discounts for others
-				item.SkuId = uuid.NewV4()
 				item.SKU.Name = item.Name + strconv.Itoa(i)
+				item.SkuId = mockId("sku", item.SKU.Name)
 			}
 			item.Status = AvailableItemStatus
-			item.Created = time.Now().UTC()
-			item.Modified = time.Now().UTC()
+			item.Created = m.now()
+			item.Modified = m.now()
 			m.Items = append(m.Items, *item)
 		}
 	}
@@ -62,11 +81,11 @@ func (m *Mocks) InitUsers() {
 		for i := 0; i < len(customerNames); i++ {
 			customer := new(Customer)
 			customer.Name = customerNames[i]
-			customer.Id = uuid.NewV4()
+			customer.Id = mockId("customer", customer.Name)
 			customer.DiscountPercentage = rand.Intn(10)
 			customer.Status = EnabledUserStatus
-			customer.Created = time.Now().UTC()
-			customer.Modified = time.Now().UTC()
+			customer.Created = m.now()
+			customer.Modified = m.now()
 			m.Customers = append(m.Customers, *customer)
 		}
 	}
@@ -79,12 +98,12 @@ func (m *Mocks) InitUsers() {
 		for i := 0; i < len(employeeNames); i++ {
 			employee := new(Employee)
 			employee.Name = employeeNames[i]
-			employee.Id = uuid.NewV4()
+			employee.Id = mockId("employee", employee.Name)
 			// Employees have better discount
 			employee.DiscountPercentage = rand.Intn(30)
 			employee.Status = EnabledUserStatus
-			employee.Created = time.Now().UTC()
-			employee.Modified = time.Now().UTC()
+			employee.Created = m.now()
+			employee.Modified = m.now()
 			m.Employees = append(m.Employees, *employee)
 		}
 	}
@@ -100,9 +119,23 @@ func (m *Mocks) GetMockedItem(i int) *Item {
 	return &m.Items[i]
 }
 
-// for testability
-func (m *Mocks) ClearMockModelCache() {
-	m.LineItems = nil
-	m.PurchaseUserDiscount = 0
-	m.PurchaseUserId = uuid.Nil
+// FindUser looks a customer or employee up by id
+func (m *Mocks) FindUser(id uuid.UUID) (User, bool) {
+	for _, c := range m.Customers {
+		if uuid.Equal(c.Id, id) {
+			return c.User, true
+		}
+	}
+	for _, e := range m.Employees {
+		if uuid.Equal(e.Id, id) {
+			return e.User, true
+		}
+	}
+	return User{}, false
+}
+
+// FindUserDiscount looks a customer or employee up by id and returns their discount
+func (m *Mocks) FindUserDiscount(id uuid.UUID) (int, bool) {
+	user, ok := m.FindUser(id)
+	return user.DiscountPercentage, ok
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"clock"
 	"github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 	"sync"
@@ -28,13 +29,11 @@ type User struct {
 
 type Customer struct {
 	User
-	DiscountPercentage int
 	// Note: Many specific customer fields left to imagination
 }
 
 type Employee struct {
 	User
-	DiscountPercentage int
 	// Note: Many specific employee fields left to imagination
 }
 
@@ -43,19 +42,46 @@ type Item struct {
 	Name               string
 	Description        string
 	Price              decimal.Decimal
+	// Scheduled price changes ordered by EffectiveFrom, Price applies until the first one
+	PriceChanges []PriceChange
+	// Tax class of the item, the SKU's when empty
+	TaxClass string
+	// Stock is reordered once it drops below ReorderPoint, at least ReorderQuantity at a time.
+	// A zero ReorderPoint leaves the item out of reordering.
+	ReorderPoint    decimal.Decimal
+	ReorderQuantity decimal.Decimal
 	BaseFields
 	SKU
 	ProductGroup
 }
 
+type PriceChange struct {
+	Price         decimal.Decimal
+	EffectiveFrom time.Time
+}
+
+// PriceAt gives the price of an item in effect at t
+func (i *Item) PriceAt(t time.Time) decimal.Decimal {
+	price := i.Price
+	for _, change := range i.PriceChanges {
+		if change.EffectiveFrom.After(t) {
+			break
+		}
+		price = change.Price
+	}
+	return price
+}
+
 type SKU struct {
 	SkuId              uuid.UUID
 	Name               string
 	Description        string
 	DiscountPercentage int
+	// Tax class of items of this SKU that don't set their own, standard when empty
+	TaxClass string
 }
 
-// Unused currently, but can be used to group products at a higher level than SKU
+// Groups products at a higher level than SKU
 type ProductGroup struct {
 	ProductGroupId     uuid.UUID
 	Name               string
@@ -68,29 +94,305 @@ type OrderLineItem struct {
 	Quantity int64
 }
 
+// A discount taken off an order line
+type AppliedDiscount struct {
+	// Who offered it, eg. item, sku, product group or user
+	Source     string
+	Percentage int
+	// Exact amount taken off, before rounding the line
+	Amount decimal.Decimal
+}
+
+// How the net amount of an order line was reached
+type PriceBreakdown struct {
+	ItemId    uuid.UUID
+	UnitPrice decimal.Decimal
+	Quantity  int64
+	// Unit price times quantity
+	Gross     decimal.Decimal
+	Discounts []AppliedDiscount
+	// What rounding to cents added to or took off the discounted amount
+	Rounding decimal.Decimal
+	Net      decimal.Decimal
+	TaxClass string
+	// Tax rate in percent and the tax in Net, or on top of it for tax-exclusive prices
+	TaxRate decimal.Decimal
+	Tax     decimal.Decimal
+}
+
 type Order struct {
-	UserId      uuid.UUID
-	LineItems   []OrderLineItem
+	UserId    uuid.UUID
+	LineItems []OrderLineItem
+	// Sales amount after discounts, without tax
 	NetAmount   decimal.Decimal
 	GrossAmount decimal.Decimal
-	// Tag an order with particular notes. Eg. replenishment order vs purchase order
-	Tag string
+	TaxAmount   decimal.Decimal
+	// What the customer pays, NetAmount plus TaxAmount
+	TotalAmount decimal.Decimal
+	// Prices included tax when the order was placed
+	TaxInclusive bool
+	// One breakdown per priced line, in line order
+	Breakdown []PriceBreakdown
+	// The purchase order a return order gives items back from
+	OriginalOrderId uuid.UUID
+	// The supplier order a replenishment receives stock for
+	SupplierOrderId uuid.UUID
+	// The transfer a transfer order ships or receives stock for, or whose discrepancies an adjustment posts
+	TransferId uuid.UUID
+	// Where the order moved stock
+	LocationId uuid.UUID
+	// What kind of stock movement the order is
+	Type OrderType
+	// Why stock was adjusted or written off, one of the adjustment reasons
+	Reason string
+	// Only read from ledgers written before orders had a Type, which kept the kind of the order here. The
+	// ledger store moves it to Type as it loads them, nothing writes it.
+	Tag string `json:",omitempty"`
 	BaseFields
 }
 
 type LedgerEntry struct {
-	Order   *Order
-	Item    *Item
-	Credit  decimal.Decimal
-	Debit   decimal.Decimal
-	Balance decimal.Decimal
+	// Position in the ledger, numbered from 1 by the store as entries are appended
+	Sequence uint64
+	Order    *Order
+	Item     *Item
+	// Where the stock moved, Balance is the item's balance there
+	LocationId uuid.UUID
+	Credit     decimal.Decimal
+	Debit      decimal.Decimal
+	Balance    decimal.Decimal
+	BaseFields
+}
+
+// A place stock is kept, every location has balances of its own
+type Location struct {
+	Name string
+	// One of the location kinds
+	Kind string
+	BaseFields
+}
+
+// DefaultLocationId is where stock is kept when no location is given. Ledgers written before stock had
+// locations are all there.
+var DefaultLocationId = uuid.NewV5(uuid.NamespaceOID, "toy-store/location/Main store")
+
+// DefaultLocation is the shop every store starts with
+func DefaultLocation() Location {
+	return Location{
+		Name: "Main store",
+		Kind: StoreLocationKind,
+		BaseFields: BaseFields{
+			Id:     DefaultLocationId,
+			Status: EnabledLocationStatus,
+		},
+	}
+}
+
+// A stock take: items are counted on the shelves, possibly over several sessions, and on approval
+// the differences with the ledger are posted as one count-correction adjustment
+type StockCount struct {
+	// Employee who started the count
+	EmployeeId uuid.UUID
+	// Where the stock is counted
+	LocationId uuid.UUID
+	// One line per item counted, a recount replaces the item's line
+	Lines []StockCountLine
+	// Who approved the count and the adjustment order posted for it
+	ApprovedBy        uuid.UUID
+	AdjustmentOrderId uuid.UUID
+	BaseFields
+}
+
+type StockCountLine struct {
+	Item    Item
+	Counted decimal.Decimal
+	// Ledger balance when the item was counted, sales after it don't change the variance
+	Expected decimal.Decimal
+	// Ledger sequence when the item was counted
+	Sequence  uint64
+	CountedAt time.Time
+}
+
+// Variance is the stock found on the shelf but not in the ledger, negative when stock is missing
+func (l StockCountLine) Variance() decimal.Decimal {
+	return l.Counted.Sub(l.Expected)
+}
+
+// A supplier the store buys stock from
+type Supplier struct {
+	Name string
+	// How to reach the supplier, eg. an email address
+	Contact string
+	BaseFields
+}
+
+// An order to a supplier. Stock is only credited as it is received, possibly in several deliveries.
+type SupplierOrder struct {
+	SupplierId uuid.UUID
+	// Employee who drafted the order
+	EmployeeId uuid.UUID
+	// Where the stock is delivered
+	LocationId uuid.UUID
+	// One line per item
+	Lines []SupplierOrderLine
+	// The replenishment orders posted for each delivery
+	ReceiptOrderIds []uuid.UUID
+	BaseFields
+}
+
+type SupplierOrderLine struct {
+	Item     Item
+	Ordered  decimal.Decimal
+	Received decimal.Decimal
+}
+
+// Outstanding is what the supplier still has to deliver of a line
+func (l SupplierOrderLine) Outstanding() decimal.Decimal {
+	return l.Ordered.Sub(l.Received)
+}
+
+// A transfer moves stock between locations: shipping it debits the source, the stock is in transit until the
+// destination receives it and what arrived is credited there
+type Transfer struct {
+	FromLocationId uuid.UUID
+	ToLocationId   uuid.UUID
+	// Employees who shipped and received it
+	ShippedBy  uuid.UUID
+	ReceivedBy uuid.UUID
+	// One line per item shipped
+	Lines []TransferLine
+	// The transfer orders debiting the source and crediting the destination, and the adjustment posted for
+	// what didn't arrive as shipped, if anything
+	ShipmentOrderId   uuid.UUID
+	ReceiptOrderId    uuid.UUID
+	AdjustmentOrderId uuid.UUID
+	BaseFields
+}
+
+type TransferLine struct {
+	Item     Item
+	Shipped  decimal.Decimal
+	Received decimal.Decimal
+}
+
+// Discrepancy is how much more arrived than was shipped, negative when stock went missing on the way
+func (l TransferLine) Discrepancy() decimal.Decimal {
+	return l.Received.Sub(l.Shipped)
+}
+
+// A reservation holds stock at a location for a cart being built, so the stock can't be sold to anybody
+// else until the cart is checked out or the reservation expires
+type Reservation struct {
+	// What the stock is held for, eg. a cart
+	HolderId   uuid.UUID
+	LocationId uuid.UUID
+	Item       Item
+	Quantity   decimal.Decimal
+	// The stock is free again from then on
+	Expires time.Time
+	BaseFields
+}
+
+// A cart is a purchase being built at a register. It can be parked, eg. while the customer fetches their wallet,
+// and resumed at the same register or another one until it is checked out or abandoned. The stock added to it
+// is reserved with the cart as holder.
+type Cart struct {
+	UserId     uuid.UUID
+	LocationId uuid.UUID
+	// Register working on the cart, the one that parked it while it is parked
+	Register string
+	Lines    []CartLine
+	// The purchase order it was checked out as
+	OrderId uuid.UUID
+	BaseFields
+}
+
+type CartLine struct {
+	Item     Item
+	Quantity int64
+}
+
+// A payment records how a customer paid for a purchase order, with one or more tenders. It is saved pending
+// when the order is placed and completed once the tenders cover the order's total. The order in the ledger
+// stays completed, for its stock moved; whether the order is paid is the payment's status.
+type Payment struct {
+	OrderId uuid.UUID
+	UserId  uuid.UUID
+	// The order's total, and how much of it the tenders paid so far
+	Due     decimal.Decimal
+	Paid    decimal.Decimal
+	Tenders []Tender
 	BaseFields
 }
 
+// Outstanding is what is left to pay
+func (p Payment) Outstanding() decimal.Decimal {
+	return p.Due.Sub(p.Paid)
+}
+
+// A tender is money handed over towards an order, in cash, by card or from store credit
+type Tender struct {
+	Type string
+	// What was handed over, only cash can be more than was left to pay
+	Tendered decimal.Decimal
+	// What went towards the order, Tendered less Change
+	Amount decimal.Decimal
+	Change decimal.Decimal
+	// When it was taken
+	Created time.Time
+}
+
 type Inventory struct {
 	Ledger []LedgerEntry
 }
 
+// Tender types
+const (
+	CashTenderType        = "cash"
+	CardTenderType        = "card"
+	StoreCreditTenderType = "store-credit"
+)
+
+// TenderTypes in the order reports list them
+var TenderTypes = []string{CashTenderType, CardTenderType, StoreCreditTenderType}
+
+// Tax classes
+const (
+	StandardTaxClass = "standard"
+	ReducedTaxClass  = "reduced"
+	ExemptTaxClass   = "exempt"
+)
+
+// OrderType tells purchases, replenishments and other stock movements apart
+type OrderType string
+
+const (
+	PurchaseOrderType      OrderType = "purchase"
+	ReplenishmentOrderType OrderType = "replenishment"
+	ReturnOrderType        OrderType = "return"
+	AdjustmentOrderType    OrderType = "adjustment"
+	TransferOrderType      OrderType = "transfer"
+	WriteOffOrderType      OrderType = "write-off"
+)
+
+// OrderTypes in the order reports list them
+var OrderTypes = []OrderType{PurchaseOrderType, ReturnOrderType, ReplenishmentOrderType, AdjustmentOrderType,
+	TransferOrderType, WriteOffOrderType}
+
+// Adjustment reasons, all but count corrections and transfer discrepancies write stock off. Transfer
+// discrepancies are posted when a transfer arrives with more or less than was shipped.
+const (
+	DamageAdjustmentReason              = "damage"
+	TheftAdjustmentReason               = "theft"
+	CountCorrectionAdjustmentReason     = "count-correction"
+	SampleAdjustmentReason              = "sample"
+	TransferDiscrepancyAdjustmentReason = "transfer-discrepancy"
+)
+
+// AdjustmentReasons in the order reports list them
+var AdjustmentReasons = []string{DamageAdjustmentReason, TheftAdjustmentReason, CountCorrectionAdjustmentReason,
+	SampleAdjustmentReason, TransferDiscrepancyAdjustmentReason}
+
 // Order Status
 const (
 	FailedOrderStatus    = "failed"
@@ -104,6 +406,56 @@ const (
 	AbortedLedgerEnryStatus  = "aborted"
 )
 
+// Location kinds
+const (
+	StoreLocationKind     = "store"
+	WarehouseLocationKind = "warehouse"
+	BackRoomLocationKind  = "back-room"
+)
+
+// LocationKinds in the order they are listed
+var LocationKinds = []string{StoreLocationKind, WarehouseLocationKind, BackRoomLocationKind}
+
+// Location Status
+const (
+	EnabledLocationStatus = "enabled"
+)
+
+// Stock count Status
+const (
+	OpenStockCountStatus      = "open"
+	ApprovedStockCountStatus  = "approved"
+	CancelledStockCountStatus = "cancelled"
+)
+
+// Supplier Status
+const (
+	EnabledSupplierStatus = "enabled"
+)
+
+// Supplier order Status
+const (
+	DraftSupplierOrderStatus             = "draft"
+	SentSupplierOrderStatus              = "sent"
+	PartiallyReceivedSupplierOrderStatus = "partially-received"
+	ReceivedSupplierOrderStatus          = "received"
+	CancelledSupplierOrderStatus         = "cancelled"
+)
+
+// Transfer Status
+const (
+	InTransitTransferStatus = "in-transit"
+	ReceivedTransferStatus  = "received"
+)
+
+// Cart Status
+const (
+	OpenCartStatus       = "open"
+	ParkedCartStatus     = "parked"
+	CheckedOutCartStatus = "checked-out"
+	AbandonedCartStatus  = "abandoned"
+)
+
 // User Status
 const (
 	EnabledUserStatus  = "enabled"
@@ -114,32 +466,22 @@ const (
 const (
 	AvailableItemStatus = "available"
 	BlockedItemStatus   = "blocked"
+	RetiredItemStatus   = "retired"
 )
 
-// Let's create a master inventory and admin user when app starts once lazily(singletons)
-var inventorySync sync.Once
-var inventoryInstance *Inventory
-
-func GetMasterInventory() *Inventory {
-	inventorySync.Do(func() {
-		inventoryInstance = &Inventory{
-			Ledger: nil,
-		}
-	})
-	return inventoryInstance
-}
-
+// Let's create an admin user when app starts once lazily(singleton)
 var adminSync sync.Once
 var storeAdmin *User
 
-func GetStoreAdmin() *User {
+// GetStoreAdmin returns the admin, stamped by clk the first time it is asked for
+func GetStoreAdmin(clk clock.Clock) *User {
 	adminSync.Do(func() {
 		storeAdmin = &User{
 			DiscountPercentage: 0,
 			BaseFields: BaseFields{
 				Id:       uuid.NewV4(),
-				Created:  time.Now().UTC(),
-				Modified: time.Now().UTC(),
+				Created:  clk.Now(),
+				Modified: clk.Now(),
 				Status:   EnabledUserStatus,
 			},
 		}
@@ -0,0 +1,57 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"models"
+)
+
+// CartStore keeps carts, open, parked or done with. Implementations must be safe for concurrent use.
+type CartStore interface {
+	// SaveCart creates or replaces the cart with the same id
+	SaveCart(cart models.Cart) error
+	Cart(id uuid.UUID) (models.Cart, bool)
+	// Carts returns every cart, oldest first
+	Carts() []models.Cart
+}
+
+// MemoryCartStore keeps carts in memory, or in a file when opened with OpenFileCartStore
+type MemoryCartStore struct {
+	carts *jsonStore[models.Cart]
+}
+
+// NewMemoryCartStore creates an empty in-memory store
+func NewMemoryCartStore() *MemoryCartStore {
+	return &MemoryCartStore{carts: newJSONStore(
+		func(cart models.Cart) uuid.UUID { return cart.Id },
+		func(a, b models.Cart) bool { return a.Created.Before(b.Created) },
+		copyCart,
+	)}
+}
+
+// OpenFileCartStore loads (or creates) carts kept in a JSON file, so a cart parked before a restart can be
+// resumed after it
+func OpenFileCartStore(path string) (*MemoryCartStore, error) {
+	s := NewMemoryCartStore()
+	if err := s.carts.open(path, "carts", nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MemoryCartStore) SaveCart(cart models.Cart) error {
+	return s.carts.save(cart)
+}
+
+func (s *MemoryCartStore) Cart(id uuid.UUID) (models.Cart, bool) {
+	return s.carts.get(id)
+}
+
+func (s *MemoryCartStore) Carts() []models.Cart {
+	return s.carts.list()
+}
+
+// callers change the lines of the carts they get, don't let that reach the stored ones
+func copyCart(cart models.Cart) models.Cart {
+	cart.Lines = append([]models.CartLine(nil), cart.Lines...)
+	return cart
+}
@@ -0,0 +1,59 @@
+package stores
+
+import (
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+func TestFileCartStore_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "carts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "carts.json")
+
+	s, err := OpenFileCartStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileCartStore() error = %v", err)
+	}
+	item := models.Item{Name: "Test Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	now := time.Now().UTC()
+	parked := models.Cart{UserId: uuid.NewV4(), LocationId: models.DefaultLocationId, Register: "front",
+		Lines:      []models.CartLine{{Item: item, Quantity: 3}},
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Created: now.Add(time.Second), Status: models.ParkedCartStatus}}
+	done := models.Cart{UserId: uuid.NewV4(), LocationId: models.DefaultLocationId, Register: "back",
+		Lines:      []models.CartLine{{Item: item, Quantity: 1}},
+		OrderId:    uuid.NewV4(),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Created: now, Status: models.CheckedOutCartStatus}}
+	for _, cart := range []models.Cart{parked, done} {
+		if err := s.SaveCart(cart); err != nil {
+			t.Fatalf("MemoryCartStore.SaveCart() error = %v", err)
+		}
+	}
+	// changing the lines of a cart got from the store doesn't change the stored one
+	got, _ := s.Cart(parked.Id)
+	got.Lines[0].Quantity = 5
+
+	reopened, err := OpenFileCartStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileCartStore() reopen error = %v", err)
+	}
+	carts := reopened.Carts()
+	if len(carts) != 2 || !uuid.Equal(carts[0].Id, done.Id) || !uuid.Equal(carts[1].Id, parked.Id) {
+		t.Fatalf("MemoryCartStore.Carts() after reopen = %+v, want the checked out cart then the parked one", carts)
+	}
+	if got := carts[1]; got.Status != models.ParkedCartStatus || got.Register != "front" ||
+		len(got.Lines) != 1 || got.Lines[0].Quantity != 3 {
+		t.Errorf("MemoryCartStore.Carts()[1] after reopen = %+v, want 3 parked at front", got)
+	}
+	if got := carts[0]; !uuid.Equal(got.OrderId, done.OrderId) {
+		t.Errorf("MemoryCartStore.Carts()[0] order after reopen = %s, want %s", got.OrderId, done.OrderId)
+	}
+}
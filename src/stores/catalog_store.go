@@ -0,0 +1,210 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"models"
+	"sort"
+	"sync"
+)
+
+// CatalogStore keeps items, SKUs and product groups. Implementations must be safe for concurrent use.
+type CatalogStore interface {
+	// SaveItem creates or replaces the item with the same id
+	SaveItem(item models.Item) error
+	Item(id uuid.UUID) (models.Item, bool)
+	// Items returns every item, sorted by name
+	Items() []models.Item
+
+	// SaveSKU creates or replaces the SKU with the same id
+	SaveSKU(sku models.SKU) error
+	SKU(id uuid.UUID) (models.SKU, bool)
+	SKUs() []models.SKU
+	DeleteSKU(id uuid.UUID) error
+
+	// SaveProductGroup creates or replaces the product group with the same id
+	SaveProductGroup(group models.ProductGroup) error
+	ProductGroup(id uuid.UUID) (models.ProductGroup, bool)
+	ProductGroups() []models.ProductGroup
+	DeleteProductGroup(id uuid.UUID) error
+}
+
+// on-disk and in-memory shape of a catalog
+type catalogSnapshot struct {
+	Items         []models.Item
+	SKUs          []models.SKU
+	ProductGroups []models.ProductGroup
+}
+
+// MemoryCatalogStore keeps the catalog in maps. If persist is set, it is handed the whole
+// catalog after every change and the change is rolled back if it fails.
+type MemoryCatalogStore struct {
+	mu      sync.RWMutex
+	items   map[uuid.UUID]models.Item
+	skus    map[uuid.UUID]models.SKU
+	groups  map[uuid.UUID]models.ProductGroup
+	persist func(catalogSnapshot) error
+}
+
+// NewMemoryCatalogStore creates an empty in-memory catalog
+func NewMemoryCatalogStore() *MemoryCatalogStore {
+	return &MemoryCatalogStore{
+		items:  make(map[uuid.UUID]models.Item),
+		skus:   make(map[uuid.UUID]models.SKU),
+		groups: make(map[uuid.UUID]models.ProductGroup),
+	}
+}
+
+func (s *MemoryCatalogStore) SaveItem(item models.Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.items[item.Id]
+	s.items[item.Id] = item
+	return s.save(func() {
+		if existed {
+			s.items[item.Id] = old
+		} else {
+			delete(s.items, item.Id)
+		}
+	})
+}
+
+func (s *MemoryCatalogStore) Item(id uuid.UUID) (models.Item, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[id]
+	return item, ok
+}
+
+func (s *MemoryCatalogStore) Items() []models.Item {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot().Items
+}
+
+func (s *MemoryCatalogStore) SaveSKU(sku models.SKU) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.skus[sku.SkuId]
+	s.skus[sku.SkuId] = sku
+	return s.save(func() {
+		if existed {
+			s.skus[sku.SkuId] = old
+		} else {
+			delete(s.skus, sku.SkuId)
+		}
+	})
+}
+
+func (s *MemoryCatalogStore) SKU(id uuid.UUID) (models.SKU, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sku, ok := s.skus[id]
+	return sku, ok
+}
+
+func (s *MemoryCatalogStore) SKUs() []models.SKU {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot().SKUs
+}
+
+func (s *MemoryCatalogStore) DeleteSKU(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.skus[id]
+	if !existed {
+		return nil
+	}
+	delete(s.skus, id)
+	return s.save(func() {
+		s.skus[id] = old
+	})
+}
+
+func (s *MemoryCatalogStore) SaveProductGroup(group models.ProductGroup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.groups[group.ProductGroupId]
+	s.groups[group.ProductGroupId] = group
+	return s.save(func() {
+		if existed {
+			s.groups[group.ProductGroupId] = old
+		} else {
+			delete(s.groups, group.ProductGroupId)
+		}
+	})
+}
+
+func (s *MemoryCatalogStore) ProductGroup(id uuid.UUID) (models.ProductGroup, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	group, ok := s.groups[id]
+	return group, ok
+}
+
+func (s *MemoryCatalogStore) ProductGroups() []models.ProductGroup {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot().ProductGroups
+}
+
+func (s *MemoryCatalogStore) DeleteProductGroup(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.groups[id]
+	if !existed {
+		return nil
+	}
+	delete(s.groups, id)
+	return s.save(func() {
+		s.groups[id] = old
+	})
+}
+
+// hand the catalog to persist, undoing the change if that fails. Callers hold the write lock.
+func (s *MemoryCatalogStore) save(undo func()) error {
+	if s.persist == nil {
+		return nil
+	}
+	if err := s.persist(s.snapshot()); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+// everything sorted by name. Callers hold a lock.
+func (s *MemoryCatalogStore) snapshot() catalogSnapshot {
+	var snapshot catalogSnapshot
+	for _, item := range s.items {
+		snapshot.Items = append(snapshot.Items, item)
+	}
+	for _, sku := range s.skus {
+		snapshot.SKUs = append(snapshot.SKUs, sku)
+	}
+	for _, group := range s.groups {
+		snapshot.ProductGroups = append(snapshot.ProductGroups, group)
+	}
+	sort.Slice(snapshot.Items, func(i, j int) bool {
+		return snapshot.Items[i].Name < snapshot.Items[j].Name
+	})
+	sort.Slice(snapshot.SKUs, func(i, j int) bool {
+		return snapshot.SKUs[i].Name < snapshot.SKUs[j].Name
+	})
+	sort.Slice(snapshot.ProductGroups, func(i, j int) bool {
+		return snapshot.ProductGroups[i].Name < snapshot.ProductGroups[j].Name
+	})
+	return snapshot
+}
+
+func (s *MemoryCatalogStore) load(snapshot catalogSnapshot) {
+	for _, item := range snapshot.Items {
+		s.items[item.Id] = item
+	}
+	for _, sku := range snapshot.SKUs {
+		s.skus[sku.SkuId] = sku
+	}
+	for _, group := range snapshot.ProductGroups {
+		s.groups[group.ProductGroupId] = group
+	}
+}
@@ -0,0 +1,18 @@
+package stores
+
+// OpenFileCatalogStore loads (or creates) a catalog kept in a JSON file.
+// The catalog is small and rarely changes, so the whole file is rewritten on every change.
+func OpenFileCatalogStore(path string) (*MemoryCatalogStore, error) {
+	s := NewMemoryCatalogStore()
+
+	var snapshot catalogSnapshot
+	if err := readJSONFile(path, "catalog", &snapshot); err != nil {
+		return nil, err
+	}
+	s.load(snapshot)
+
+	s.persist = func(snapshot catalogSnapshot) error {
+		return writeJSONFile(path, snapshot)
+	}
+	return s, nil
+}
@@ -0,0 +1,337 @@
+package stores
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"io"
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotFileName = "ledger.snapshot"
+	logFilePrefix    = "ledger."
+	logFileSuffix    = ".log"
+
+	// fold the log into a fresh snapshot once it has this many entries
+	DefaultSnapshotEvery = 1000
+)
+
+// on-disk snapshot: every entry written before log Generation was started
+type ledgerSnapshot struct {
+	Generation int
+	Entries    []models.LedgerEntry
+}
+
+// FileLedgerStore keeps the ledger in a directory as an append-only log plus a snapshot.
+// Each Append is written as JSON lines and synced before returning. Once the log grows
+// past SnapshotEvery entries, the whole ledger is written to a new snapshot and a new
+// empty log generation is started, so reopening never replays more than one log.
+type FileLedgerStore struct {
+	SnapshotEvery int
+
+	mu         sync.RWMutex
+	dir        string
+	generation int
+	log        *os.File
+	logEntries int
+	inventory  models.Inventory
+	index      *ledgerIndex
+}
+
+// OpenFileLedgerStore loads (or creates) the ledger kept in dir
+func OpenFileLedgerStore(dir string) (*FileLedgerStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &FileLedgerStore{
+		SnapshotEvery: DefaultSnapshotEvery,
+		dir:           dir,
+		index:         newLedgerIndex(),
+	}
+
+	snapshot, err := readSnapshot(filepath.Join(dir, snapshotFileName))
+	if err != nil {
+		return nil, err
+	}
+	s.generation = snapshot.Generation
+
+	entries, size, err := readLog(s.logPath(s.generation))
+	if err != nil {
+		return nil, err
+	}
+	// ledgers written before entries had sequence numbers get them now, in the order they were appended
+	loaded := append(snapshot.Entries, entries...)
+	stampEntries(nil, loaded)
+	typeOrders(loaded)
+	s.inventory.Ledger = loaded
+	s.logEntries = len(entries)
+	s.index.add(s.inventory.Ledger, 0)
+
+	s.log, err = os.OpenFile(s.logPath(s.generation), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// drop a torn tail so the next append starts on a fresh line
+	if err := s.log.Truncate(size); err != nil {
+		s.log.Close()
+		return nil, err
+	}
+
+	// a crash between writing a snapshot and removing the old log leaves it behind
+	s.removeStaleLogs()
+
+	return s, nil
+}
+
+func (s *FileLedgerStore) Append(entries ...models.LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return fmt.Errorf("ledger store %s is closed", s.dir)
+	}
+
+	// encode everything before touching the file so a bad entry can't leave half an append
+	stampEntries(s.inventory.Ledger, entries)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	if _, err := s.log.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+
+	from := len(s.inventory.Ledger)
+	s.inventory.Ledger = append(s.inventory.Ledger, entries...)
+	s.logEntries += len(entries)
+	s.index.add(s.inventory.Ledger, from)
+
+	if s.SnapshotEvery > 0 && s.logEntries >= s.SnapshotEvery {
+		return s.snapshot()
+	}
+	return nil
+}
+
+func (s *FileLedgerStore) Entries() []models.LedgerEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyLedger(s.inventory.Ledger)
+}
+
+func (s *FileLedgerStore) Sequence() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return uint64(len(s.inventory.Ledger))
+}
+
+func (s *FileLedgerStore) SequenceAt(t time.Time) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sequenceAt(s.inventory.Ledger, t)
+}
+
+func (s *FileLedgerStore) Balance(locationId uuid.UUID, itemId uuid.UUID) decimal.Decimal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.balance(locationId, itemId)
+}
+
+func (s *FileLedgerStore) BalancesAsOf(sequence uint64) map[uuid.UUID]decimal.Decimal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.balancesAsOf(s.inventory.Ledger, sequence)
+}
+
+func (s *FileLedgerStore) LocationBalancesAsOf(locationId uuid.UUID, sequence uint64) map[uuid.UUID]decimal.Decimal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.locationBalancesAsOf(s.inventory.Ledger, locationId, sequence)
+}
+
+func (s *FileLedgerStore) EntriesBetween(after, through uint64) []models.LedgerEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return entriesBetween(s.inventory.Ledger, after, through)
+}
+
+// Snapshot folds the current log into the snapshot file
+func (s *FileLedgerStore) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+// Close snapshots the ledger and releases the log file
+func (s *FileLedgerStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+	err := s.snapshot()
+	if cerr := s.log.Close(); err == nil {
+		err = cerr
+	}
+	s.log = nil
+	return err
+}
+
+func (s *FileLedgerStore) snapshot() error {
+	next := s.generation + 1
+
+	// start the next log first so the snapshot never points at a missing file
+	log, err := os.OpenFile(s.logPath(next), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	snapshot := ledgerSnapshot{
+		Generation: next,
+		Entries:    s.inventory.Ledger,
+	}
+	if err := writeSnapshot(filepath.Join(s.dir, snapshotFileName), snapshot); err != nil {
+		log.Close()
+		os.Remove(s.logPath(next))
+		return err
+	}
+
+	old := s.logPath(s.generation)
+	s.log.Close()
+	s.log = log
+	s.generation = next
+	s.logEntries = 0
+	os.Remove(old)
+
+	return nil
+}
+
+func (s *FileLedgerStore) logPath(generation int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%d%s", logFilePrefix, generation, logFileSuffix))
+}
+
+func (s *FileLedgerStore) removeStaleLogs() {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	current := filepath.Base(s.logPath(s.generation))
+	for _, f := range files {
+		name := f.Name()
+		if strings.HasPrefix(name, logFilePrefix) && strings.HasSuffix(name, logFileSuffix) && name != current {
+			os.Remove(filepath.Join(s.dir, name))
+		}
+	}
+}
+
+func readSnapshot(path string) (ledgerSnapshot, error) {
+	var snapshot ledgerSnapshot
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("corrupt ledger snapshot %s: %s", path, err)
+	}
+	return snapshot, nil
+}
+
+// write to a temp file and rename over the old snapshot so readers see either one or the other
+func writeSnapshot(path string, snapshot ledgerSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// orders written before they had a type kept their kind in the tag, which used the same names. The tag is
+// dropped from every order, the type is the only kind an order has.
+func typeOrders(entries []models.LedgerEntry) {
+	for _, entry := range entries {
+		if entry.Order == nil {
+			continue
+		}
+		if entry.Order.Type == "" {
+			entry.Order.Type = models.OrderType(entry.Order.Tag)
+		}
+		entry.Order.Tag = ""
+	}
+}
+
+// returns the complete entries in the log and the number of bytes they take up
+func readLog(path string) ([]models.LedgerEntry, int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var entries []models.LedgerEntry
+	var size int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// a line without its newline is a torn write from a crash, that append never returned
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var entry models.LedgerEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, 0, fmt.Errorf("corrupt ledger log %s: %s", path, err)
+		}
+		entries = append(entries, entry)
+		size += int64(len(line))
+	}
+	return entries, size, nil
+}
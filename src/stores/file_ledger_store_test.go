@@ -0,0 +1,184 @@
+package stores
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func testLedgerEntry(item *models.Item, credit int64, balance int64) models.LedgerEntry {
+	return models.LedgerEntry{
+		Order: &models.Order{
+			BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.CompletedOrderStatus},
+		},
+		Item:    item,
+		Credit:  decimal.New(credit, 0),
+		Debit:   decimal.Zero,
+		Balance: decimal.New(balance, 0),
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  time.Now().UTC(),
+			Modified: time.Now().UTC(),
+			Status:   models.CreatedLedgerEntryStatus,
+		},
+	}
+}
+
+func TestFileLedgerStore_Reopen(t *testing.T) {
+	item := &models.Item{Name: "Test Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+
+	tests := []struct {
+		name          string
+		snapshotEvery int
+		appends       int
+		tornTail      bool
+	}{
+		{name: "Test log only", snapshotEvery: 0, appends: 5},
+		{name: "Test snapshot and log", snapshotEvery: 3, appends: 7},
+		{name: "Test torn log tail", snapshotEvery: 0, appends: 4, tornTail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ledger")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			s, err := OpenFileLedgerStore(dir)
+			if err != nil {
+				t.Fatalf("OpenFileLedgerStore() error = %v", err)
+			}
+			s.SnapshotEvery = tt.snapshotEvery
+			for n := 1; n <= tt.appends; n++ {
+				if err := s.Append(testLedgerEntry(item, 1, int64(n))); err != nil {
+					t.Fatalf("FileLedgerStore.Append() error = %v", err)
+				}
+			}
+			// simulate a crash: leave the files as they are
+			s.log.Close()
+
+			if tt.tornTail {
+				f, _ := os.OpenFile(s.logPath(s.generation), os.O_WRONLY|os.O_APPEND, 0644)
+				f.WriteString(`{"Credit":"1","Bal`)
+				f.Close()
+			}
+
+			reopened, err := OpenFileLedgerStore(dir)
+			if err != nil {
+				t.Fatalf("OpenFileLedgerStore() reopen error = %v", err)
+			}
+			defer reopened.Close()
+
+			entries := reopened.Entries()
+			if len(entries) != tt.appends {
+				t.Fatalf("FileLedgerStore.Entries() = %d entries, want %d", len(entries), tt.appends)
+			}
+			for n, entry := range entries {
+				if !entry.Balance.Equal(decimal.New(int64(n+1), 0)) || !uuid.Equal(entry.Item.Id, item.Id) {
+					t.Errorf("FileLedgerStore.Entries()[%d] = %v, want balance %d", n, entry.Balance, n+1)
+				}
+				if entry.Sequence != uint64(n+1) {
+					t.Errorf("FileLedgerStore.Entries()[%d] sequence = %d, want %d", n, entry.Sequence, n+1)
+				}
+			}
+
+			if got := reopened.Balance(models.DefaultLocationId, item.Id); !got.Equal(decimal.New(int64(tt.appends), 0)) {
+				t.Errorf("FileLedgerStore.Balance() after reopen = %s, want %d", got, tt.appends)
+			}
+
+			// the store keeps working after a reopen
+			if err := reopened.Append(testLedgerEntry(item, 1, int64(tt.appends+1))); err != nil {
+				t.Fatalf("FileLedgerStore.Append() after reopen error = %v", err)
+			}
+			if got := reopened.Balance(models.DefaultLocationId, item.Id); !got.Equal(decimal.New(int64(tt.appends+1), 0)) {
+				t.Errorf("FileLedgerStore.Balance() after append = %s, want %d", got, tt.appends+1)
+			}
+			logs, _ := filepath.Glob(filepath.Join(dir, logFilePrefix+"*"+logFileSuffix))
+			if len(logs) != 1 {
+				t.Errorf("ledger dir has %d logs, want 1", len(logs))
+			}
+		})
+	}
+}
+
+func TestFileLedgerStore_LegacyOrderTags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	item := &models.Item{Name: "Test Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	s, err := OpenFileLedgerStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileLedgerStore() error = %v", err)
+	}
+	// written before orders had a type
+	legacy := testLedgerEntry(item, 5, 5)
+	legacy.Order.Tag = "replenishment"
+	typed := testLedgerEntry(item, 1, 6)
+	typed.Order.Type = models.ReturnOrderType
+	typed.Order.Tag = "damaged box"
+	if err := s.Append(legacy, typed); err != nil {
+		t.Fatalf("FileLedgerStore.Append() error = %v", err)
+	}
+	s.Close()
+
+	reopened, err := OpenFileLedgerStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileLedgerStore() reopen error = %v", err)
+	}
+	defer reopened.Close()
+	entries := reopened.Entries()
+	if entries[0].Order.Type != models.ReplenishmentOrderType || entries[0].Order.Tag != "" {
+		t.Errorf("FileLedgerStore.Entries()[0] order = %s/%q, want the tag moved to the type", entries[0].Order.Type,
+			entries[0].Order.Tag)
+	}
+	if entries[1].Order.Type != models.ReturnOrderType || entries[1].Order.Tag != "" {
+		t.Errorf("FileLedgerStore.Entries()[1] order = %s/%q, want its type and no tag", entries[1].Order.Type,
+			entries[1].Order.Tag)
+	}
+}
+
+func TestFileLedgerStore_LegacyLocations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a log line written before entries had a location
+	item := &models.Item{Name: "Test Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	data, _ := json.Marshal(testLedgerEntry(item, 5, 5))
+	var legacy map[string]interface{}
+	json.Unmarshal(data, &legacy)
+	delete(legacy, "LocationId")
+	delete(legacy["Order"].(map[string]interface{}), "LocationId")
+	data, _ = json.Marshal(legacy)
+	if err := ioutil.WriteFile(filepath.Join(dir, "ledger.0.log"), append(data, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenFileLedgerStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileLedgerStore() error = %v", err)
+	}
+	defer s.Close()
+	entry := s.Entries()[0]
+	if !uuid.Equal(entry.LocationId, models.DefaultLocationId) ||
+		!uuid.Equal(entry.Order.LocationId, models.DefaultLocationId) {
+		t.Errorf("FileLedgerStore.Entries()[0] at %s, order at %s, want both at the default location",
+			entry.LocationId, entry.Order.LocationId)
+	}
+	if got := s.Balance(models.DefaultLocationId, item.Id); !got.Equal(decimal.New(5, 0)) {
+		t.Errorf("FileLedgerStore.Balance() at the default location = %s, want 5", got)
+	}
+}
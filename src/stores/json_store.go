@@ -0,0 +1,143 @@
+package stores
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/satori/go.uuid"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// jsonStore keeps values of one kind in a map by id, for the stores of records that are few and change one
+// at a time. If persist is set, it is handed every value after a change and the change is rolled back if it
+// fails. open backs a store with a JSON file, rewritten on every change like the catalog.
+type jsonStore[T any] struct {
+	mu     sync.RWMutex
+	values map[uuid.UUID]T
+	// what a value is stored by
+	key func(T) uuid.UUID
+	// the order values are listed and written in
+	less func(a, b T) bool
+	// copies the slices of a value, so callers changing what they saved or got don't change the store.
+	// Nil for values without any.
+	clone   func(T) T
+	persist func([]T) error
+}
+
+func newJSONStore[T any](key func(T) uuid.UUID, less func(a, b T) bool, clone func(T) T) *jsonStore[T] {
+	if clone == nil {
+		clone = func(value T) T { return value }
+	}
+	return &jsonStore[T]{values: make(map[uuid.UUID]T), key: key, less: less, clone: clone}
+}
+
+// open loads the values kept in the JSON file at path, if there is one, and writes every change to it.
+// upgrade, if set, fixes values written by older versions as they are loaded.
+func (s *jsonStore[T]) open(path string, what string, upgrade func(T) T) error {
+	var values []T
+	if err := readJSONFile(path, what, &values); err != nil {
+		return err
+	}
+	for _, value := range values {
+		if upgrade != nil {
+			value = upgrade(value)
+		}
+		s.values[s.key(value)] = value
+	}
+	s.persist = func(values []T) error {
+		return writeJSONFile(path, values)
+	}
+	return nil
+}
+
+// save creates or replaces the value with the same key
+func (s *jsonStore[T]) save(value T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.key(value)
+	old, existed := s.values[id]
+	s.values[id] = s.clone(value)
+	if err := s.write(); err != nil {
+		if existed {
+			s.values[id] = old
+		} else {
+			delete(s.values, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// delete drops a value, dropping one that isn't there is fine
+func (s *jsonStore[T]) delete(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.values[id]
+	if !existed {
+		return nil
+	}
+	delete(s.values, id)
+	if err := s.write(); err != nil {
+		s.values[id] = old
+		return err
+	}
+	return nil
+}
+
+func (s *jsonStore[T]) get(id uuid.UUID) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[id]
+	return s.clone(value), ok
+}
+
+func (s *jsonStore[T]) list() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sorted()
+}
+
+// hand every value to persist, if set. Callers hold the write lock.
+func (s *jsonStore[T]) write() error {
+	if s.persist == nil {
+		return nil
+	}
+	return s.persist(s.sorted())
+}
+
+// copies of every value in order. Callers hold a lock.
+func (s *jsonStore[T]) sorted() []T {
+	values := make([]T, 0, len(s.values))
+	for _, value := range s.values {
+		values = append(values, s.clone(value))
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return s.less(values[i], values[j])
+	})
+	return values
+}
+
+// read the JSON file at path into v, a missing file leaves v alone
+func readJSONFile(path string, what string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("corrupt %s %s: %s", what, path, err)
+	}
+	return nil
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
@@ -0,0 +1,90 @@
+package stores
+
+import (
+	"fmt"
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+func TestJSONStore(t *testing.T) {
+	newStore := func() *jsonStore[models.Cart] {
+		return newJSONStore(
+			func(cart models.Cart) uuid.UUID { return cart.Id },
+			func(a, b models.Cart) bool { return a.Created.Before(b.Created) },
+			copyCart,
+		)
+	}
+	item := models.Item{Name: "Test Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+
+	tests := []struct {
+		name string
+		// done to the store after a cart of 3 was saved, the store must still have 3 after it
+		change func(s *jsonStore[models.Cart], saved models.Cart)
+	}{
+		{name: "Test changing what was saved", change: func(s *jsonStore[models.Cart], saved models.Cart) {
+			saved.Lines[0].Quantity = 100
+		}},
+		{name: "Test changing what was got", change: func(s *jsonStore[models.Cart], saved models.Cart) {
+			got, _ := s.get(saved.Id)
+			got.Lines[0].Quantity = 100
+		}},
+		{name: "Test changing what was listed", change: func(s *jsonStore[models.Cart], saved models.Cart) {
+			s.list()[0].Lines[0].Quantity = 100
+		}},
+		{name: "Test a failed write", change: func(s *jsonStore[models.Cart], saved models.Cart) {
+			persist := s.persist
+			s.persist = func([]models.Cart) error { return fmt.Errorf("disk full") }
+			defer func() { s.persist = persist }()
+			changed := copyCart(saved)
+			changed.Lines[0].Quantity = 100
+			if err := s.save(changed); err == nil {
+				t.Errorf("jsonStore.save() with a failing write succeeded")
+			}
+			added := copyCart(saved)
+			added.Id = uuid.NewV4()
+			if err := s.save(added); err == nil {
+				t.Errorf("jsonStore.save() of a new cart with a failing write succeeded")
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "store")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "carts.json")
+
+			s := newStore()
+			if err := s.open(path, "carts", nil); err != nil {
+				t.Fatalf("jsonStore.open() error = %v", err)
+			}
+			cart := models.Cart{
+				Lines:      []models.CartLine{{Item: item, Quantity: 3}},
+				BaseFields: models.BaseFields{Id: uuid.NewV4(), Created: time.Now().UTC()},
+			}
+			if err := s.save(cart); err != nil {
+				t.Fatalf("jsonStore.save() error = %v", err)
+			}
+			tt.change(s, cart)
+
+			reopened := newStore()
+			if err := reopened.open(path, "carts", nil); err != nil {
+				t.Fatalf("jsonStore.open() reopen error = %v", err)
+			}
+			for what, store := range map[string]*jsonStore[models.Cart]{"in memory": s, "reopened": reopened} {
+				carts := store.list()
+				if len(carts) != 1 || !uuid.Equal(carts[0].Id, cart.Id) || carts[0].Lines[0].Quantity != 3 {
+					t.Errorf("jsonStore.list() %s = %+v, want just the cart of 3", what, carts)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"sort"
+	"time"
+)
+
+// Number entries about to be appended after ledger and keep their Created time from going
+// backwards, so the ledger is ordered by both and a point in time is a prefix of it.
+// Entries and orders without a location are at the default location.
+func stampEntries(ledger []models.LedgerEntry, entries []models.LedgerEntry) {
+	var last time.Time
+	if len(ledger) > 0 {
+		last = ledger[len(ledger)-1].Created
+	}
+	for n := range entries {
+		entries[n].Sequence = uint64(len(ledger) + n + 1)
+		if entries[n].Created.Before(last) {
+			entries[n].Created = last
+		}
+		last = entries[n].Created
+		if uuid.Equal(entries[n].LocationId, uuid.Nil) {
+			entries[n].LocationId = models.DefaultLocationId
+		}
+		if entries[n].Order != nil && uuid.Equal(entries[n].Order.LocationId, uuid.Nil) {
+			entries[n].Order.LocationId = models.DefaultLocationId
+		}
+	}
+}
+
+// balances are kept per item per location
+type stockKey struct {
+	locationId uuid.UUID
+	itemId     uuid.UUID
+}
+
+// ledgerIndex is kept up to date as entries are appended so lookups don't scan the ledger:
+// the current balance of every item at every location and the positions of their entries.
+// Entry n of the ledger has sequence number n+1. Callers synchronize access.
+type ledgerIndex struct {
+	balances map[stockKey]decimal.Decimal
+	// positions of the entries that moved stock of an item at a location, in sequence order
+	itemEntries map[stockKey][]int
+}
+
+func newLedgerIndex() *ledgerIndex {
+	return &ledgerIndex{
+		balances:    make(map[stockKey]decimal.Decimal),
+		itemEntries: make(map[stockKey][]int),
+	}
+}
+
+// index ledger[from:], which were just appended
+func (x *ledgerIndex) add(ledger []models.LedgerEntry, from int) {
+	for n := from; n < len(ledger); n++ {
+		entry := &ledger[n]
+		if entry.Status == models.AbortedLedgerEnryStatus || entry.Item == nil {
+			continue
+		}
+		key := stockKey{locationId: entry.LocationId, itemId: entry.Item.Id}
+		x.balances[key] = entry.Balance
+		x.itemEntries[key] = append(x.itemEntries[key], n)
+	}
+}
+
+func (x *ledgerIndex) balance(locationId uuid.UUID, itemId uuid.UUID) decimal.Decimal {
+	balance, ok := x.balances[stockKey{locationId: locationId, itemId: itemId}]
+	if !ok {
+		return decimal.Zero
+	}
+	return balance
+}
+
+// balance of every item after the entries up to and including sequence, summed across locations
+func (x *ledgerIndex) balancesAsOf(ledger []models.LedgerEntry, sequence uint64) map[uuid.UUID]decimal.Decimal {
+	balances := make(map[uuid.UUID]decimal.Decimal)
+	for key, positions := range x.itemEntries {
+		if balance, ok := balanceAsOf(ledger, positions, sequence); ok {
+			balances[key.itemId] = balances[key.itemId].Add(balance)
+		}
+	}
+	return balances
+}
+
+// balance of every item at a location after the entries up to and including sequence
+func (x *ledgerIndex) locationBalancesAsOf(ledger []models.LedgerEntry, locationId uuid.UUID,
+	sequence uint64) map[uuid.UUID]decimal.Decimal {
+	balances := make(map[uuid.UUID]decimal.Decimal)
+	for key, positions := range x.itemEntries {
+		if !uuid.Equal(key.locationId, locationId) {
+			continue
+		}
+		if balance, ok := balanceAsOf(ledger, positions, sequence); ok {
+			balances[key.itemId] = balance
+		}
+	}
+	return balances
+}
+
+// balance after the last of the entries at positions up to and including sequence, false if there is none
+func balanceAsOf(ledger []models.LedgerEntry, positions []int, sequence uint64) (decimal.Decimal, bool) {
+	n := sort.Search(len(positions), func(i int) bool {
+		return uint64(positions[i]) >= sequence
+	})
+	if n == 0 {
+		return decimal.Zero, false
+	}
+	return ledger[positions[n-1]].Balance, true
+}
+
+// sequence of the last entry created at or before t, 0 if there is none
+func sequenceAt(ledger []models.LedgerEntry, t time.Time) uint64 {
+	return uint64(sort.Search(len(ledger), func(i int) bool {
+		return ledger[i].Created.After(t)
+	}))
+}
+
+// a copy of the entries after sequence after, up to and including sequence through
+func entriesBetween(ledger []models.LedgerEntry, after, through uint64) []models.LedgerEntry {
+	if through > uint64(len(ledger)) {
+		through = uint64(len(ledger))
+	}
+	if after >= through {
+		return nil
+	}
+	return copyLedger(ledger[after:through])
+}
@@ -0,0 +1,111 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"sync"
+	"time"
+)
+
+// LedgerStore keeps the inventory ledger. Implementations must be safe for concurrent use.
+//
+// Entries are numbered with a sequence as they are appended and their Created time never goes
+// backwards along it, so every as-of query is a prefix of the ledger: a point in time is first
+// turned into the sequence of the last entry created by then.
+type LedgerStore interface {
+	// Append adds entries to the end of the ledger, all or nothing. It numbers them with the next
+	// sequence numbers and moves a Created time earlier than the previous entry's up to it.
+	// Entries without a location are put at the default location.
+	Append(entries ...models.LedgerEntry) error
+	// Entries returns a copy of the ledger in sequence order
+	Entries() []models.LedgerEntry
+	// Sequence returns the sequence of the last entry, 0 for an empty ledger
+	Sequence() uint64
+	// SequenceAt returns the sequence of the last entry created at or before t, 0 if there is none
+	SequenceAt(t time.Time) uint64
+	// Balance returns the balance of the last entry appended for an item at a location that wasn't aborted,
+	// zero if none
+	Balance(locationId uuid.UUID, itemId uuid.UUID) decimal.Decimal
+	// BalancesAsOf returns the balance of every item summed across locations after the entries up to and
+	// including sequence, aborted entries left out
+	BalancesAsOf(sequence uint64) map[uuid.UUID]decimal.Decimal
+	// LocationBalancesAsOf is BalancesAsOf for the stock at one location
+	LocationBalancesAsOf(locationId uuid.UUID, sequence uint64) map[uuid.UUID]decimal.Decimal
+	// EntriesBetween returns a copy of the entries after sequence after, up to and including sequence through
+	EntriesBetween(after, through uint64) []models.LedgerEntry
+}
+
+// MemoryLedgerStore keeps the ledger in a slice, it is lost when the process exits
+type MemoryLedgerStore struct {
+	mu        sync.RWMutex
+	inventory models.Inventory
+	index     *ledgerIndex
+}
+
+// NewMemoryLedgerStore creates an empty in-memory ledger
+func NewMemoryLedgerStore() *MemoryLedgerStore {
+	return &MemoryLedgerStore{index: newLedgerIndex()}
+}
+
+func (s *MemoryLedgerStore) Append(entries ...models.LedgerEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from := len(s.inventory.Ledger)
+	stampEntries(s.inventory.Ledger, entries)
+	s.inventory.Ledger = append(s.inventory.Ledger, entries...)
+	s.index.add(s.inventory.Ledger, from)
+	return nil
+}
+
+func (s *MemoryLedgerStore) Entries() []models.LedgerEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyLedger(s.inventory.Ledger)
+}
+
+func (s *MemoryLedgerStore) Sequence() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return uint64(len(s.inventory.Ledger))
+}
+
+func (s *MemoryLedgerStore) SequenceAt(t time.Time) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sequenceAt(s.inventory.Ledger, t)
+}
+
+func (s *MemoryLedgerStore) Balance(locationId uuid.UUID, itemId uuid.UUID) decimal.Decimal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.balance(locationId, itemId)
+}
+
+func (s *MemoryLedgerStore) BalancesAsOf(sequence uint64) map[uuid.UUID]decimal.Decimal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.balancesAsOf(s.inventory.Ledger, sequence)
+}
+
+func (s *MemoryLedgerStore) LocationBalancesAsOf(locationId uuid.UUID, sequence uint64) map[uuid.UUID]decimal.Decimal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.locationBalancesAsOf(s.inventory.Ledger, locationId, sequence)
+}
+
+func (s *MemoryLedgerStore) EntriesBetween(after, through uint64) []models.LedgerEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return entriesBetween(s.inventory.Ledger, after, through)
+}
+
+// copy so callers can sort/filter without touching the store
+func copyLedger(ledger []models.LedgerEntry) []models.LedgerEntry {
+	if ledger == nil {
+		return nil
+	}
+	entries := make([]models.LedgerEntry, len(ledger))
+	copy(entries, ledger)
+	return entries
+}
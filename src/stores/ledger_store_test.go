@@ -0,0 +1,209 @@
+package stores
+
+import (
+	"models"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestMemoryLedgerStore_Index(t *testing.T) {
+	s := NewMemoryLedgerStore()
+	first := &models.Item{Name: "First", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	second := &models.Item{Name: "Second", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	start := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+	at := func(entry models.LedgerEntry, minutes int) models.LedgerEntry {
+		entry.Created = start.Add(time.Duration(minutes) * time.Minute)
+		entry.Modified = entry.Created
+		return entry
+	}
+	aborted := at(testLedgerEntry(first, 0, 99), 30)
+	aborted.Status = models.AbortedLedgerEnryStatus
+
+	s.Append(at(testLedgerEntry(first, 5, 5), 0), at(testLedgerEntry(second, 2, 2), 10))
+	s.Append(at(testLedgerEntry(first, 3, 8), 30), aborted)
+	// a clock running late can't put an entry before the ones already appended
+	s.Append(at(testLedgerEntry(second, 1, 3), 20))
+	// entries created in the same instant are told apart by their sequence
+	s.Append(at(testLedgerEntry(first, 1, 9), 40), at(testLedgerEntry(first, 1, 10), 40))
+
+	if got := s.Sequence(); got != 7 {
+		t.Errorf("MemoryLedgerStore.Sequence() = %d, want 7", got)
+	}
+	entries := s.Entries()
+	for n, entry := range entries {
+		if entry.Sequence != uint64(n+1) || (n > 0 && entry.Created.Before(entries[n-1].Created)) {
+			t.Errorf("MemoryLedgerStore.Entries()[%d] = sequence %d created %s, want %d in time order", n,
+				entry.Sequence, entry.Created, n+1)
+		}
+	}
+
+	if got := s.Balance(models.DefaultLocationId, first.Id); !got.Equal(decimal.New(10, 0)) {
+		t.Errorf("MemoryLedgerStore.Balance() = %s, want 10", got)
+	}
+	if got := s.Balance(models.DefaultLocationId, uuid.NewV4()); !got.Equal(decimal.Zero) {
+		t.Errorf("MemoryLedgerStore.Balance() of an unknown item = %s, want 0", got)
+	}
+
+	tests := []struct {
+		name          string
+		at            time.Time
+		wantSequence  uint64
+		first, second int64
+	}{
+		{name: "before any entry", at: start.Add(-time.Minute), wantSequence: 0},
+		{name: "first entry", at: start, wantSequence: 1, first: 5},
+		{name: "between entries", at: start.Add(25 * time.Minute), wantSequence: 2, first: 5, second: 2},
+		{name: "late entry moved up", at: start.Add(30 * time.Minute), wantSequence: 5, first: 8, second: 3},
+		{name: "after everything", at: start.Add(time.Hour), wantSequence: 7, first: 10, second: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sequence := s.SequenceAt(tt.at)
+			if sequence != tt.wantSequence {
+				t.Errorf("MemoryLedgerStore.SequenceAt() = %d, want %d", sequence, tt.wantSequence)
+			}
+			balances := s.BalancesAsOf(sequence)
+			if !balances[first.Id].Equal(decimal.New(tt.first, 0)) || !balances[second.Id].Equal(decimal.New(tt.second, 0)) {
+				t.Errorf("MemoryLedgerStore.BalancesAsOf(%d) = %v, want first %d second %d", sequence, balances,
+					tt.first, tt.second)
+			}
+		})
+	}
+
+	if got := s.BalancesAsOf(6)[first.Id]; !got.Equal(decimal.New(9, 0)) {
+		t.Errorf("MemoryLedgerStore.BalancesAsOf(6) first = %s, want 9", got)
+	}
+	between := s.EntriesBetween(1, 3)
+	if len(between) != 2 || between[0].Sequence != 2 || between[1].Sequence != 3 {
+		t.Errorf("MemoryLedgerStore.EntriesBetween(1, 3) = %d entries, want sequences 2 and 3", len(between))
+	}
+	if got := s.EntriesBetween(7, 100); len(got) != 0 {
+		t.Errorf("MemoryLedgerStore.EntriesBetween(7, 100) = %d entries, want none", len(got))
+	}
+}
+
+func TestMemoryLedgerStore_Locations(t *testing.T) {
+	s := NewMemoryLedgerStore()
+	item := &models.Item{Name: "Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	warehouse := uuid.NewV4()
+	inWarehouse := func(entry models.LedgerEntry) models.LedgerEntry {
+		entry.LocationId = warehouse
+		return entry
+	}
+
+	// entries without a location are at the default one
+	s.Append(testLedgerEntry(item, 5, 5), inWarehouse(testLedgerEntry(item, 20, 20)))
+	s.Append(testLedgerEntry(item, 1, 6))
+	s.Append(inWarehouse(testLedgerEntry(item, 4, 24)))
+
+	if got := s.Entries()[0].LocationId; !uuid.Equal(got, models.DefaultLocationId) {
+		t.Errorf("MemoryLedgerStore.Entries()[0] location = %s, want the default", got)
+	}
+	if got := s.Balance(models.DefaultLocationId, item.Id); !got.Equal(decimal.New(6, 0)) {
+		t.Errorf("MemoryLedgerStore.Balance() at the default location = %s, want 6", got)
+	}
+	if got := s.Balance(warehouse, item.Id); !got.Equal(decimal.New(24, 0)) {
+		t.Errorf("MemoryLedgerStore.Balance() in the warehouse = %s, want 24", got)
+	}
+	if got := s.BalancesAsOf(3)[item.Id]; !got.Equal(decimal.New(26, 0)) {
+		t.Errorf("MemoryLedgerStore.BalancesAsOf(3) = %s, want 26 across locations", got)
+	}
+	if got := s.LocationBalancesAsOf(warehouse, 3)[item.Id]; !got.Equal(decimal.New(20, 0)) {
+		t.Errorf("MemoryLedgerStore.LocationBalancesAsOf(3) in the warehouse = %s, want 20", got)
+	}
+	if got := s.LocationBalancesAsOf(uuid.NewV4(), 4); len(got) != 0 {
+		t.Errorf("MemoryLedgerStore.LocationBalancesAsOf() of an empty location = %v, want nothing", got)
+	}
+}
+
+// a ledger of a million entries over a thousand items, an entry a second
+const benchLedgerSize = 1000000
+
+var (
+	benchOnce  sync.Once
+	benchStore *MemoryLedgerStore
+	benchItems []*models.Item
+	benchStart = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func benchLedger() *MemoryLedgerStore {
+	benchOnce.Do(func() {
+		benchStore = NewMemoryLedgerStore()
+		for n := 0; n < 1000; n++ {
+			benchItems = append(benchItems, &models.Item{BaseFields: models.BaseFields{Id: uuid.NewV4()}})
+		}
+		order := &models.Order{BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+		for n := 0; n < benchLedgerSize; n++ {
+			at := benchStart.Add(time.Duration(n) * time.Second)
+			benchStore.Append(models.LedgerEntry{
+				Order:      order,
+				Item:       benchItems[n%len(benchItems)],
+				Credit:     decimal.New(1, 0),
+				Balance:    decimal.New(int64(n/len(benchItems)+1), 0),
+				BaseFields: models.BaseFields{Created: at, Modified: at, Status: models.CreatedLedgerEntryStatus},
+			})
+		}
+	})
+	return benchStore
+}
+
+// how balances were found before the index: sort a copy of the ledger and scan it
+func scanBalance(s LedgerStore, itemId uuid.UUID) decimal.Decimal {
+	ledger := s.Entries()
+	sort.Slice(ledger, func(i, j int) bool {
+		return ledger[i].Created.After(ledger[j].Created)
+	})
+	for _, entry := range ledger {
+		if uuid.Equal(entry.Item.Id, itemId) {
+			return entry.Balance
+		}
+	}
+	return decimal.Zero
+}
+
+func BenchmarkLedgerBalance_Scan(b *testing.B) {
+	s := benchLedger()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		scanBalance(s, benchItems[n%len(benchItems)].Id)
+	}
+}
+
+func BenchmarkLedgerBalance_Index(b *testing.B) {
+	s := benchLedger()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s.Balance(models.DefaultLocationId, benchItems[n%len(benchItems)].Id)
+	}
+}
+
+// an hour of entries out of the million
+func BenchmarkLedgerRange_Scan(b *testing.B) {
+	s := benchLedger()
+	from := benchStart.Add(500000 * time.Second)
+	till := from.Add(time.Hour)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var entries []models.LedgerEntry
+		for _, entry := range s.Entries() {
+			if entry.Created.After(from) && !entry.Created.After(till) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+}
+
+func BenchmarkLedgerRange_Index(b *testing.B) {
+	s := benchLedger()
+	from := benchStart.Add(500000 * time.Second)
+	till := from.Add(time.Hour)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s.EntriesBetween(s.SequenceAt(from), s.SequenceAt(till))
+	}
+}
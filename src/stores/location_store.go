@@ -0,0 +1,53 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"models"
+)
+
+// LocationStore keeps the places stock is kept, it always has the default location.
+// Implementations must be safe for concurrent use.
+type LocationStore interface {
+	// SaveLocation creates or replaces the location with the same id
+	SaveLocation(location models.Location) error
+	Location(id uuid.UUID) (models.Location, bool)
+	// Locations returns every location sorted by name
+	Locations() []models.Location
+}
+
+// MemoryLocationStore keeps locations in memory, or in a file when opened with OpenFileLocationStore
+type MemoryLocationStore struct {
+	locations *jsonStore[models.Location]
+}
+
+// NewMemoryLocationStore creates an in-memory store with just the default location
+func NewMemoryLocationStore() *MemoryLocationStore {
+	s := &MemoryLocationStore{locations: newJSONStore(
+		func(location models.Location) uuid.UUID { return location.Id },
+		func(a, b models.Location) bool { return a.Name < b.Name },
+		nil,
+	)}
+	s.locations.save(models.DefaultLocation())
+	return s
+}
+
+// OpenFileLocationStore loads (or creates) locations kept in a JSON file
+func OpenFileLocationStore(path string) (*MemoryLocationStore, error) {
+	s := NewMemoryLocationStore()
+	if err := s.locations.open(path, "locations", nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MemoryLocationStore) SaveLocation(location models.Location) error {
+	return s.locations.save(location)
+}
+
+func (s *MemoryLocationStore) Location(id uuid.UUID) (models.Location, bool) {
+	return s.locations.get(id)
+}
+
+func (s *MemoryLocationStore) Locations() []models.Location {
+	return s.locations.list()
+}
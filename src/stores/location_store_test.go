@@ -0,0 +1,49 @@
+package stores
+
+import (
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/satori/go.uuid"
+)
+
+func TestFileLocationStore_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "locations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "locations.json")
+
+	s, err := OpenFileLocationStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileLocationStore() error = %v", err)
+	}
+	if _, ok := s.Location(models.DefaultLocationId); !ok {
+		t.Fatalf("MemoryLocationStore.Location() of a new store has no default location")
+	}
+	warehouse := models.Location{Name: "Depot", Kind: models.WarehouseLocationKind,
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.EnabledLocationStatus}}
+	if err := s.SaveLocation(warehouse); err != nil {
+		t.Fatalf("MemoryLocationStore.SaveLocation() error = %v", err)
+	}
+	// the default location is kept like any other once it is changed
+	shop := models.DefaultLocation()
+	shop.Name = "High Street"
+	if err := s.SaveLocation(shop); err != nil {
+		t.Fatalf("MemoryLocationStore.SaveLocation() error = %v", err)
+	}
+
+	reopened, err := OpenFileLocationStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileLocationStore() reopen error = %v", err)
+	}
+	locations := reopened.Locations()
+	if len(locations) != 2 || locations[0].Name != "Depot" || locations[0].Kind != models.WarehouseLocationKind ||
+		locations[1].Name != "High Street" || !uuid.Equal(locations[1].Id, models.DefaultLocationId) {
+		t.Errorf("MemoryLocationStore.Locations() after reopen = %+v, want the Depot and the renamed default", locations)
+	}
+}
@@ -0,0 +1,110 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"models"
+	"sort"
+	"sync"
+)
+
+// PaymentStore keeps the payments of purchase orders, one per order. Implementations must be safe for
+// concurrent use.
+type PaymentStore interface {
+	// SavePayment creates or replaces the payment of the same order
+	SavePayment(payment models.Payment) error
+	// DeletePayment drops the payment of an order, dropping one that isn't there is fine
+	DeletePayment(orderId uuid.UUID) error
+	// Payment looks the payment of an order up
+	Payment(orderId uuid.UUID) (models.Payment, bool)
+	// Payments returns every payment, oldest first
+	Payments() []models.Payment
+	// Pending returns the payments still pending, oldest first
+	Pending() []models.Payment
+}
+
+// MemoryPaymentStore keeps payments in memory by order id, or in a file when opened with OpenFilePaymentStore
+type MemoryPaymentStore struct {
+	payments *jsonStore[models.Payment]
+	// the order ids of the pending payments, changed along with them
+	mu      sync.Mutex
+	pending map[uuid.UUID]bool
+}
+
+// NewMemoryPaymentStore creates an empty in-memory store
+func NewMemoryPaymentStore() *MemoryPaymentStore {
+	return &MemoryPaymentStore{
+		payments: newJSONStore(
+			func(payment models.Payment) uuid.UUID { return payment.OrderId },
+			func(a, b models.Payment) bool { return a.Created.Before(b.Created) },
+			copyPayment,
+		),
+		pending: make(map[uuid.UUID]bool),
+	}
+}
+
+// OpenFilePaymentStore loads (or creates) payments kept in a JSON file
+func OpenFilePaymentStore(path string) (*MemoryPaymentStore, error) {
+	s := NewMemoryPaymentStore()
+	if err := s.payments.open(path, "payments", nil); err != nil {
+		return nil, err
+	}
+	for _, payment := range s.payments.list() {
+		if payment.Status == models.PendingOrderStatus {
+			s.pending[payment.OrderId] = true
+		}
+	}
+	return s, nil
+}
+
+func (s *MemoryPaymentStore) SavePayment(payment models.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.payments.save(payment); err != nil {
+		return err
+	}
+	if payment.Status == models.PendingOrderStatus {
+		s.pending[payment.OrderId] = true
+	} else {
+		delete(s.pending, payment.OrderId)
+	}
+	return nil
+}
+
+func (s *MemoryPaymentStore) DeletePayment(orderId uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.payments.delete(orderId); err != nil {
+		return err
+	}
+	delete(s.pending, orderId)
+	return nil
+}
+
+func (s *MemoryPaymentStore) Payment(orderId uuid.UUID) (models.Payment, bool) {
+	return s.payments.get(orderId)
+}
+
+func (s *MemoryPaymentStore) Payments() []models.Payment {
+	return s.payments.list()
+}
+
+func (s *MemoryPaymentStore) Pending() []models.Payment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make([]models.Payment, 0, len(s.pending))
+	for orderId := range s.pending {
+		if payment, ok := s.payments.get(orderId); ok {
+			pending = append(pending, payment)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Created.Before(pending[j].Created)
+	})
+	return pending
+}
+
+// callers change the tenders of the payments they get, don't let that reach the stored ones
+func copyPayment(payment models.Payment) models.Payment {
+	payment.Tenders = append([]models.Tender(nil), payment.Tenders...)
+	return payment
+}
@@ -0,0 +1,76 @@
+package stores
+
+import (
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestFilePaymentStore_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "payments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "payments.json")
+
+	s, err := OpenFilePaymentStore(path)
+	if err != nil {
+		t.Fatalf("OpenFilePaymentStore() error = %v", err)
+	}
+	now := time.Now().UTC()
+	payment := func(created time.Time, status string, paid int64) models.Payment {
+		return models.Payment{OrderId: uuid.NewV4(), UserId: uuid.NewV4(), Due: decimal.New(10, 0),
+			Paid:       decimal.New(paid, 0),
+			BaseFields: models.BaseFields{Id: uuid.NewV4(), Created: created, Modified: created, Status: status}}
+	}
+	paid := payment(now, models.CompletedOrderStatus, 10)
+	paid.Tenders = []models.Tender{{Type: models.CashTenderType, Tendered: decimal.New(20, 0),
+		Amount: decimal.New(10, 0), Change: decimal.New(10, 0), Created: now}}
+	partly := payment(now.Add(time.Second), models.PendingOrderStatus, 4)
+	partly.Tenders = []models.Tender{{Type: models.CardTenderType, Tendered: decimal.New(4, 0),
+		Amount: decimal.New(4, 0), Change: decimal.Zero, Created: now}}
+	unpaid := payment(now.Add(2*time.Second), models.PendingOrderStatus, 0)
+	// the payment of an order that was never placed
+	dropped := payment(now.Add(3*time.Second), models.PendingOrderStatus, 0)
+	for _, p := range []models.Payment{paid, partly, unpaid, dropped} {
+		if err := s.SavePayment(p); err != nil {
+			t.Fatalf("MemoryPaymentStore.SavePayment() error = %v", err)
+		}
+	}
+	if err := s.DeletePayment(dropped.OrderId); err != nil {
+		t.Fatalf("MemoryPaymentStore.DeletePayment() error = %v", err)
+	}
+	// paid in full after being saved pending, it is no longer pending
+	unpaid.Paid = unpaid.Due
+	unpaid.Status = models.CompletedOrderStatus
+	if err := s.SavePayment(unpaid); err != nil {
+		t.Fatalf("MemoryPaymentStore.SavePayment() error = %v", err)
+	}
+
+	reopened, err := OpenFilePaymentStore(path)
+	if err != nil {
+		t.Fatalf("OpenFilePaymentStore() reopen error = %v", err)
+	}
+	if payments := reopened.Payments(); len(payments) != 3 {
+		t.Errorf("MemoryPaymentStore.Payments() after reopen = %+v, want 3", payments)
+	}
+	// the pending index is rebuilt from the payments read back
+	if pending := reopened.Pending(); len(pending) != 1 || !uuid.Equal(pending[0].OrderId, partly.OrderId) {
+		t.Errorf("MemoryPaymentStore.Pending() after reopen = %+v, want the partly paid order only", pending)
+	}
+	got, ok := reopened.Payment(paid.OrderId)
+	if !ok || len(got.Tenders) != 1 || !got.Tenders[0].Change.Equal(decimal.New(10, 0)) ||
+		got.Outstanding().Sign() != 0 {
+		t.Errorf("MemoryPaymentStore.Payment() after reopen = %+v, want it paid in cash with 10 change", got)
+	}
+	if got, ok := reopened.Payment(partly.OrderId); !ok || !got.Outstanding().Equal(decimal.New(6, 0)) {
+		t.Errorf("MemoryPaymentStore.Payment() after reopen = %+v, want 6 left to pay", got)
+	}
+}
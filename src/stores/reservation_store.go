@@ -0,0 +1,53 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"models"
+)
+
+// ReservationStore keeps the stock held for carts, live or expired. Implementations must be safe for
+// concurrent use.
+type ReservationStore interface {
+	// SaveReservation creates or replaces the reservation with the same id
+	SaveReservation(reservation models.Reservation) error
+	// DeleteReservation drops a reservation, dropping one that isn't there is fine
+	DeleteReservation(id uuid.UUID) error
+	// Reservations returns every reservation, oldest first
+	Reservations() []models.Reservation
+}
+
+// MemoryReservationStore keeps reservations in memory, or in a file when opened with OpenFileReservationStore
+type MemoryReservationStore struct {
+	reservations *jsonStore[models.Reservation]
+}
+
+// NewMemoryReservationStore creates an empty in-memory store
+func NewMemoryReservationStore() *MemoryReservationStore {
+	return &MemoryReservationStore{reservations: newJSONStore(
+		func(reservation models.Reservation) uuid.UUID { return reservation.Id },
+		func(a, b models.Reservation) bool { return a.Created.Before(b.Created) },
+		nil,
+	)}
+}
+
+// OpenFileReservationStore loads (or creates) reservations kept in a JSON file, so registers sharing the data
+// directory see each other's holds when they start
+func OpenFileReservationStore(path string) (*MemoryReservationStore, error) {
+	s := NewMemoryReservationStore()
+	if err := s.reservations.open(path, "reservations", nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MemoryReservationStore) SaveReservation(reservation models.Reservation) error {
+	return s.reservations.save(reservation)
+}
+
+func (s *MemoryReservationStore) DeleteReservation(id uuid.UUID) error {
+	return s.reservations.delete(id)
+}
+
+func (s *MemoryReservationStore) Reservations() []models.Reservation {
+	return s.reservations.list()
+}
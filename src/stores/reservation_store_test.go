@@ -0,0 +1,58 @@
+package stores
+
+import (
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestFileReservationStore_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reservations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reservations.json")
+
+	s, err := OpenFileReservationStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileReservationStore() error = %v", err)
+	}
+	item := models.Item{Name: "Test Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	now := time.Now().UTC()
+	hold := func(created time.Time) models.Reservation {
+		return models.Reservation{HolderId: uuid.NewV4(), LocationId: models.DefaultLocationId, Item: item,
+			Quantity: decimal.New(2, 0), Expires: created.Add(15 * time.Minute),
+			BaseFields: models.BaseFields{Id: uuid.NewV4(), Created: created}}
+	}
+	kept, released := hold(now), hold(now.Add(time.Second))
+	for _, reservation := range []models.Reservation{kept, released} {
+		if err := s.SaveReservation(reservation); err != nil {
+			t.Fatalf("MemoryReservationStore.SaveReservation() error = %v", err)
+		}
+	}
+	// a released hold stays released for the registers starting after it
+	if err := s.DeleteReservation(released.Id); err != nil {
+		t.Fatalf("MemoryReservationStore.DeleteReservation() error = %v", err)
+	}
+
+	reopened, err := OpenFileReservationStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileReservationStore() reopen error = %v", err)
+	}
+	reservations := reopened.Reservations()
+	if len(reservations) != 1 || !uuid.Equal(reservations[0].Id, kept.Id) {
+		t.Fatalf("MemoryReservationStore.Reservations() after reopen = %+v, want the kept hold only", reservations)
+	}
+	got := reservations[0]
+	if !uuid.Equal(got.HolderId, kept.HolderId) || !got.Quantity.Equal(decimal.New(2, 0)) ||
+		!got.Expires.Equal(kept.Expires) {
+		t.Errorf("MemoryReservationStore.Reservations()[0] after reopen = %+v, want 2 held until %s", got, kept.Expires)
+	}
+}
@@ -0,0 +1,63 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"models"
+)
+
+// StockCountStore keeps stock takes, open or done. Implementations must be safe for concurrent use.
+type StockCountStore interface {
+	// SaveCount creates or replaces the count with the same id
+	SaveCount(count models.StockCount) error
+	Count(id uuid.UUID) (models.StockCount, bool)
+	// Counts returns every count, oldest first
+	Counts() []models.StockCount
+}
+
+// MemoryStockCountStore keeps counts in memory, or in a file when opened with OpenFileStockCountStore
+type MemoryStockCountStore struct {
+	counts *jsonStore[models.StockCount]
+}
+
+// NewMemoryStockCountStore creates an empty in-memory store
+func NewMemoryStockCountStore() *MemoryStockCountStore {
+	return &MemoryStockCountStore{counts: newJSONStore(
+		func(count models.StockCount) uuid.UUID { return count.Id },
+		func(a, b models.StockCount) bool { return a.Created.Before(b.Created) },
+		copyCount,
+	)}
+}
+
+// OpenFileStockCountStore loads (or creates) counts kept in a JSON file
+func OpenFileStockCountStore(path string) (*MemoryStockCountStore, error) {
+	s := NewMemoryStockCountStore()
+	err := s.counts.open(path, "stock counts", func(count models.StockCount) models.StockCount {
+		// counts from before stock had locations were of the default location
+		if uuid.Equal(count.LocationId, uuid.Nil) {
+			count.LocationId = models.DefaultLocationId
+		}
+		return count
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MemoryStockCountStore) SaveCount(count models.StockCount) error {
+	return s.counts.save(count)
+}
+
+func (s *MemoryStockCountStore) Count(id uuid.UUID) (models.StockCount, bool) {
+	return s.counts.get(id)
+}
+
+func (s *MemoryStockCountStore) Counts() []models.StockCount {
+	return s.counts.list()
+}
+
+// callers append to the lines of the counts they get, don't let that reach the stored ones
+func copyCount(count models.StockCount) models.StockCount {
+	count.Lines = append([]models.StockCountLine(nil), count.Lines...)
+	return count
+}
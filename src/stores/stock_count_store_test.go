@@ -0,0 +1,68 @@
+package stores
+
+import (
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestFileStockCountStore_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "counts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "counts.json")
+
+	s, err := OpenFileStockCountStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStockCountStore() error = %v", err)
+	}
+	item := models.Item{Name: "Test Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	now := time.Now().UTC()
+	count := models.StockCount{
+		EmployeeId: uuid.NewV4(),
+		LocationId: uuid.NewV4(),
+		Lines: []models.StockCountLine{{Item: item, Counted: decimal.New(3, 0), Expected: decimal.New(4, 0),
+			Sequence: 7}},
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Created: now, Status: models.OpenStockCountStatus},
+	}
+	// written before stock had locations
+	legacy := models.StockCount{
+		EmployeeId: uuid.NewV4(),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Created: now.Add(time.Second),
+			Status: models.ApprovedStockCountStatus},
+	}
+	for _, c := range []models.StockCount{count, legacy} {
+		if err := s.SaveCount(c); err != nil {
+			t.Fatalf("MemoryStockCountStore.SaveCount() error = %v", err)
+		}
+	}
+
+	reopened, err := OpenFileStockCountStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStockCountStore() reopen error = %v", err)
+	}
+	counts := reopened.Counts()
+	if len(counts) != 2 || !uuid.Equal(counts[0].Id, count.Id) || !uuid.Equal(counts[1].Id, legacy.Id) {
+		t.Fatalf("MemoryStockCountStore.Counts() after reopen = %+v, want the saved counts, oldest first", counts)
+	}
+	if !uuid.Equal(counts[0].LocationId, count.LocationId) || len(counts[0].Lines) != 1 {
+		t.Errorf("MemoryStockCountStore.Counts()[0] after reopen = %+v, want it as saved", counts[0])
+	}
+	line := counts[0].Lines[0]
+	if !line.Counted.Equal(decimal.New(3, 0)) || !line.Variance().Equal(decimal.New(-1, 0)) || line.Sequence != 7 {
+		t.Errorf("MemoryStockCountStore.Counts() line after reopen = %+v, want 3 counted of 4", line)
+	}
+	if got, ok := reopened.Count(legacy.Id); !ok || !uuid.Equal(got.LocationId, models.DefaultLocationId) ||
+		got.Status != models.ApprovedStockCountStatus {
+		t.Errorf("MemoryStockCountStore.Count() of a count without a location = %+v, want it at the default location",
+			got)
+	}
+}
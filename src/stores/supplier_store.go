@@ -0,0 +1,116 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"models"
+	"sync"
+)
+
+// SupplierStore keeps suppliers and the orders sent to them. Implementations must be safe for concurrent use.
+type SupplierStore interface {
+	// SaveSupplier creates or replaces the supplier with the same id
+	SaveSupplier(supplier models.Supplier) error
+	Supplier(id uuid.UUID) (models.Supplier, bool)
+	// Suppliers returns every supplier sorted by name
+	Suppliers() []models.Supplier
+	// SaveOrder creates or replaces the order with the same id
+	SaveOrder(order models.SupplierOrder) error
+	Order(id uuid.UUID) (models.SupplierOrder, bool)
+	// Orders returns every order, oldest first
+	Orders() []models.SupplierOrder
+}
+
+// MemorySupplierStore keeps suppliers and their orders in memory, or in a file when opened with
+// OpenFileSupplierStore
+type MemorySupplierStore struct {
+	// suppliers and orders share the file, saving either writes both, one save at a time
+	mu        sync.Mutex
+	suppliers *jsonStore[models.Supplier]
+	orders    *jsonStore[models.SupplierOrder]
+}
+
+// what the file store writes
+type supplierFile struct {
+	Suppliers []models.Supplier
+	Orders    []models.SupplierOrder
+}
+
+// NewMemorySupplierStore creates an empty in-memory store
+func NewMemorySupplierStore() *MemorySupplierStore {
+	return &MemorySupplierStore{
+		suppliers: newJSONStore(
+			func(supplier models.Supplier) uuid.UUID { return supplier.Id },
+			func(a, b models.Supplier) bool { return a.Name < b.Name },
+			nil,
+		),
+		orders: newJSONStore(
+			func(order models.SupplierOrder) uuid.UUID { return order.Id },
+			func(a, b models.SupplierOrder) bool { return a.Created.Before(b.Created) },
+			copySupplierOrder,
+		),
+	}
+}
+
+// OpenFileSupplierStore loads (or creates) suppliers and their orders kept in a JSON file, rewritten on every
+// change like the catalog
+func OpenFileSupplierStore(path string) (*MemorySupplierStore, error) {
+	s := NewMemorySupplierStore()
+
+	var file supplierFile
+	if err := readJSONFile(path, "suppliers", &file); err != nil {
+		return nil, err
+	}
+	for _, supplier := range file.Suppliers {
+		s.suppliers.values[supplier.Id] = supplier
+	}
+	for _, order := range file.Orders {
+		// orders from before stock had locations were delivered to the default location
+		if uuid.Equal(order.LocationId, uuid.Nil) {
+			order.LocationId = models.DefaultLocationId
+		}
+		s.orders.values[order.Id] = order
+	}
+
+	s.suppliers.persist = func(suppliers []models.Supplier) error {
+		return writeJSONFile(path, supplierFile{Suppliers: suppliers, Orders: s.orders.list()})
+	}
+	s.orders.persist = func(orders []models.SupplierOrder) error {
+		return writeJSONFile(path, supplierFile{Suppliers: s.suppliers.list(), Orders: orders})
+	}
+	return s, nil
+}
+
+func (s *MemorySupplierStore) SaveSupplier(supplier models.Supplier) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.suppliers.save(supplier)
+}
+
+func (s *MemorySupplierStore) Supplier(id uuid.UUID) (models.Supplier, bool) {
+	return s.suppliers.get(id)
+}
+
+func (s *MemorySupplierStore) Suppliers() []models.Supplier {
+	return s.suppliers.list()
+}
+
+func (s *MemorySupplierStore) SaveOrder(order models.SupplierOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.orders.save(order)
+}
+
+func (s *MemorySupplierStore) Order(id uuid.UUID) (models.SupplierOrder, bool) {
+	return s.orders.get(id)
+}
+
+func (s *MemorySupplierStore) Orders() []models.SupplierOrder {
+	return s.orders.list()
+}
+
+// callers change the lines of the orders they get, don't let that reach the stored ones
+func copySupplierOrder(order models.SupplierOrder) models.SupplierOrder {
+	order.Lines = append([]models.SupplierOrderLine(nil), order.Lines...)
+	order.ReceiptOrderIds = append([]uuid.UUID(nil), order.ReceiptOrderIds...)
+	return order
+}
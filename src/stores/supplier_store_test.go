@@ -0,0 +1,65 @@
+package stores
+
+import (
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestFileSupplierStore_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "suppliers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "suppliers.json")
+
+	s, err := OpenFileSupplierStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileSupplierStore() error = %v", err)
+	}
+	toys := models.Supplier{Name: "Toys Ltd", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	if err := s.SaveSupplier(toys); err != nil {
+		t.Fatalf("MemorySupplierStore.SaveSupplier() error = %v", err)
+	}
+	item := models.Item{Name: "Test Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	// written before stock had locations
+	order := models.SupplierOrder{
+		SupplierId:      toys.Id,
+		Lines:           []models.SupplierOrderLine{{Item: item, Ordered: decimal.New(10, 0), Received: decimal.New(4, 0)}},
+		ReceiptOrderIds: []uuid.UUID{uuid.NewV4()},
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Created: time.Now().UTC(),
+			Status: models.PartiallyReceivedSupplierOrderStatus},
+	}
+	if err := s.SaveOrder(order); err != nil {
+		t.Fatalf("MemorySupplierStore.SaveOrder() error = %v", err)
+	}
+	// suppliers and orders share the file, saving a supplier keeps the orders
+	bricks := models.Supplier{Name: "Bricks Inc", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	if err := s.SaveSupplier(bricks); err != nil {
+		t.Fatalf("MemorySupplierStore.SaveSupplier() error = %v", err)
+	}
+
+	reopened, err := OpenFileSupplierStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileSupplierStore() reopen error = %v", err)
+	}
+	if suppliers := reopened.Suppliers(); len(suppliers) != 2 || suppliers[0].Name != "Bricks Inc" ||
+		suppliers[1].Name != "Toys Ltd" {
+		t.Errorf("MemorySupplierStore.Suppliers() after reopen = %+v, want Bricks Inc and Toys Ltd", suppliers)
+	}
+	got, ok := reopened.Order(order.Id)
+	if !ok || !uuid.Equal(got.SupplierId, toys.Id) || !got.Lines[0].Outstanding().Equal(decimal.New(6, 0)) ||
+		len(got.ReceiptOrderIds) != 1 || !uuid.Equal(got.ReceiptOrderIds[0], order.ReceiptOrderIds[0]) {
+		t.Errorf("MemorySupplierStore.Order() after reopen = %+v, want 6 of 10 outstanding after one receipt", got)
+	}
+	if !uuid.Equal(got.LocationId, models.DefaultLocationId) {
+		t.Errorf("MemorySupplierStore.Order() location after reopen = %s, want the default location", got.LocationId)
+	}
+}
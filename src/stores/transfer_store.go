@@ -0,0 +1,63 @@
+package stores
+
+import (
+	"github.com/satori/go.uuid"
+	"models"
+)
+
+// TransferStore keeps stock transfers between locations, in transit or received. Implementations must be
+// safe for concurrent use.
+type TransferStore interface {
+	// SaveTransfer creates or replaces the transfer with the same id
+	SaveTransfer(transfer models.Transfer) error
+	// DeleteTransfer drops a transfer, dropping one that isn't there is fine
+	DeleteTransfer(id uuid.UUID) error
+	Transfer(id uuid.UUID) (models.Transfer, bool)
+	// Transfers returns every transfer, oldest first
+	Transfers() []models.Transfer
+}
+
+// MemoryTransferStore keeps transfers in memory, or in a file when opened with OpenFileTransferStore
+type MemoryTransferStore struct {
+	transfers *jsonStore[models.Transfer]
+}
+
+// NewMemoryTransferStore creates an empty in-memory store
+func NewMemoryTransferStore() *MemoryTransferStore {
+	return &MemoryTransferStore{transfers: newJSONStore(
+		func(transfer models.Transfer) uuid.UUID { return transfer.Id },
+		func(a, b models.Transfer) bool { return a.Created.Before(b.Created) },
+		copyTransfer,
+	)}
+}
+
+// OpenFileTransferStore loads (or creates) transfers kept in a JSON file
+func OpenFileTransferStore(path string) (*MemoryTransferStore, error) {
+	s := NewMemoryTransferStore()
+	if err := s.transfers.open(path, "transfers", nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MemoryTransferStore) SaveTransfer(transfer models.Transfer) error {
+	return s.transfers.save(transfer)
+}
+
+func (s *MemoryTransferStore) DeleteTransfer(id uuid.UUID) error {
+	return s.transfers.delete(id)
+}
+
+func (s *MemoryTransferStore) Transfer(id uuid.UUID) (models.Transfer, bool) {
+	return s.transfers.get(id)
+}
+
+func (s *MemoryTransferStore) Transfers() []models.Transfer {
+	return s.transfers.list()
+}
+
+// callers change the lines of the transfers they get, don't let that reach the stored ones
+func copyTransfer(transfer models.Transfer) models.Transfer {
+	transfer.Lines = append([]models.TransferLine(nil), transfer.Lines...)
+	return transfer
+}
@@ -0,0 +1,65 @@
+package stores
+
+import (
+	"io/ioutil"
+	"models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestFileTransferStore_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transfers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "transfers.json")
+
+	s, err := OpenFileTransferStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileTransferStore() error = %v", err)
+	}
+	item := models.Item{Name: "Test Item", BaseFields: models.BaseFields{Id: uuid.NewV4()}}
+	now := time.Now().UTC()
+	received := models.Transfer{
+		FromLocationId:    uuid.NewV4(),
+		ToLocationId:      models.DefaultLocationId,
+		Lines:             []models.TransferLine{{Item: item, Shipped: decimal.New(5, 0), Received: decimal.New(4, 0)}},
+		ShipmentOrderId:   uuid.NewV4(),
+		ReceiptOrderId:    uuid.NewV4(),
+		AdjustmentOrderId: uuid.NewV4(),
+		BaseFields:        models.BaseFields{Id: uuid.NewV4(), Created: now, Status: models.ReceivedTransferStatus},
+	}
+	// a shipment that was rolled back
+	dropped := models.Transfer{
+		Lines:      []models.TransferLine{{Item: item, Shipped: decimal.New(2, 0), Received: decimal.Zero}},
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Created: now, Status: models.InTransitTransferStatus},
+	}
+	for _, transfer := range []models.Transfer{received, dropped} {
+		if err := s.SaveTransfer(transfer); err != nil {
+			t.Fatalf("MemoryTransferStore.SaveTransfer() error = %v", err)
+		}
+	}
+	if err := s.DeleteTransfer(dropped.Id); err != nil {
+		t.Fatalf("MemoryTransferStore.DeleteTransfer() error = %v", err)
+	}
+
+	reopened, err := OpenFileTransferStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileTransferStore() reopen error = %v", err)
+	}
+	transfers := reopened.Transfers()
+	if len(transfers) != 1 || !uuid.Equal(transfers[0].Id, received.Id) {
+		t.Fatalf("MemoryTransferStore.Transfers() after reopen = %+v, want the received transfer only", transfers)
+	}
+	got := transfers[0]
+	if !got.Lines[0].Discrepancy().Equal(decimal.New(-1, 0)) || !uuid.Equal(got.ReceiptOrderId, received.ReceiptOrderId) ||
+		!uuid.Equal(got.AdjustmentOrderId, received.AdjustmentOrderId) {
+		t.Errorf("MemoryTransferStore.Transfers()[0] after reopen = %+v, want one short with its orders", got)
+	}
+}
@@ -0,0 +1,174 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"time"
+)
+
+// Adjust corrects the stock of an item outside of sales and replenishments, a positive quantity adds stock and
+// a negative one takes it out. Every adjustment needs a reason and the employee making it. Count corrections
+// can go either way and are adjustment orders, stock lost to damage, theft or samples is a write-off. Stock
+// reserved for carts can't be taken out.
+func (i *InventoryUsecaseRepository) Adjust(locationId uuid.UUID, item models.Item, quantity decimal.Decimal,
+	reason string, employeeId uuid.UUID) (models.Order, error) {
+	// check input
+	if uuid.Equal(item.Id, uuid.Nil) || uuid.Equal(employeeId, uuid.Nil) {
+		return models.Order{}, errors.NewError(errors.AdjustmentError, "Empty item/employee given")
+	}
+	if quantity.Sign() == 0 {
+		return models.Order{}, errors.NewError(errors.AdjustmentError, "Quantity of "+item.Name+" can't be zero")
+	}
+	orderType := models.WriteOffOrderType
+	switch reason {
+	case models.CountCorrectionAdjustmentReason:
+		orderType = models.AdjustmentOrderType
+	case models.DamageAdjustmentReason, models.TheftAdjustmentReason, models.SampleAdjustmentReason:
+		if quantity.Sign() > 0 {
+			return models.Order{}, errors.NewError(errors.AdjustmentError, reason+" can only take stock out")
+		}
+	case models.TransferDiscrepancyAdjustmentReason:
+		return models.Order{}, errors.NewError(errors.AdjustmentError, reason+" is posted by receiving a transfer")
+	default:
+		return models.Order{}, errors.NewError(errors.AdjustmentError, "unknown reason "+reason)
+	}
+	if err := i.checkLocation(locationId); err != nil {
+		return models.Order{}, err
+	}
+
+	order := models.Order{
+		UserId:      employeeId,
+		NetAmount:   decimal.Zero,
+		GrossAmount: decimal.Zero,
+		Type:        orderType,
+		Reason:      reason,
+		LocationId:  locationId,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  i.clock.Now(),
+			Modified: i.clock.Now(),
+			Status:   models.CompletedOrderStatus,
+		},
+	}
+
+	unlock := i.locks.lock(item.Id)
+	defer unlock()
+
+	entry, err := i.adjustmentEntry(&order, item, quantity)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if err := i.ledger.Append(entry); err != nil {
+		return models.Order{}, errors.NewError(errors.AdjustmentError, err.Error())
+	}
+
+	// we are done
+	return order, nil
+}
+
+// The entry moving quantity of item in or out at the order's location for an adjustment order. Stock reserved
+// for carts can't be taken out. Callers hold the item's lock.
+func (i *InventoryUsecaseRepository) adjustmentEntry(order *models.Order, item models.Item,
+	quantity decimal.Decimal) (models.LedgerEntry, error) {
+	balance := i.findItemBalanceInLedger(order.LocationId, item).Add(quantity)
+	if reserved := i.reserved(order.LocationId, uuid.Nil)[item.Id]; quantity.Sign() < 0 && balance.Cmp(reserved) < 0 {
+		return models.LedgerEntry{}, errors.NewError(errors.AdjustmentError, "only "+
+			balance.Sub(quantity).Sub(reserved).String()+" of "+item.Name+" available, "+reserved.String()+
+			" is reserved for carts")
+	}
+	entry := models.LedgerEntry{
+		Order:      order,
+		Item:       &item,
+		LocationId: order.LocationId,
+		Credit:     decimal.Zero,
+		Debit:      decimal.Zero,
+		Balance:    balance,
+		BaseFields: models.BaseFields{
+			Id:       uuid.UUID{},
+			Created:  i.clock.Now(),
+			Modified: i.clock.Now(),
+			Status:   models.CreatedLedgerEntryStatus,
+		},
+	}
+	if quantity.Sign() > 0 {
+		entry.Credit = quantity
+	} else {
+		entry.Debit = quantity.Neg()
+	}
+	return entry, nil
+}
+
+// ShrinkageLine is stock lost and what it would have sold for
+type ShrinkageLine struct {
+	// Name of the item, empty for reasons
+	Name  string
+	Units decimal.Decimal
+	// Units at the item's price when they were lost
+	Value decimal.Decimal
+}
+
+// ShrinkageReport adds up the stock adjusted and written off in a period. Stock found by count corrections
+// counts against what was lost, so the figures are net.
+type ShrinkageReport struct {
+	From    time.Time
+	Till    time.Time
+	Items   map[uuid.UUID]ShrinkageLine
+	Reasons map[string]ShrinkageLine
+	Total   ShrinkageLine
+}
+
+// ShrinkageReport reports the adjustments made from from up to but not including till
+func (i *InventoryUsecaseRepository) ShrinkageReport(from time.Time, till time.Time) ShrinkageReport {
+	report := ShrinkageReport{
+		From:    from,
+		Till:    till,
+		Items:   make(map[uuid.UUID]ShrinkageLine),
+		Reasons: make(map[string]ShrinkageLine),
+		Total:   newShrinkageLine(""),
+	}
+	after, through := i.sequencesBetween(from, till)
+	for _, entry := range i.ledger.EntriesBetween(after, through) {
+		if entry.Status == models.AbortedLedgerEnryStatus {
+			continue
+		}
+		if entry.Order.Type != models.AdjustmentOrderType && entry.Order.Type != models.WriteOffOrderType {
+			continue
+		}
+		lost := entry.Debit.Sub(entry.Credit)
+		value := lost.Mul(entry.Item.PriceAt(entry.Created))
+
+		line, ok := report.Items[entry.Item.Id]
+		if !ok {
+			line = newShrinkageLine(entry.Item.Name)
+		}
+		report.Items[entry.Item.Id] = line.add(lost, value)
+		line, ok = report.Reasons[entry.Order.Reason]
+		if !ok {
+			line = newShrinkageLine("")
+		}
+		report.Reasons[entry.Order.Reason] = line.add(lost, value)
+		report.Total = report.Total.add(lost, value)
+	}
+	return report
+}
+
+// ShrinkageReportFor reports the business day, week or month at falls in
+func (i *InventoryUsecaseRepository) ShrinkageReportFor(period ReportPeriod, at time.Time) (ShrinkageReport, error) {
+	from, till, err := i.Calendar.Period(period, at)
+	if err != nil {
+		return ShrinkageReport{}, err
+	}
+	return i.ShrinkageReport(from, till), nil
+}
+
+func newShrinkageLine(name string) ShrinkageLine {
+	return ShrinkageLine{Name: name, Units: decimal.Zero, Value: decimal.Zero}
+}
+
+func (l ShrinkageLine) add(units decimal.Decimal, value decimal.Decimal) ShrinkageLine {
+	l.Units = l.Units.Add(units)
+	l.Value = l.Value.Add(value)
+	return l
+}
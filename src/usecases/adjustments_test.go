@@ -0,0 +1,88 @@
+package usecases
+
+import (
+	"clock"
+	"error"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestInventoryUsecaseRepository_Adjust(t *testing.T) {
+	start := time.Date(2017, 6, 6, 9, 0, 0, 0, time.UTC)
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.NewFake(start))
+	fM := new(models.Mocks)
+	fM.InitUsers()
+	anna := fM.Employees[0].Id
+
+	newItem := func(name, price string) models.Item {
+		return models.Item{Name: name, Price: mustDecimal(price),
+			BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	}
+	lego := newItem("Lego", "20")
+	yoyo := newItem("Yoyo", "3")
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(10, 0))
+	repo.Replenish(models.DefaultLocationId, yoyo, decimal.New(10, 0))
+
+	tests := []struct {
+		name      string
+		item      models.Item
+		quantity  int64
+		reason    string
+		user      uuid.UUID
+		wantType  models.OrderType
+		wantStock int64
+	}{
+		{name: "damaged", item: lego, quantity: -2, reason: models.DamageAdjustmentReason, user: anna,
+			wantType: models.WriteOffOrderType, wantStock: 8},
+		{name: "stolen", item: yoyo, quantity: -4, reason: models.TheftAdjustmentReason, user: anna,
+			wantType: models.WriteOffOrderType, wantStock: 6},
+		{name: "found when counting", item: yoyo, quantity: 1, reason: models.CountCorrectionAdjustmentReason,
+			user: anna, wantType: models.AdjustmentOrderType, wantStock: 7},
+		{name: "no reason", item: lego, quantity: -1, user: anna},
+		{name: "unknown reason", item: lego, quantity: -1, reason: "lunch", user: anna},
+		{name: "damage adding stock", item: lego, quantity: 1, reason: models.DamageAdjustmentReason, user: anna},
+		{name: "no employee", item: lego, quantity: -1, reason: models.SampleAdjustmentReason},
+		{name: "zero", item: lego, quantity: 0, reason: models.CountCorrectionAdjustmentReason, user: anna},
+		{name: "more than in stock", item: lego, quantity: -9, reason: models.SampleAdjustmentReason, user: anna},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := repo.Adjust(models.DefaultLocationId, tt.item, decimal.New(tt.quantity, 0), tt.reason, tt.user)
+			if tt.wantType == "" {
+				e, ok := err.(errors.ApplicationError)
+				if !ok || e.ErrorType != errors.ErrorMap[errors.AdjustmentError].ErrorType {
+					t.Errorf("InventoryUsecaseRepository.Adjust() error = %v, want an AdjustmentError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InventoryUsecaseRepository.Adjust() error = %v", err)
+			}
+			if order.Type != tt.wantType || order.Reason != tt.reason || !uuid.Equal(order.UserId, anna) {
+				t.Errorf("InventoryUsecaseRepository.Adjust() = %s/%s by %s, want %s/%s by Anna", order.Type,
+					order.Reason, order.UserId, tt.wantType, tt.reason)
+			}
+			if got := repo.findItemBalanceInLedger(models.DefaultLocationId, tt.item); !got.Equal(decimal.New(tt.wantStock, 0)) {
+				t.Errorf("stock of %s = %s, want %d", tt.item.Name, got, tt.wantStock)
+			}
+		})
+	}
+
+	report := repo.ShrinkageReport(start, start.Add(time.Hour))
+	want := func(what string, line ShrinkageLine, units int64, value string) {
+		if !line.Units.Equal(decimal.New(units, 0)) || !line.Value.Equal(mustDecimal(value)) {
+			t.Errorf("InventoryUsecaseRepository.ShrinkageReport() %s = %s worth %s, want %d worth %s", what,
+				line.Units, line.Value, units, value)
+		}
+	}
+	want("Lego", report.Items[lego.Id], 2, "40")
+	want("Yoyo, net of the one found", report.Items[yoyo.Id], 3, "9")
+	want("theft", report.Reasons[models.TheftAdjustmentReason], 4, "12")
+	want("count corrections", report.Reasons[models.CountCorrectionAdjustmentReason], -1, "-3")
+	want("total", report.Total, 5, "49")
+}
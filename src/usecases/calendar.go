@@ -0,0 +1,89 @@
+package usecases
+
+import (
+	"error"
+	"strconv"
+	"time"
+)
+
+// ReportPeriod is the length of a sales report
+type ReportPeriod string
+
+const (
+	DayPeriod   ReportPeriod = "day"
+	WeekPeriod  ReportPeriod = "week"
+	MonthPeriod ReportPeriod = "month"
+)
+
+// BusinessCalendar tells which business day a time belongs to. A business day starts at the cutoff hour
+// in the store's time zone, so sales after midnight but before the cutoff count towards the day before.
+// Days are counted on the wall clock, a day with a DST change is an hour shorter or longer.
+// The zero value has days running midnight to midnight UTC.
+type BusinessCalendar struct {
+	Location   *time.Location
+	CutoffHour int
+}
+
+// NewBusinessCalendar creates a calendar for an IANA time zone, eg. Europe/London
+func NewBusinessCalendar(zone string, cutoffHour int) (BusinessCalendar, error) {
+	if cutoffHour < 0 || cutoffHour > 23 {
+		return BusinessCalendar{}, errors.NewError(errors.InvalidInputError, "business day cutoff "+
+			strconv.Itoa(cutoffHour)+" is not an hour of the day")
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return BusinessCalendar{}, errors.NewError(errors.InvalidInputError, "time zone "+zone+" - "+err.Error())
+	}
+	return BusinessCalendar{Location: location, CutoffHour: cutoffHour}, nil
+}
+
+func (c BusinessCalendar) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// Day gives the date of the business day t falls in, as midnight in the store's time zone
+func (c BusinessCalendar) Day(t time.Time) time.Time {
+	local := t.In(c.location())
+	if local.Hour() < c.CutoffHour {
+		local = local.AddDate(0, 0, -1)
+	}
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.location())
+}
+
+// ParseDay reads a business day written as 2006-01-02 and gives the time it starts
+func (c BusinessCalendar) ParseDay(value string) (time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", value, c.location())
+	if err != nil {
+		return day, errors.NewError(errors.InvalidInputError, "bad date "+value)
+	}
+	return c.start(day), nil
+}
+
+// Period gives when the business day, week or month holding t starts and ends, in UTC.
+// Weeks start on Monday.
+func (c BusinessCalendar) Period(period ReportPeriod, t time.Time) (time.Time, time.Time, error) {
+	day := c.Day(t)
+	var first, next time.Time
+	switch period {
+	case DayPeriod:
+		first, next = day, day.AddDate(0, 0, 1)
+	case WeekPeriod:
+		first = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		next = first.AddDate(0, 0, 7)
+	case MonthPeriod:
+		first = day.AddDate(0, 0, 1-day.Day())
+		next = first.AddDate(0, 1, 0)
+	default:
+		return time.Time{}, time.Time{}, errors.NewError(errors.InvalidInputError, "unknown report period "+
+			string(period))
+	}
+	return c.start(first), c.start(next), nil
+}
+
+// when the business day of date starts
+func (c BusinessCalendar) start(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), c.CutoffHour, 0, 0, 0, c.location()).UTC()
+}
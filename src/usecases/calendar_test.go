@@ -0,0 +1,121 @@
+package usecases
+
+import (
+	"clock"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestBusinessCalendar_Period(t *testing.T) {
+	london, err := NewBusinessCalendar("Europe/London", 4)
+	if err != nil {
+		t.Fatalf("NewBusinessCalendar() error = %v", err)
+	}
+	utc := func(value string) time.Time {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return at.UTC()
+	}
+
+	tests := []struct {
+		name       string
+		calendar   BusinessCalendar
+		period     ReportPeriod
+		at         string
+		from, till string
+	}{
+		{name: "zero value", period: DayPeriod, at: "2017-06-06T23:30:00Z",
+			from: "2017-06-06T00:00:00Z", till: "2017-06-07T00:00:00Z"},
+		{name: "summer day", calendar: london, period: DayPeriod, at: "2017-06-06T12:00:00Z",
+			from: "2017-06-06T03:00:00Z", till: "2017-06-07T03:00:00Z"},
+		{name: "after midnight before the cutoff", calendar: london, period: DayPeriod, at: "2017-06-07T02:30:00Z",
+			from: "2017-06-06T03:00:00Z", till: "2017-06-07T03:00:00Z"},
+		{name: "clocks go forward, a 23 hour day", calendar: london, period: DayPeriod, at: "2017-03-25T12:00:00Z",
+			from: "2017-03-25T04:00:00Z", till: "2017-03-26T03:00:00Z"},
+		{name: "clocks go back, a 25 hour day", calendar: london, period: DayPeriod, at: "2017-10-28T12:00:00Z",
+			from: "2017-10-28T03:00:00Z", till: "2017-10-29T04:00:00Z"},
+		{name: "week from Monday", calendar: london, period: WeekPeriod, at: "2017-06-11T20:00:00Z",
+			from: "2017-06-05T03:00:00Z", till: "2017-06-12T03:00:00Z"},
+		{name: "month over a DST change", calendar: london, period: MonthPeriod, at: "2017-03-31T12:00:00Z",
+			from: "2017-03-01T04:00:00Z", till: "2017-04-01T03:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, till, err := tt.calendar.Period(tt.period, utc(tt.at))
+			if err != nil {
+				t.Fatalf("BusinessCalendar.Period() error = %v", err)
+			}
+			if !from.Equal(utc(tt.from)) || !till.Equal(utc(tt.till)) {
+				t.Errorf("BusinessCalendar.Period() = %s - %s, want %s - %s", from, till, tt.from, tt.till)
+			}
+		})
+	}
+
+	if _, _, err := london.Period("year", time.Now()); err == nil {
+		t.Errorf("BusinessCalendar.Period(year) succeeded")
+	}
+	if _, err := NewBusinessCalendar("Europe/Atlantis", 0); err == nil {
+		t.Errorf("NewBusinessCalendar() with an unknown zone succeeded")
+	}
+	if _, err := NewBusinessCalendar("UTC", 24); err == nil {
+		t.Errorf("NewBusinessCalendar() with cutoff 24 succeeded")
+	}
+}
+
+func TestInventoryUsecaseRepository_SalesFor(t *testing.T) {
+	london, _ := NewBusinessCalendar("Europe/London", 4)
+	// Tuesday 6 June 2017 15:00 BST
+	now := clock.NewFake(time.Date(2017, 6, 6, 14, 0, 0, 0, time.UTC))
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), now)
+	repo.Calendar = london
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	dora := fM.GetMockedItem(0)
+	repo.Replenish(models.DefaultLocationId, *dora, decimal.New(20, 0))
+	buy := func(at time.Time, qty int64) {
+		now.Set(at)
+		lineItems := []models.OrderLineItem{{Item: dora, Quantity: qty}}
+		if _, err := repo.Purchase(models.DefaultLocationId, &lineItems, fM.GetMockedUser(0).Id, 0); err != nil {
+			t.Fatalf("InventoryUsecaseRepository.Purchase() error = %v", err)
+		}
+	}
+	// Tuesday afternoon, Wednesday 1:30 and 4:00 BST, the next Monday
+	buy(time.Date(2017, 6, 6, 14, 0, 0, 0, time.UTC), 1)
+	buy(time.Date(2017, 6, 7, 0, 30, 0, 0, time.UTC), 2)
+	buy(time.Date(2017, 6, 7, 3, 0, 0, 0, time.UTC), 4)
+	buy(time.Date(2017, 6, 12, 12, 0, 0, 0, time.UTC), 8)
+
+	tuesday, err := london.ParseDay("2017-06-06")
+	if err != nil {
+		t.Fatalf("BusinessCalendar.ParseDay() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		period ReportPeriod
+		at     time.Time
+		sold   int64
+	}{
+		{name: "Tuesday with the late sale", period: DayPeriod, at: tuesday, sold: 3},
+		{name: "Wednesday from the cutoff", period: DayPeriod, at: tuesday.AddDate(0, 0, 1), sold: 4},
+		{name: "the week", period: WeekPeriod, at: tuesday, sold: 7},
+		{name: "the month", period: MonthPeriod, at: tuesday, sold: 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sales, err := repo.SalesFor(tt.period, tt.at)
+			if err != nil {
+				t.Fatalf("InventoryUsecaseRepository.SalesFor() error = %v", err)
+			}
+			if got := sales.Items[dora.Id]; !got.Equal(decimal.New(tt.sold, 0)) {
+				t.Errorf("InventoryUsecaseRepository.SalesFor() %s = %s, want %d", dora.Name, got, tt.sold)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"models"
+	"stores"
+	"sync"
+)
+
+// CartUsecaseRepository keeps the purchases being built at the registers. The stock added to a cart is reserved
+// for it, with the cart's id as holder, until the cart is checked out or abandoned.
+type CartUsecaseRepository struct {
+	inventory *InventoryUsecaseRepository
+	carts     stores.CartStore
+	// a cart is read, changed and saved, one change at a time
+	mu sync.Mutex
+}
+
+// NewCartUsecaseRepository creates the cart usecases for an inventory
+func NewCartUsecaseRepository(inventory *InventoryUsecaseRepository, carts stores.CartStore) *CartUsecaseRepository {
+	return &CartUsecaseRepository{
+		inventory: inventory,
+		carts:     carts,
+	}
+}
+
+// Open starts an empty cart for a user at a register, selling the stock of a location
+func (c *CartUsecaseRepository) Open(userId uuid.UUID, locationId uuid.UUID, register string) (models.Cart, error) {
+	if uuid.Equal(userId, uuid.Nil) {
+		return models.Cart{}, errors.NewError(errors.CartError, "Empty user given")
+	}
+	if err := c.inventory.checkLocation(locationId); err != nil {
+		return models.Cart{}, err
+	}
+
+	cart := models.Cart{
+		UserId:     userId,
+		LocationId: locationId,
+		Register:   register,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  c.inventory.clock.Now(),
+			Modified: c.inventory.clock.Now(),
+			Status:   models.OpenCartStatus,
+		},
+	}
+	if err := c.carts.SaveCart(cart); err != nil {
+		return models.Cart{}, errors.NewError(errors.CartError, err.Error())
+	}
+	return cart, nil
+}
+
+// Add puts quantity more of an item in an open cart, once the stock is reserved for it. Lines of the same item
+// are added up.
+func (c *CartUsecaseRepository) Add(cartId uuid.UUID, item models.Item, quantity int64) (models.Cart, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cart, err := c.cartIn(cartId, models.OpenCartStatus)
+	if err != nil {
+		return cart, err
+	}
+	if _, err := c.inventory.Reserve(cart.Id, cart.LocationId, item, quantity); err != nil {
+		return cart, err
+	}
+
+	if n := cartLine(cart, item.Id); n >= 0 {
+		cart.Lines[n].Quantity += quantity
+	} else {
+		cart.Lines = append(cart.Lines, models.CartLine{Item: item, Quantity: quantity})
+	}
+	// stock reserved for a cart that couldn't be saved is free again once the reservation expires
+	return c.save(cart)
+}
+
+// Park sets an open cart aside so its register can serve somebody else. Its stock stays reserved until the
+// reservations expire.
+func (c *CartUsecaseRepository) Park(cartId uuid.UUID) (models.Cart, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cart, err := c.cartIn(cartId, models.OpenCartStatus)
+	if err != nil {
+		return cart, err
+	}
+	cart.Status = models.ParkedCartStatus
+	return c.save(cart)
+}
+
+// Resume opens a parked cart at a register, the one that parked it or another one. The reservations of its lines
+// are renewed, as they may have expired while it was parked; if some of the stock was sold meanwhile the cart
+// stays parked with the reservations it had, to be abandoned or resumed when there is stock again.
+func (c *CartUsecaseRepository) Resume(cartId uuid.UUID, register string) (models.Cart, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cart, err := c.cartIn(cartId, models.ParkedCartStatus)
+	if err != nil {
+		return cart, err
+	}
+
+	if _, err := c.inventory.Renew(cart.Id, cart.LocationId, cartLineItems(cart)); err != nil {
+		return cart, err
+	}
+	cart.Status = models.OpenCartStatus
+	cart.Register = register
+	return c.save(cart)
+}
+
+// Abandon drops an open or parked cart and frees the stock reserved for it
+func (c *CartUsecaseRepository) Abandon(cartId uuid.UUID) (models.Cart, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cart, err := c.cartIn(cartId, models.OpenCartStatus, models.ParkedCartStatus)
+	if err != nil {
+		return cart, err
+	}
+	if err := c.inventory.Release(cart.Id); err != nil {
+		return cart, err
+	}
+	cart.Status = models.AbandonedCartStatus
+	return c.save(cart)
+}
+
+// Checkout places the purchase order for an open cart with the user's discount, the stock reserved for it
+// counting as available. A cart that can't be checked out stays open. A placed order is returned even with an
+// error, eg. when its reservations couldn't be released.
+func (c *CartUsecaseRepository) Checkout(cartId uuid.UUID, userDiscount int) (models.Order, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cart, err := c.cartIn(cartId, models.OpenCartStatus)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if len(cart.Lines) == 0 {
+		return models.Order{}, errors.NewError(errors.CartError, "cart "+cart.Id.String()+" is empty")
+	}
+
+	// the cart is saved as checked out before the order is placed, so a cart that couldn't be saved can't be
+	// checked out twice
+	before := cart
+	cart.Status = models.CheckedOutCartStatus
+	if cart, err = c.save(cart); err != nil {
+		return models.Order{}, err
+	}
+	lineItems := cartLineItems(cart)
+	order, err := c.inventory.Checkout(cart.Id, cart.LocationId, &lineItems, cart.UserId, userDiscount)
+	if uuid.Equal(order.Id, uuid.Nil) {
+		// nothing was bought, the cart is open again
+		if _, rollbackErr := c.save(before); rollbackErr != nil {
+			return order, errors.NewError(errors.CartError, err.Error()+", and the cart is left checked out: "+
+				rollbackErr.Error())
+		}
+		return order, err
+	}
+
+	// the order is placed even if its reservations weren't released
+	cart.OrderId = order.Id
+	if _, saveErr := c.save(cart); saveErr != nil {
+		// the cart is checked out all the same, it just doesn't link to its order
+		return order, saveErr
+	}
+	return order, err
+}
+
+// Cart looks a cart up by id
+func (c *CartUsecaseRepository) Cart(cartId uuid.UUID) (models.Cart, error) {
+	cart, ok := c.carts.Cart(cartId)
+	if !ok {
+		return models.Cart{}, errors.NewError(errors.NotFoundError, "cart "+cartId.String())
+	}
+	return cart, nil
+}
+
+// Carts returns the carts with the given status, every cart when it is empty, oldest first
+func (c *CartUsecaseRepository) Carts(status string) []models.Cart {
+	var carts []models.Cart
+	for _, cart := range c.carts.Carts() {
+		if status == "" || cart.Status == status {
+			carts = append(carts, cart)
+		}
+	}
+	return carts
+}
+
+// the cart, if it has one of the statuses. Callers hold mu.
+func (c *CartUsecaseRepository) cartIn(cartId uuid.UUID, statuses ...string) (models.Cart, error) {
+	cart, err := c.Cart(cartId)
+	if err != nil {
+		return cart, err
+	}
+	for _, status := range statuses {
+		if cart.Status == status {
+			return cart, nil
+		}
+	}
+	return cart, errors.NewError(errors.CartError, "cart "+cartId.String()+" is "+cart.Status)
+}
+
+// Callers hold mu.
+func (c *CartUsecaseRepository) save(cart models.Cart) (models.Cart, error) {
+	cart.Modified = c.inventory.clock.Now()
+	if err := c.carts.SaveCart(cart); err != nil {
+		return models.Cart{}, errors.NewError(errors.CartError, err.Error())
+	}
+	return cart, nil
+}
+
+// index of the cart's line for an item, -1 when it has none
+func cartLine(cart models.Cart, itemId uuid.UUID) int {
+	for n, line := range cart.Lines {
+		if uuid.Equal(line.Item.Id, itemId) {
+			return n
+		}
+	}
+	return -1
+}
+
+// the order lines of a cart's lines
+func cartLineItems(cart models.Cart) []models.OrderLineItem {
+	lineItems := make([]models.OrderLineItem, 0, len(cart.Lines))
+	for n := range cart.Lines {
+		lineItems = append(lineItems, models.OrderLineItem{Item: &cart.Lines[n].Item,
+			Quantity: cart.Lines[n].Quantity})
+	}
+	return lineItems
+}
@@ -0,0 +1,220 @@
+package usecases
+
+import (
+	"clock"
+	"error"
+	"fmt"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestCartUsecaseRepository_ParkAndResume(t *testing.T) {
+	fake := clock.NewFake(time.Date(2017, 6, 6, 9, 0, 0, 0, time.UTC))
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), fake)
+	repo.ReservationTTL = 10 * time.Minute
+	carts := NewCartUsecaseRepository(repo, stores.NewMemoryCartStore())
+	lego := models.Item{Name: "Lego", Price: decimal.New(5, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(5, 0))
+	anna, boris := uuid.NewV4(), uuid.NewV4()
+
+	wantError := func(what string, err error, errorType int) {
+		e, ok := err.(errors.ApplicationError)
+		if !ok || e.ErrorType != errors.ErrorMap[errorType].ErrorType {
+			t.Errorf("CartUsecaseRepository %s error = %v, want %s", what, err, errors.ErrorMap[errorType].Message)
+		}
+	}
+	reserved := func() decimal.Decimal {
+		return repo.StockLevels(models.DefaultLocationId)[lego.Id].Reserved
+	}
+
+	cart, err := carts.Open(anna, models.DefaultLocationId, "front")
+	if err != nil {
+		t.Fatalf("CartUsecaseRepository.Open() error = %v", err)
+	}
+	carts.Add(cart.Id, lego, 1)
+	if cart, err = carts.Add(cart.Id, lego, 2); err != nil || len(cart.Lines) != 1 || cart.Lines[0].Quantity != 3 {
+		t.Fatalf("CartUsecaseRepository.Add() = %+v, %v, want one line of 3 Lego", cart, err)
+	}
+	_, err = carts.Checkout(uuid.NewV4(), 0)
+	wantError("Checkout() of an unknown cart", err, errors.NotFoundError)
+
+	// the cart is parked while Anna fetches her wallet and the register serves Boris
+	if _, err := carts.Park(cart.Id); err != nil {
+		t.Fatalf("CartUsecaseRepository.Park() error = %v", err)
+	}
+	_, err = carts.Add(cart.Id, lego, 1)
+	wantError("Add() to a parked cart", err, errors.CartError)
+	_, err = carts.Checkout(cart.Id, 0)
+	wantError("Checkout() of a parked cart", err, errors.CartError)
+	other, _ := carts.Open(boris, models.DefaultLocationId, "front")
+	_, err = carts.Add(other.Id, lego, 3)
+	wantError("Add() of stock held by a parked cart", err, errors.ReservationError)
+	carts.Add(other.Id, lego, 2)
+	if parked := carts.Carts(models.ParkedCartStatus); len(parked) != 1 || !uuid.Equal(parked[0].Id, cart.Id) {
+		t.Errorf("CartUsecaseRepository.Carts(parked) = %+v, want Anna's cart", parked)
+	}
+
+	// she comes back after the reservation expired and pays at another register
+	fake.Advance(10 * time.Minute)
+	if got := reserved(); !got.Equal(decimal.Zero) {
+		t.Errorf("reserved after the TTL = %s, want 0", got)
+	}
+	if cart, err = carts.Resume(cart.Id, "back"); err != nil || cart.Status != models.OpenCartStatus ||
+		cart.Register != "back" {
+		t.Fatalf("CartUsecaseRepository.Resume() = %+v, %v, want it open at the back register", cart, err)
+	}
+	if got := reserved(); !got.Equal(decimal.New(3, 0)) {
+		t.Errorf("reserved after resuming = %s, want 3", got)
+	}
+	_, err = carts.Resume(cart.Id, "front")
+	wantError("Resume() of an open cart", err, errors.CartError)
+	order, err := carts.Checkout(cart.Id, 0)
+	if err != nil {
+		t.Fatalf("CartUsecaseRepository.Checkout() error = %v", err)
+	}
+	if cart, _ = carts.Cart(cart.Id); cart.Status != models.CheckedOutCartStatus || !uuid.Equal(cart.OrderId, order.Id) {
+		t.Errorf("CartUsecaseRepository.Cart() after checkout = %+v, want it checked out as %s", cart, order.Id)
+	}
+
+	// a cart whose stock was sold while it was parked can't be resumed, abandoning it frees what it holds
+	other, _ = carts.Park(other.Id)
+	fake.Advance(10 * time.Minute)
+	if _, err := repo.PurchaseOrder(models.DefaultLocationId, &[]models.OrderLineItem{{Item: &lego, Quantity: 1}},
+		anna, 0); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+	}
+	_, err = carts.Resume(other.Id, "front")
+	wantError("Resume() of a cart whose stock was sold", err, errors.ReservationError)
+	if other, _ = carts.Cart(other.Id); other.Status != models.ParkedCartStatus {
+		t.Errorf("CartUsecaseRepository.Cart() after a failed resume = %+v, want it parked", other)
+	}
+	if other, err = carts.Abandon(other.Id); err != nil || other.Status != models.AbandonedCartStatus {
+		t.Errorf("CartUsecaseRepository.Abandon() = %+v, %v, want it abandoned", other, err)
+	}
+	if held := repo.Held(other.Id); len(held) != 0 {
+		t.Errorf("InventoryUsecaseRepository.Held() after abandoning = %v, want nothing", held)
+	}
+
+	// a failed resume leaves the reservations of the cart as they were
+	last, _ := carts.Open(boris, models.DefaultLocationId, "front")
+	carts.Add(last.Id, lego, 1)
+	carts.Park(last.Id)
+	fake.Advance(10 * time.Minute)
+	before := repo.Reservations.Reservations()
+	if _, err := repo.PurchaseOrder(models.DefaultLocationId, &[]models.OrderLineItem{{Item: &lego, Quantity: 1}},
+		anna, 0); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+	}
+	_, err = carts.Resume(last.Id, "front")
+	wantError("Resume() of a cart whose stock was sold", err, errors.ReservationError)
+	if after := repo.Reservations.Reservations(); len(before) != 1 || len(after) != 1 ||
+		!uuid.Equal(after[0].Id, before[0].Id) || !after[0].Quantity.Equal(before[0].Quantity) ||
+		!after[0].Expires.Equal(before[0].Expires) {
+		t.Errorf("reservations after a failed resume = %+v, want them as they were, %+v", after, before)
+	}
+}
+
+// fails every reservation deleted while fail is set
+type failingReservationStore struct {
+	stores.ReservationStore
+	fail bool
+}
+
+func (s *failingReservationStore) DeleteReservation(id uuid.UUID) error {
+	if s.fail {
+		return fmt.Errorf("disk full")
+	}
+	return s.ReservationStore.DeleteReservation(id)
+}
+
+func TestCartUsecaseRepository_CheckoutReleaseFailing(t *testing.T) {
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	reservations := &failingReservationStore{ReservationStore: repo.Reservations}
+	repo.Reservations = reservations
+	carts := NewCartUsecaseRepository(repo, stores.NewMemoryCartStore())
+	lego := models.Item{Name: "Lego", Price: decimal.New(5, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(5, 0))
+	cart, _ := carts.Open(uuid.NewV4(), models.DefaultLocationId, "front")
+	carts.Add(cart.Id, lego, 3)
+
+	// the order is placed, its reservations are left to lapse
+	reservations.fail = true
+	order, err := carts.Checkout(cart.Id, 0)
+	if e, ok := err.(errors.ApplicationError); !ok || e.ErrorType != errors.ErrorMap[errors.ReservationError].ErrorType {
+		t.Errorf("CartUsecaseRepository.Checkout() error = %v, want a reservation error", err)
+	}
+	if uuid.Equal(order.Id, uuid.Nil) {
+		t.Fatalf("CartUsecaseRepository.Checkout() placed no order")
+	}
+	if got, _ := carts.Cart(cart.Id); got.Status != models.CheckedOutCartStatus || !uuid.Equal(got.OrderId, order.Id) {
+		t.Errorf("CartUsecaseRepository.Cart() = %+v, want it checked out for order %s", got, order.Id)
+	}
+}
+
+// fails saving the carts failing picks
+type failingCartStore struct {
+	stores.CartStore
+	failing func(cart models.Cart) bool
+}
+
+func (s *failingCartStore) SaveCart(cart models.Cart) error {
+	if s.failing != nil && s.failing(cart) {
+		return fmt.Errorf("disk full")
+	}
+	return s.CartStore.SaveCart(cart)
+}
+
+func TestCartUsecaseRepository_CheckoutSaveFailing(t *testing.T) {
+	ledger := stores.NewMemoryLedgerStore()
+	repo := NewInventoryUsecaseRepository(ledger, clock.System)
+	store := &failingCartStore{CartStore: stores.NewMemoryCartStore()}
+	carts := NewCartUsecaseRepository(repo, store)
+	lego := models.Item{Name: "Lego", Price: decimal.New(5, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(10, 0))
+	purchases := func() int {
+		n := 0
+		for _, entry := range ledger.Entries() {
+			if entry.Order.Type == models.PurchaseOrderType {
+				n++
+			}
+		}
+		return n
+	}
+	checkout := func(cart models.Cart, failing func(cart models.Cart) bool) (models.Order, error) {
+		store.failing = failing
+		defer func() { store.failing = nil }()
+		return carts.Checkout(cart.Id, 0)
+	}
+
+	// a cart that can't be marked checked out stays open and nothing is bought
+	cart, _ := carts.Open(uuid.NewV4(), models.DefaultLocationId, "front")
+	carts.Add(cart.Id, lego, 2)
+	if _, err := checkout(cart, func(models.Cart) bool { return true }); err == nil {
+		t.Errorf("CartUsecaseRepository.Checkout() with a failing store succeeded")
+	}
+	if got, _ := carts.Cart(cart.Id); got.Status != models.OpenCartStatus || purchases() != 0 {
+		t.Errorf("CartUsecaseRepository.Cart() after a failed checkout = %+v with %d orders, want it open and none",
+			got, purchases())
+	}
+
+	// a cart that can't be linked to its order is checked out all the same, retrying doesn't buy again
+	withOrder := func(cart models.Cart) bool { return !uuid.Equal(cart.OrderId, uuid.Nil) }
+	if order, err := checkout(cart, withOrder); err == nil || uuid.Equal(order.Id, uuid.Nil) {
+		t.Errorf("CartUsecaseRepository.Checkout() = %+v, %v, want the order and the error", order, err)
+	}
+	if _, err := carts.Checkout(cart.Id, 0); err == nil {
+		t.Errorf("CartUsecaseRepository.Checkout() again succeeded")
+	}
+	if got, _ := carts.Cart(cart.Id); got.Status != models.CheckedOutCartStatus || purchases() != 1 {
+		t.Errorf("CartUsecaseRepository.Cart() after checking out = %+v with %d orders, want it checked out once",
+			got, purchases())
+	}
+}
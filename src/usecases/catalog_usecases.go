@@ -0,0 +1,362 @@
+package usecases
+
+import (
+	"clock"
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"sort"
+	"stores"
+	"strings"
+	"time"
+)
+
+// CatalogUsecaseRepository maintains the items, SKUs and product groups on sale
+type CatalogUsecaseRepository struct {
+	// stamps catalog changes
+	clock   clock.Clock
+	catalog stores.CatalogStore
+}
+
+// NewCatalogUsecaseRepository creates the catalog usecases on top of a catalog store, telling the time by clk
+func NewCatalogUsecaseRepository(catalog stores.CatalogStore, clk clock.Clock) *CatalogUsecaseRepository {
+	return &CatalogUsecaseRepository{
+		clock:   clk,
+		catalog: catalog,
+	}
+}
+
+// Seed fills an empty catalog with the given items and their SKUs and product groups,
+// a catalog that already has items is left alone
+func (c *CatalogUsecaseRepository) Seed(items []models.Item) error {
+	if len(c.catalog.Items()) > 0 {
+		return nil
+	}
+	for _, item := range items {
+		if !uuid.Equal(item.SkuId, uuid.Nil) {
+			if err := c.catalog.SaveSKU(item.SKU); err != nil {
+				return errors.NewError(errors.CatalogError, err.Error())
+			}
+		}
+		if !uuid.Equal(item.ProductGroupId, uuid.Nil) {
+			if err := c.catalog.SaveProductGroup(item.ProductGroup); err != nil {
+				return errors.NewError(errors.CatalogError, err.Error())
+			}
+		}
+		if err := c.catalog.SaveItem(item); err != nil {
+			return errors.NewError(errors.CatalogError, err.Error())
+		}
+	}
+	return nil
+}
+
+// CreateItem adds an available item to the catalog. Its SKU and product group, if given, must exist.
+func (c *CatalogUsecaseRepository) CreateItem(item models.Item) (models.Item, error) {
+	if err := c.checkItem(item); err != nil {
+		return item, err
+	}
+
+	now := c.clock.Now()
+	item.PriceChanges = nil
+	item.BaseFields = models.BaseFields{
+		Id:       uuid.NewV4(),
+		Created:  now,
+		Modified: now,
+		Status:   models.AvailableItemStatus,
+	}
+	if err := c.catalog.SaveItem(item); err != nil {
+		return item, errors.NewError(errors.CatalogError, err.Error())
+	}
+	return c.resolve(item, now), nil
+}
+
+// UpdateItem changes the name, description, discount, tax class, reordering, SKU and product group of an item.
+// Prices and status have their own usecases.
+func (c *CatalogUsecaseRepository) UpdateItem(item models.Item) (models.Item, error) {
+	stored, err := c.storedItem(item.Id)
+	if err != nil {
+		return item, err
+	}
+	if err := c.checkItem(item); err != nil {
+		return item, err
+	}
+
+	stored.Name = item.Name
+	stored.Description = item.Description
+	stored.DiscountPercentage = item.DiscountPercentage
+	stored.TaxClass = item.TaxClass
+	stored.ReorderPoint = item.ReorderPoint
+	stored.ReorderQuantity = item.ReorderQuantity
+	stored.SKU = item.SKU
+	stored.ProductGroup = item.ProductGroup
+	stored.Modified = c.clock.Now()
+	if err := c.catalog.SaveItem(stored); err != nil {
+		return item, errors.NewError(errors.CatalogError, err.Error())
+	}
+	return c.resolve(stored, stored.Modified), nil
+}
+
+// ChangePrice schedules a new price for an item from effectiveFrom on, a change already
+// scheduled for the same moment is replaced
+func (c *CatalogUsecaseRepository) ChangePrice(itemId uuid.UUID, price decimal.Decimal,
+	effectiveFrom time.Time) error {
+	if price.Sign() < 0 {
+		return errors.NewError(errors.CatalogError, "Price can't be negative")
+	}
+	item, err := c.storedItem(itemId)
+	if err != nil {
+		return err
+	}
+
+	changes := make([]models.PriceChange, 0, len(item.PriceChanges)+1)
+	for _, change := range item.PriceChanges {
+		if !change.EffectiveFrom.Equal(effectiveFrom) {
+			changes = append(changes, change)
+		}
+	}
+	changes = append(changes, models.PriceChange{Price: price, EffectiveFrom: effectiveFrom.UTC()})
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].EffectiveFrom.Before(changes[j].EffectiveFrom)
+	})
+
+	item.PriceChanges = changes
+	item.Modified = c.clock.Now()
+	if err := c.catalog.SaveItem(item); err != nil {
+		return errors.NewError(errors.CatalogError, err.Error())
+	}
+	return nil
+}
+
+// SetItemStatus makes an item available or blocks it from being sold
+func (c *CatalogUsecaseRepository) SetItemStatus(itemId uuid.UUID, status string) error {
+	if status != models.AvailableItemStatus && status != models.BlockedItemStatus {
+		return errors.NewError(errors.CatalogError, "Unknown item status "+status)
+	}
+	item, err := c.storedItem(itemId)
+	if err != nil {
+		return err
+	}
+	if item.Status == models.RetiredItemStatus {
+		return errors.NewError(errors.CatalogError, item.Name+" is retired")
+	}
+
+	item.Status = status
+	item.Modified = c.clock.Now()
+	if err := c.catalog.SaveItem(item); err != nil {
+		return errors.NewError(errors.CatalogError, err.Error())
+	}
+	return nil
+}
+
+// RetireItem takes an item off sale for good. It stays in the catalog so the ledger can still name it.
+func (c *CatalogUsecaseRepository) RetireItem(itemId uuid.UUID) error {
+	item, err := c.storedItem(itemId)
+	if err != nil {
+		return err
+	}
+
+	item.Status = models.RetiredItemStatus
+	item.Modified = c.clock.Now()
+	if err := c.catalog.SaveItem(item); err != nil {
+		return errors.NewError(errors.CatalogError, err.Error())
+	}
+	return nil
+}
+
+// GetItem returns an item with its current price, SKU and product group
+func (c *CatalogUsecaseRepository) GetItem(itemId uuid.UUID) (models.Item, error) {
+	item, err := c.storedItem(itemId)
+	if err != nil {
+		return item, err
+	}
+	return c.resolve(item, c.clock.Now()), nil
+}
+
+// FindItem looks an item up by id or, ignoring case, by name
+func (c *CatalogUsecaseRepository) FindItem(ref string) (models.Item, error) {
+	if id, err := uuid.FromString(ref); err == nil {
+		return c.GetItem(id)
+	}
+	for _, item := range c.Items() {
+		if strings.EqualFold(item.Name, ref) {
+			return item, nil
+		}
+	}
+	return models.Item{}, errors.NewError(errors.NotFoundError, "item "+ref)
+}
+
+// Items returns every item, retired ones included, with current prices
+func (c *CatalogUsecaseRepository) Items() []models.Item {
+	now := c.clock.Now()
+	items := c.catalog.Items()
+	for n := range items {
+		items[n] = c.resolve(items[n], now)
+	}
+	return items
+}
+
+// AvailableItems returns the items that can be sold right now
+func (c *CatalogUsecaseRepository) AvailableItems() []models.Item {
+	var available []models.Item
+	for _, item := range c.Items() {
+		if item.Status == models.AvailableItemStatus {
+			available = append(available, item)
+		}
+	}
+	return available
+}
+
+// CreateSKU adds a SKU to the catalog
+func (c *CatalogUsecaseRepository) CreateSKU(sku models.SKU) (models.SKU, error) {
+	if err := checkSKU(sku); err != nil {
+		return sku, err
+	}
+	sku.SkuId = uuid.NewV4()
+	if err := c.catalog.SaveSKU(sku); err != nil {
+		return sku, errors.NewError(errors.CatalogError, err.Error())
+	}
+	return sku, nil
+}
+
+// UpdateSKU changes the name, description, discount or tax class of a SKU
+func (c *CatalogUsecaseRepository) UpdateSKU(sku models.SKU) error {
+	if _, ok := c.catalog.SKU(sku.SkuId); !ok {
+		return errors.NewError(errors.NotFoundError, "SKU "+sku.SkuId.String())
+	}
+	if err := checkSKU(sku); err != nil {
+		return err
+	}
+	if err := c.catalog.SaveSKU(sku); err != nil {
+		return errors.NewError(errors.CatalogError, err.Error())
+	}
+	return nil
+}
+
+// RetireSKU removes a SKU that no unretired item uses anymore
+func (c *CatalogUsecaseRepository) RetireSKU(skuId uuid.UUID) error {
+	for _, item := range c.catalog.Items() {
+		if uuid.Equal(item.SkuId, skuId) && item.Status != models.RetiredItemStatus {
+			return errors.NewError(errors.CatalogError, "SKU is still used by "+item.Name)
+		}
+	}
+	if err := c.catalog.DeleteSKU(skuId); err != nil {
+		return errors.NewError(errors.CatalogError, err.Error())
+	}
+	return nil
+}
+
+func (c *CatalogUsecaseRepository) SKUs() []models.SKU {
+	return c.catalog.SKUs()
+}
+
+// CreateProductGroup adds a product group to the catalog
+func (c *CatalogUsecaseRepository) CreateProductGroup(group models.ProductGroup) (models.ProductGroup, error) {
+	if err := checkDiscount(group.Name, group.DiscountPercentage); err != nil {
+		return group, err
+	}
+	group.ProductGroupId = uuid.NewV4()
+	if err := c.catalog.SaveProductGroup(group); err != nil {
+		return group, errors.NewError(errors.CatalogError, err.Error())
+	}
+	return group, nil
+}
+
+// UpdateProductGroup changes the name, description or discount of a product group
+func (c *CatalogUsecaseRepository) UpdateProductGroup(group models.ProductGroup) error {
+	if _, ok := c.catalog.ProductGroup(group.ProductGroupId); !ok {
+		return errors.NewError(errors.NotFoundError, "product group "+group.ProductGroupId.String())
+	}
+	if err := checkDiscount(group.Name, group.DiscountPercentage); err != nil {
+		return err
+	}
+	if err := c.catalog.SaveProductGroup(group); err != nil {
+		return errors.NewError(errors.CatalogError, err.Error())
+	}
+	return nil
+}
+
+// RetireProductGroup removes a product group that no unretired item uses anymore
+func (c *CatalogUsecaseRepository) RetireProductGroup(groupId uuid.UUID) error {
+	for _, item := range c.catalog.Items() {
+		if uuid.Equal(item.ProductGroupId, groupId) && item.Status != models.RetiredItemStatus {
+			return errors.NewError(errors.CatalogError, "product group is still used by "+item.Name)
+		}
+	}
+	if err := c.catalog.DeleteProductGroup(groupId); err != nil {
+		return errors.NewError(errors.CatalogError, err.Error())
+	}
+	return nil
+}
+
+func (c *CatalogUsecaseRepository) ProductGroups() []models.ProductGroup {
+	return c.catalog.ProductGroups()
+}
+
+func (c *CatalogUsecaseRepository) storedItem(itemId uuid.UUID) (models.Item, error) {
+	item, ok := c.catalog.Item(itemId)
+	if !ok {
+		return item, errors.NewError(errors.NotFoundError, "item "+itemId.String())
+	}
+	return item, nil
+}
+
+func (c *CatalogUsecaseRepository) checkItem(item models.Item) error {
+	if strings.TrimSpace(item.Name) == "" {
+		return errors.NewError(errors.CatalogError, "Item needs a name")
+	}
+	if item.Price.Sign() < 0 {
+		return errors.NewError(errors.CatalogError, "Price can't be negative")
+	}
+	if item.ReorderPoint.Sign() < 0 || item.ReorderQuantity.Sign() < 0 {
+		return errors.NewError(errors.CatalogError, "Reorder point and quantity of "+item.Name+" can't be negative")
+	}
+	if err := checkDiscount(item.Name, item.DiscountPercentage); err != nil {
+		return err
+	}
+	if item.TaxClass != "" {
+		if err := checkTaxClass(item.TaxClass); err != nil {
+			return err
+		}
+	}
+	if !uuid.Equal(item.SkuId, uuid.Nil) {
+		if _, ok := c.catalog.SKU(item.SkuId); !ok {
+			return errors.NewError(errors.NotFoundError, "SKU "+item.SkuId.String())
+		}
+	}
+	if !uuid.Equal(item.ProductGroupId, uuid.Nil) {
+		if _, ok := c.catalog.ProductGroup(item.ProductGroupId); !ok {
+			return errors.NewError(errors.NotFoundError, "product group "+item.ProductGroupId.String())
+		}
+	}
+	return nil
+}
+
+func checkSKU(sku models.SKU) error {
+	if err := checkDiscount(sku.Name, sku.DiscountPercentage); err != nil {
+		return err
+	}
+	if sku.TaxClass != "" {
+		return checkTaxClass(sku.TaxClass)
+	}
+	return nil
+}
+
+func checkDiscount(name string, percentage int) error {
+	if percentage < 0 || percentage > 100 {
+		return errors.NewError(errors.CatalogError, "Discount of "+name+" must be between 0 and 100%")
+	}
+	return nil
+}
+
+// the item as sold at a point in time: the price in effect and the latest SKU and product group
+func (c *CatalogUsecaseRepository) resolve(item models.Item, at time.Time) models.Item {
+	item.Price = item.PriceAt(at)
+	if sku, ok := c.catalog.SKU(item.SkuId); ok {
+		item.SKU = sku
+	}
+	if group, ok := c.catalog.ProductGroup(item.ProductGroupId); ok {
+		item.ProductGroup = group
+	}
+	return item
+}
@@ -0,0 +1,103 @@
+package usecases
+
+import (
+	"clock"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCatalogUsecaseRepository_ItemLifecycle(t *testing.T) {
+	catalogStore := stores.NewMemoryCatalogStore()
+	catalog := NewCatalogUsecaseRepository(catalogStore, clock.System)
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	repo.Catalog = catalogStore
+	fM := new(models.Mocks)
+	fM.InitUsers()
+
+	sku, err := catalog.CreateSKU(models.SKU{Name: "Plush", DiscountPercentage: 5})
+	if err != nil {
+		t.Fatalf("CatalogUsecaseRepository.CreateSKU() error = %v", err)
+	}
+	if _, err := catalog.CreateItem(models.Item{Name: "", Price: decimal.New(1, 0)}); err == nil {
+		t.Errorf("CatalogUsecaseRepository.CreateItem() without a name succeeded")
+	}
+	item, err := catalog.CreateItem(models.Item{Name: "Elmo", Price: decimal.New(20, 0), SKU: sku})
+	if err != nil {
+		t.Fatalf("CatalogUsecaseRepository.CreateItem() error = %v", err)
+	}
+	if item.Status != models.AvailableItemStatus || item.SKU.Name != "Plush" {
+		t.Errorf("CatalogUsecaseRepository.CreateItem() = %s/%s, want available with SKU Plush", item.Status, item.SKU.Name)
+	}
+
+	// a price change in the past applies now, one in the future doesn't yet
+	now := time.Now().UTC()
+	if err := catalog.ChangePrice(item.Id, decimal.New(18, 0), now.Add(-time.Hour)); err != nil {
+		t.Fatalf("CatalogUsecaseRepository.ChangePrice() error = %v", err)
+	}
+	if err := catalog.ChangePrice(item.Id, decimal.New(25, 0), now.Add(time.Hour)); err != nil {
+		t.Fatalf("CatalogUsecaseRepository.ChangePrice() error = %v", err)
+	}
+	got, _ := catalog.GetItem(item.Id)
+	if !got.Price.Equal(decimal.New(18, 0)) {
+		t.Errorf("CatalogUsecaseRepository.GetItem() price = %s, want 18", got.Price)
+	}
+	if !got.PriceAt(now.Add(2 * time.Hour)).Equal(decimal.New(25, 0)) {
+		t.Errorf("Item.PriceAt() in two hours = %s, want 25", got.PriceAt(now.Add(2*time.Hour)))
+	}
+
+	// SKU changes show up on the item
+	sku.DiscountPercentage = 15
+	if err := catalog.UpdateSKU(sku); err != nil {
+		t.Fatalf("CatalogUsecaseRepository.UpdateSKU() error = %v", err)
+	}
+	got, _ = catalog.GetItem(item.Id)
+	if got.SKU.DiscountPercentage != 15 {
+		t.Errorf("CatalogUsecaseRepository.GetItem() SKU discount = %d, want 15", got.SKU.DiscountPercentage)
+	}
+
+	// blocked items can't be sold
+	repo.Replenish(models.DefaultLocationId, got, decimal.New(5, 0))
+	if err := catalog.SetItemStatus(item.Id, models.BlockedItemStatus); err != nil {
+		t.Fatalf("CatalogUsecaseRepository.SetItemStatus() error = %v", err)
+	}
+	blocked, _ := catalog.GetItem(item.Id)
+	lineItems := []models.OrderLineItem{{Item: &blocked, Quantity: 1}}
+	if _, err := repo.Purchase(models.DefaultLocationId, &lineItems, fM.GetMockedUser(0).Id, 0); err == nil {
+		t.Errorf("InventoryUsecaseRepository.Purchase() of a blocked item succeeded")
+	}
+	// a copy fetched before the item was blocked still says it's available
+	stale := []models.OrderLineItem{{Item: &got, Quantity: 1}}
+	if _, err := repo.Purchase(models.DefaultLocationId, &stale, fM.GetMockedUser(0).Id, 0); err == nil {
+		t.Errorf("InventoryUsecaseRepository.Purchase() of a stale copy of a blocked item succeeded")
+	}
+	if len(catalog.AvailableItems()) != 0 {
+		t.Errorf("CatalogUsecaseRepository.AvailableItems() = %d items, want 0", len(catalog.AvailableItems()))
+	}
+	catalog.SetItemStatus(item.Id, models.AvailableItemStatus)
+	available, _ := catalog.GetItem(item.Id)
+	lineItems = []models.OrderLineItem{{Item: &available, Quantity: 1}}
+	if _, err := repo.Purchase(models.DefaultLocationId, &lineItems, fM.GetMockedUser(0).Id, 0); err != nil {
+		t.Errorf("InventoryUsecaseRepository.Purchase() after unblocking error = %v", err)
+	}
+
+	// a SKU in use can't be retired until its items are
+	if err := catalog.RetireSKU(sku.SkuId); err == nil {
+		t.Errorf("CatalogUsecaseRepository.RetireSKU() of a SKU in use succeeded")
+	}
+	if err := catalog.RetireItem(item.Id); err != nil {
+		t.Fatalf("CatalogUsecaseRepository.RetireItem() error = %v", err)
+	}
+	if err := catalog.SetItemStatus(item.Id, models.AvailableItemStatus); err == nil {
+		t.Errorf("CatalogUsecaseRepository.SetItemStatus() brought a retired item back")
+	}
+	if err := catalog.RetireSKU(sku.SkuId); err != nil {
+		t.Errorf("CatalogUsecaseRepository.RetireSKU() error = %v", err)
+	}
+	if found, err := catalog.FindItem("elmo"); err != nil || found.Status != models.RetiredItemStatus {
+		t.Errorf("CatalogUsecaseRepository.FindItem() = %v, %v, want the retired item", found.Status, err)
+	}
+}
@@ -1,214 +1,418 @@
 package usecases
 
 import (
+	"clock"
 	"error"
 	"github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 	"models"
-	"sort"
+	"stores"
+	"sync"
 	"time"
 )
 
-// InventoryUsecaseRepository contains business logic
-type InventoryUsecaseRepository struct{}
+// InventoryUsecaseRepository contains business logic. It is safe to share between registers:
+// Replenish and Purchase lock the items they touch, so a balance check and the entries
+// appended after it are atomic with respect to each other. Stock is kept at locations, every
+// location has balances of its own.
+type InventoryUsecaseRepository struct {
+	// DiscountPolicy decides which item, SKU, product group and user discounts a purchase gets
+	DiscountPolicy DiscountPolicy
+	// TaxCalculator taxes purchases, the zero value taxes nothing
+	TaxCalculator TaxCalculator
+	// Calendar decides when business days, weeks and months start, the zero value uses UTC days
+	Calendar BusinessCalendar
+	// LowStock is called when a purchase takes an item below its reorder point, after the purchase
+	// is in the ledger and its locks are released. Nil alerts nobody.
+	LowStock func(LowStockAlert)
+	// Locations keeps the places stock is kept, an in-memory store with just the default location
+	// unless set
+	Locations stores.LocationStore
+	// Reservations keeps the stock held for carts, purchases can't take it. An in-memory store unless set.
+	Reservations stores.ReservationStore
+	// ReservationTTL is how long a reservation holds stock after the last time it was added to
+	ReservationTTL time.Duration
+	// Payments keeps the tenders taken for purchase orders. An in-memory store unless set.
+	Payments stores.PaymentStore
+	// Catalog has the status items are sold by, a caller's copy of an item may be stale. Without one
+	// the status of the item given counts.
+	Catalog stores.CatalogStore
 
-// Replenish an item in inventory
-func (i *InventoryUsecaseRepository) Replenish(item models.Item, count decimal.Decimal) (bool,
+	// stamps orders and ledger entries
+	clock  clock.Clock
+	ledger stores.LedgerStore
+	locks  itemLocks
+	// a location's name is checked and saved, one location at a time
+	locationsMu sync.Mutex
+	// reservations are read, changed and saved one change at a time, after locking their items
+	reservationsMu sync.Mutex
+	// a payment is read, changed and saved, one change at a time
+	paymentsMu sync.Mutex
+}
+
+// NewInventoryUsecaseRepository creates the usecases on top of a ledger store, telling the time by clk.
+// Tests hand in a clock.Fake.
+func NewInventoryUsecaseRepository(ledger stores.LedgerStore, clk clock.Clock) *InventoryUsecaseRepository {
+	return &InventoryUsecaseRepository{
+		DiscountPolicy: DefaultDiscountPolicy,
+		clock:          clk,
+		Locations:      stores.NewMemoryLocationStore(),
+		Reservations:   stores.NewMemoryReservationStore(),
+		ReservationTTL: DefaultReservationTTL,
+		Payments:       stores.NewMemoryPaymentStore(),
+		ledger:         ledger,
+	}
+}
+
+// Replenish an item in inventory at a location
+func (i *InventoryUsecaseRepository) Replenish(locationId uuid.UUID, item models.Item, count decimal.Decimal) (bool,
 	error) {
 	// check input
 	if uuid.Equal(item.Id, uuid.Nil) {
 		err := errors.NewError(errors.ReplenishError, "Empty item given")
 		return false, err
 	}
+	if err := i.checkLocation(locationId); err != nil {
+		return false, err
+	}
 
 	// create a replenishment order
 	order := models.Order{
-		UserId:      models.GetStoreAdmin().Id,
+		UserId:      models.GetStoreAdmin(i.clock).Id,
 		LineItems:   nil,
 		NetAmount:   decimal.Zero,
 		GrossAmount: decimal.Zero,
-		Tag:         "replenishment",
+		Type:        models.ReplenishmentOrderType,
+		LocationId:  locationId,
 		BaseFields: models.BaseFields{
 			Id:       uuid.NewV4(),
-			Created:  time.Now().UTC(),
-			Modified: time.Now().UTC(),
+			Created:  i.clock.Now(),
+			Modified: i.clock.Now(),
 			Status:   models.CompletedOrderStatus,
 		},
 	}
 
-	// find or create ledger entry
-	itemBalance := findItemBalanceInLedger(item)
+	unlock := i.locks.lock(item.Id)
+	defer unlock()
 
-	itemBalance = itemBalance.Add(count)
+	// add the ledger entry to inventory
+	if err := i.ledger.Append(i.replenishmentEntry(&order, item, count)); err != nil {
+		return false, errors.NewError(errors.ReplenishError, err.Error())
+	}
+
+	// we are done
+	return true, nil
+}
 
-	entry := models.LedgerEntry{
-		Order:   &order,
-		Item:    &item,
-		Credit:  count,
-		Debit:   decimal.Zero,
-		Balance: itemBalance,
+// The entry crediting count of item at the order's location for a replenishment order. Callers hold
+// the item's lock.
+func (i *InventoryUsecaseRepository) replenishmentEntry(order *models.Order, item models.Item,
+	count decimal.Decimal) models.LedgerEntry {
+	return models.LedgerEntry{
+		Order:      order,
+		Item:       &item,
+		LocationId: order.LocationId,
+		Credit:     count,
+		Debit:      decimal.Zero,
+		Balance:    i.findItemBalanceInLedger(order.LocationId, item).Add(count),
 		BaseFields: models.BaseFields{
 			Id:       uuid.UUID{},
-			Created:  time.Now().UTC(),
-			Modified: time.Now().UTC(),
+			Created:  i.clock.Now(),
+			Modified: i.clock.Now(),
 			Status:   models.CreatedLedgerEntryStatus,
 		},
 	}
+}
 
-	// add the ledger entry to inventory
-	models.GetMasterInventory().Ledger = append(models.GetMasterInventory().Ledger, entry)
+// Place a purchase order for a user from the stock at a location, giving the amount to pay
+func (i *InventoryUsecaseRepository) Purchase(locationId uuid.UUID, lineItems *[]models.OrderLineItem,
+	userId uuid.UUID, userDiscount int) (decimal.Decimal, error) {
+	order, err := i.PurchaseOrder(locationId, lineItems, userId, userDiscount)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return order.TotalAmount, nil
+}
 
-	// we are done
-	return true, nil
+// Place a purchase order for a user from the stock at a location, giving the order placed, pending until it is
+// paid unless there is nothing to pay. Stock reserved for carts isn't for sale.
+func (i *InventoryUsecaseRepository) PurchaseOrder(locationId uuid.UUID, lineItems *[]models.OrderLineItem,
+	userId uuid.UUID, userDiscount int) (models.Order, error) {
+	return i.purchaseOrder(uuid.Nil, locationId, lineItems, userId, userDiscount)
 }
 
-// Place a purchase order for a user
-func (i *InventoryUsecaseRepository) Purchase(lineItems *[]models.OrderLineItem,
-	userId uuid.UUID, userDiscount int) (decimal.Decimal, error) {
+// Place a purchase order that can take the stock holderId reserved, releasing its reservations once the order is
+// debited. Nil holds nothing.
+func (i *InventoryUsecaseRepository) purchaseOrder(holderId uuid.UUID, locationId uuid.UUID,
+	lineItems *[]models.OrderLineItem, userId uuid.UUID, userDiscount int) (models.Order, error) {
 	// check input
 	if len(*lineItems) == 0 || uuid.Equal(userId, uuid.Nil) {
 		err := errors.NewError(errors.OrderError, "Empty line items/user given")
-		return decimal.Zero, err
+		return models.Order{}, err
+	}
+	for _, line := range *lineItems {
+		// pricing skips such lines, the ledger wouldn't
+		if line.Quantity <= 0 {
+			err := errors.NewError(errors.OrderError, "Quantity of "+line.Item.Name+" must be positive")
+			return models.Order{}, err
+		}
+		if !i.forSale(*line.Item) {
+			err := errors.NewError(errors.OrderError, line.Item.Name+" is not available for sale")
+			return models.Order{}, err
+		}
+	}
+	if err := i.checkLocation(locationId); err != nil {
+		return models.Order{}, err
 	}
 
 	// create a purchase order
-	net, gross := CalcOrderAmounts(lineItems, userDiscount)
-	order := models.Order{
-		UserId:      userId,
-		LineItems:   *lineItems,
-		NetAmount:   net,
-		GrossAmount: gross,
-		Tag:         "purchase",
-		BaseFields: models.BaseFields{
-			Id:       uuid.NewV4(),
-			Created:  time.Now().UTC(),
-			Modified: time.Now().UTC(),
-			Status:   models.CompletedOrderStatus,
-		},
+	order := i.PriceOrder(lineItems, userDiscount)
+	order.UserId = userId
+	order.Type = models.PurchaseOrderType
+	order.LocationId = locationId
+	order.BaseFields = models.BaseFields{
+		Id:       uuid.NewV4(),
+		Created:  i.clock.Now(),
+		Modified: i.clock.Now(),
+		Status:   models.CompletedOrderStatus,
 	}
 
-	// process ledger entry for each line item
+	// deferred before the unlock, so the hook runs once the items are unlocked
+	var alerts []LowStockAlert
+	defer func() {
+		i.alertLowStock(alerts)
+	}()
+
+	itemIds := make([]uuid.UUID, 0, len(*lineItems))
+	for _, line := range *lineItems {
+		itemIds = append(itemIds, line.Item.Id)
+	}
+	unlock := i.locks.lock(itemIds...)
+	defer unlock()
+
+	// build a ledger entry for each line item before writing any of them, so an order
+	// is either debited completely or not at all
+	reserved := i.reserved(locationId, holderId)
+	entries := make([]models.LedgerEntry, 0, len(*lineItems))
+	startBalances := make(map[uuid.UUID]decimal.Decimal)
+	balances := make(map[uuid.UUID]decimal.Decimal)
+	var failure error
 	for _, line := range *lineItems {
 		itemQty := decimal.New(line.Quantity, 0)
-		itemBalance := findItemBalanceInLedger(*line.Item)
+		itemBalance, ok := balances[line.Item.Id]
+		if !ok {
+			itemBalance = i.findItemBalanceInLedger(locationId, *line.Item)
+			startBalances[line.Item.Id] = itemBalance
+		}
 
 		itemBalance = itemBalance.Sub(itemQty)
-		if itemBalance.Cmp(decimal.Zero) < 0 {
-			err := errors.NewError(errors.OrderError, "Inventory item balance will become negative")
-			return decimal.Zero, err
+		if itemBalance.Cmp(decimal.Zero) < 0 && failure == nil {
+			failure = errors.NewError(errors.OrderError, "Inventory item balance will become negative")
 		}
+		if itemBalance.Cmp(reserved[line.Item.Id]) < 0 && failure == nil {
+			failure = errors.NewError(errors.OrderError, line.Item.Name+" is reserved for other carts")
+		}
+		balances[line.Item.Id] = itemBalance
 
 		entry := models.LedgerEntry{
-			Order:   &order,
-			Item:    line.Item,
-			Credit:  decimal.Zero,
-			Debit:   itemQty,
-			Balance: itemBalance,
+			Order:      &order,
+			Item:       line.Item,
+			LocationId: locationId,
+			Credit:     decimal.Zero,
+			Debit:      itemQty,
+			Balance:    itemBalance,
 			BaseFields: models.BaseFields{
 				Id:       uuid.UUID{},
-				Created:  time.Now().UTC(),
-				Modified: time.Now().UTC(),
+				Created:  i.clock.Now(),
+				Modified: i.clock.Now(),
 				Status:   models.CreatedLedgerEntryStatus,
 			},
 		}
+		entries = append(entries, entry)
+	}
 
-		// add the ledger entry to inventory
-		models.GetMasterInventory().Ledger = append(models.GetMasterInventory().Ledger, entry)
+	if failure != nil {
+		// keep the failed order in the ledger for the audit trail, its entries don't move stock
+		order.Status = models.FailedOrderStatus
+		for n := range entries {
+			entries[n].Status = models.AbortedLedgerEnryStatus
+			entries[n].Balance = startBalances[entries[n].Item.Id]
+		}
+		if err := i.ledger.Append(entries...); err != nil {
+			return models.Order{}, errors.NewError(errors.OrderError, err.Error())
+		}
+		return models.Order{}, failure
 	}
 
-	// we are done
-	return net, nil
-}
-
-func findItemBalanceInLedger(item models.Item) decimal.Decimal {
-	ledger := models.GetMasterInventory().Ledger
-	itemBalance := decimal.Zero
-	if ledger != nil {
-		sortLedger(ledger)
-		for _, entry := range ledger {
-			if uuid.Equal(entry.Item.Id, item.Id) {
-				// found the item
-				itemBalance = entry.Balance
-				break
-			}
+	// the order is pending until it is paid, its payment is saved first so no placed order goes without one
+	payment := newPayment(&order)
+	if err := i.Payments.SavePayment(payment); err != nil {
+		return models.Order{}, errors.NewError(errors.OrderError, err.Error())
+	}
+	// add the ledger entries to inventory
+	if err := i.ledger.Append(entries...); err != nil {
+		if rollbackErr := i.Payments.DeletePayment(order.Id); rollbackErr != nil {
+			return models.Order{}, errors.NewError(errors.OrderError, err.Error()+", and the payment of the "+
+				"order that wasn't placed is left pending: "+rollbackErr.Error())
+		}
+		return models.Order{}, errors.NewError(errors.OrderError, err.Error())
+	}
+	alerts = lowStockAlerts(&order, *lineItems, startBalances, balances)
+	// the ledger's order is completed as its stock moved, the caller's has the status of its payment
+	placed := order
+	placed.Status = payment.Status
+	if !uuid.Equal(holderId, uuid.Nil) {
+		i.reservationsMu.Lock()
+		defer i.reservationsMu.Unlock()
+		// the order is placed and is returned with the error, stock still held for it would count against
+		// everyone else until the reservations expire
+		if err := i.release(holderId); err != nil {
+			return placed, errors.NewError(errors.ReservationError, "order "+order.Id.String()+
+				" is placed but its reservations weren't released: "+err.Error())
 		}
 	}
-	return itemBalance
+
+	// we are done
+	return placed, nil
 }
 
-func (i *InventoryUsecaseRepository) SaleSummary(from time.Time) (map[uuid.UUID]decimal.
-	Decimal, decimal.Decimal) {
-	summary := make(map[uuid.UUID]decimal.Decimal)
-	totalSales := decimal.Zero
-	orders := make(map[uuid.UUID]decimal.Decimal)
-
-	ledger := models.GetMasterInventory().Ledger
-	if ledger != nil {
-		sortLedger(ledger)
-		for _, entry := range ledger {
-			if entry.Modified.After(from) {
-				summary[entry.Item.Id] = summary[entry.Item.Id].Add(entry.Debit)
-				orders[entry.Order.Id] = entry.Order.NetAmount
-			}
+// Whether an item can be sold, by its status in the catalog if there is one. Items the catalog doesn't
+// have can't.
+func (i *InventoryUsecaseRepository) forSale(item models.Item) bool {
+	if i.Catalog != nil {
+		stored, ok := i.Catalog.Item(item.Id)
+		if !ok {
+			return false
 		}
+		item = stored
 	}
+	return item.Status == models.AvailableItemStatus
+}
 
-	for _, value := range orders {
-		totalSales = totalSales.Add(value)
-	}
+// Latest balance of an item at a location. Callers must hold the item lock.
+// Appends for an item happen under its lock, so the store's balance is the current one
+// even if two entries share a timestamp.
+func (i *InventoryUsecaseRepository) findItemBalanceInLedger(locationId uuid.UUID, item models.Item) decimal.Decimal {
+	return i.ledger.Balance(locationId, item.Id)
+}
 
-	return summary, totalSales
+// SaleTotals adds up the orders of a period, keeping tax collected apart from net sales
+// and refunds of returns apart from both
+type SaleTotals struct {
+	Net   decimal.Decimal
+	Tax   decimal.Decimal
+	Total decimal.Decimal
+	// Refunded to customers, tax included, and the tax in it
+	Refunds     decimal.Decimal
+	RefundedTax decimal.Decimal
+	// Quantity returned per item
+	Returned map[uuid.UUID]decimal.Decimal
 }
 
-func (i *InventoryUsecaseRepository) InventorySummary(till time.Time) map[uuid.UUID]decimal.
-	Decimal {
-	summary := make(map[uuid.UUID]decimal.Decimal)
+func (i *InventoryUsecaseRepository) SaleSummary(from time.Time) (map[uuid.UUID]decimal.
+	Decimal, SaleTotals) {
+	return i.saleSummary(i.ledger.SequenceAt(from), i.ledger.Sequence())
+}
 
-	ledger := models.GetMasterInventory().Ledger
-	if ledger != nil {
-		sortLedger(ledger)
-		for _, entry := range ledger {
-			if entry.Modified.Before(till) {
-				if _, ok := summary[entry.Item.Id]; !ok {
-					summary[entry.Item.Id] = entry.Balance
-				}
-			}
-		}
-	}
+// SaleSummaryBetween adds up the sales made from from up to but not including till
+func (i *InventoryUsecaseRepository) SaleSummaryBetween(from time.Time, till time.Time) (map[uuid.UUID]decimal.
+	Decimal, SaleTotals) {
+	return i.saleSummary(i.sequencesBetween(from, till))
+}
 
-	return summary
+// sequences bounding the entries made from from up to but not including till, for EntriesBetween.
+// SequenceAt takes entries made at the given time in, step back so from is in and till is out.
+func (i *InventoryUsecaseRepository) sequencesBetween(from time.Time, till time.Time) (uint64, uint64) {
+	return i.ledger.SequenceAt(from.Add(-time.Nanosecond)), i.ledger.SequenceAt(till.Add(-time.Nanosecond))
 }
 
-// Reverse sort inventory based on timestamp
-func sortLedger(ledger []models.LedgerEntry) {
-	sort.Slice(ledger, func(i, j int) bool {
-		return ledger[i].Modified.After(ledger[j].Modified)
-	})
+// PeriodSales is what was sold in a business day, week or month
+type PeriodSales struct {
+	From   time.Time
+	Till   time.Time
+	Items  map[uuid.UUID]decimal.Decimal
+	Totals SaleTotals
 }
 
-// Calculate net and gross amount for line items
-// Note: public for testing purposes
-func CalcOrderAmounts(lineItems *[]models.OrderLineItem, userDiscount int) (decimal.Decimal, decimal.Decimal) {
-	netAmount := decimal.Zero
-	grossAmount := decimal.Zero
-	for _, line := range *lineItems {
-		itemQty := decimal.New(line.Quantity, 0)
-		if itemQty.Cmp(decimal.Zero) <= 0 {
-			// skip negative/zero item qty
+// SalesFor adds up the sales of the business day, week or month at falls in
+func (i *InventoryUsecaseRepository) SalesFor(period ReportPeriod, at time.Time) (PeriodSales, error) {
+	from, till, err := i.Calendar.Period(period, at)
+	if err != nil {
+		return PeriodSales{}, err
+	}
+	items, totals := i.SaleSummaryBetween(from, till)
+	return PeriodSales{From: from, Till: till, Items: items, Totals: totals}, nil
+}
+
+// sales in the entries after sequence after, up to and including sequence through
+func (i *InventoryUsecaseRepository) saleSummary(after uint64, through uint64) (map[uuid.UUID]decimal.Decimal,
+	SaleTotals) {
+	summary := make(map[uuid.UUID]decimal.Decimal)
+	totals := SaleTotals{Net: decimal.Zero, Tax: decimal.Zero, Total: decimal.Zero, Refunds: decimal.Zero,
+		RefundedTax: decimal.Zero, Returned: make(map[uuid.UUID]decimal.Decimal)}
+	orders := make(map[uuid.UUID]*models.Order)
+
+	for _, entry := range i.ledger.EntriesBetween(after, through) {
+		if entry.Status == models.AbortedLedgerEnryStatus {
 			continue
 		}
+		// replenishments aren't sales
+		switch entry.Order.Type {
+		case models.PurchaseOrderType:
+			summary[entry.Item.Id] = summary[entry.Item.Id].Add(entry.Debit)
+		case models.ReturnOrderType:
+			totals.Returned[entry.Item.Id] = totals.Returned[entry.Item.Id].Add(entry.Credit)
+		default:
+			continue
+		}
+		orders[entry.Order.Id] = entry.Order
+	}
 
-		lineAmount := line.Item.Price.Mul(itemQty)
-		grossAmount = grossAmount.Add(lineAmount)
-		itemDiscount := 0
-		if line.Item.DiscountPercentage == 0 {
-			itemDiscount = line.Item.SKU.DiscountPercentage
+	for _, order := range orders {
+		if order.Type == models.ReturnOrderType {
+			totals.Refunds = totals.Refunds.Add(order.TotalAmount)
+			totals.RefundedTax = totals.RefundedTax.Add(order.TaxAmount)
+			continue
 		}
-		discount := line.Item.Price.Mul(decimal.NewFromFloat(float64(itemDiscount) / 100))
-		netAmount = netAmount.Add(lineAmount.Sub(discount))
+		totals.Net = totals.Net.Add(order.NetAmount)
+		totals.Tax = totals.Tax.Add(order.TaxAmount)
 	}
+	totals.Total = totals.Net.Add(totals.Tax)
+
+	return summary, totals
+}
 
-	userDiscountDec := netAmount.Mul(decimal.NewFromFloat(float64(userDiscount) / 100))
-	netAmount = netAmount.Sub(userDiscountDec)
-	return netAmount, grossAmount
+// Stock levels at a location at till, like InventoryAt for the stock kept there
+func (i *InventoryUsecaseRepository) InventorySummary(locationId uuid.UUID, till time.Time) map[uuid.UUID]decimal.
+	Decimal {
+	return i.ledger.LocationBalancesAsOf(locationId, i.ledger.SequenceAt(till))
+}
+
+// Exact stock level of every item at a point in time, summed across locations: the balances after every
+// entry created at or before it. Items without entries by then are left out.
+func (i *InventoryUsecaseRepository) InventoryAt(at time.Time) map[uuid.UUID]decimal.Decimal {
+	return i.ledger.BalancesAsOf(i.ledger.SequenceAt(at))
+}
+
+// Price and tax line items the way Purchase would, without placing an order
+func (i *InventoryUsecaseRepository) PriceOrder(lineItems *[]models.OrderLineItem, userDiscount int) models.Order {
+	breakdown := priceLines(i.DiscountPolicy, lineItems, userDiscount)
+	_, gross := sumBreakdowns(breakdown)
+	net, tax := i.TaxCalculator.Apply(breakdown)
+	return models.Order{
+		LineItems:    *lineItems,
+		NetAmount:    net,
+		GrossAmount:  gross,
+		TaxAmount:    tax,
+		TotalAmount:  net.Add(tax),
+		TaxInclusive: i.TaxCalculator.Inclusive,
+		Breakdown:    breakdown,
+		BaseFields:   models.BaseFields{Status: models.PendingOrderStatus},
+	}
+}
+
+// Calculate net and gross amount for line items with the default discount policy
+// Note: public for testing purposes
+func CalcOrderAmounts(lineItems *[]models.OrderLineItem, userDiscount int) (decimal.Decimal, decimal.Decimal) {
+	return calcOrderAmounts(DefaultDiscountPolicy, lineItems, userDiscount)
 }
@@ -1,10 +1,15 @@
 package usecases
 
 import (
+	"clock"
 	"fmt"
 	"math/rand"
 	"models"
 	"reflect"
+	"runtime"
+	"stores"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -12,7 +17,7 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-var testRepo = new(InventoryUsecaseRepository)
+var testRepo = NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
 
 func TestInventoryUsecaseRepository_Replenish(t *testing.T) {
 	type args struct {
@@ -83,7 +88,7 @@ func TestInventoryUsecaseRepository_Replenish(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.InventoryUsecaseRepository.Replenish(tt.args.item, tt.args.count)
+			got, err := tt.InventoryUsecaseRepository.Replenish(models.DefaultLocationId, tt.args.item, tt.args.count)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("InventoryUsecaseRepository.Replenish() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -101,8 +106,8 @@ func TestInventoryUsecaseRepository_Purchase(t *testing.T) {
 	fM.InitInventory()
 	fM.InitUsers()
 	for _, item := range fM.Items {
-		// add to inventory
-		ok, err := testRepo.Replenish(item, decimal.New(rand.Int63n(10), 0))
+		// add to inventory, enough to cover every order below
+		ok, err := testRepo.Replenish(models.DefaultLocationId, item, decimal.New(rand.Int63n(10)+5, 0))
 		if !ok || err != nil {
 			fmt.Println("Replenish failed, try again later...")
 			break
@@ -157,6 +162,17 @@ func TestInventoryUsecaseRepository_Purchase(t *testing.T) {
 			want:    decimal.Zero,
 			wantErr: true,
 		},
+		{
+			name: "Test negative quantity",
+			InventoryUsecaseRepository: testRepo,
+			args: args{
+				lineItems:    &[]models.OrderLineItem{{Item: fM.GetMockedItem(1), Quantity: -10}},
+				userId:       fM.GetMockedUser(1).Id,
+				userDiscount: 0,
+			},
+			want:    decimal.Zero,
+			wantErr: true,
+		},
 		{
 			name: "Test single item purchase order",
 			InventoryUsecaseRepository: testRepo,
@@ -182,7 +198,8 @@ func TestInventoryUsecaseRepository_Purchase(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.InventoryUsecaseRepository.Purchase(tt.args.lineItems, tt.args.userId, tt.args.userDiscount)
+			got, err := tt.InventoryUsecaseRepository.Purchase(models.DefaultLocationId, tt.args.lineItems, tt.args.userId,
+				tt.args.userDiscount)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("InventoryUsecaseRepository.Purchase() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -192,7 +209,273 @@ func TestInventoryUsecaseRepository_Purchase(t *testing.T) {
 			}
 		})
 	}
+}
+
+// yields after every read so registers interleave between a balance check and its append
+type yieldingLedgerStore struct {
+	stores.LedgerStore
+}
+
+func (s yieldingLedgerStore) Entries() []models.LedgerEntry {
+	entries := s.LedgerStore.Entries()
+	runtime.Gosched()
+	return entries
+}
+
+func (s yieldingLedgerStore) Balance(locationId uuid.UUID, itemId uuid.UUID) decimal.Decimal {
+	balance := s.LedgerStore.Balance(locationId, itemId)
+	runtime.Gosched()
+	return balance
+}
+
+func TestInventoryUsecaseRepository_ConcurrentPurchase(t *testing.T) {
+	// setup: two items shared by every register, with less stock than the registers want
+	repo := NewInventoryUsecaseRepository(yieldingLedgerStore{stores.NewMemoryLedgerStore()}, clock.System)
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	first, second := fM.GetMockedItem(0), fM.GetMockedItem(1)
+	const stock = 150
+	for _, item := range []*models.Item{first, second} {
+		if ok, err := repo.Replenish(models.DefaultLocationId, *item, decimal.New(stock, 0)); !ok || err != nil {
+			t.Fatalf("InventoryUsecaseRepository.Replenish() error = %v", err)
+		}
+	}
+
+	const registers = 300
+	var wg sync.WaitGroup
+	var succeeded int64
+	start := make(chan struct{})
+	for r := 0; r < registers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			<-start
+			// alternate line order so registers lock the same items from both ends
+			lineItems := []models.OrderLineItem{{Item: first, Quantity: 1}, {Item: second, Quantity: 1}}
+			if r%2 == 1 {
+				lineItems[0], lineItems[1] = lineItems[1], lineItems[0]
+			}
+			if r%10 == 0 {
+				// a replenishment racing with the purchases must not be lost either
+				repo.Replenish(models.DefaultLocationId, *first, decimal.New(1, 0))
+			}
+			if _, err := repo.Purchase(models.DefaultLocationId, &lineItems, fM.GetMockedUser(0).Id, 0); err == nil {
+				atomic.AddInt64(&succeeded, 1)
+			}
+		}(r)
+	}
+	// release every register at once
+	close(start)
+	wg.Wait()
 
-	// teardown
-	fM.ClearMockModelCache()
+	for _, entry := range repo.ledger.Entries() {
+		if entry.Balance.Sign() < 0 {
+			t.Fatalf("ledger balance of %s went negative: %s", entry.Item.Name, entry.Balance)
+		}
+	}
+	for _, item := range []*models.Item{first, second} {
+		debits := decimal.Zero
+		credits := decimal.Zero
+		for _, entry := range repo.ledger.Entries() {
+			if uuid.Equal(entry.Item.Id, item.Id) && entry.Status != models.AbortedLedgerEnryStatus {
+				debits = debits.Add(entry.Debit)
+				credits = credits.Add(entry.Credit)
+			}
+		}
+		balance := repo.findItemBalanceInLedger(models.DefaultLocationId, *item)
+		if !balance.Equal(credits.Sub(debits)) {
+			t.Errorf("%s balance = %s, want credits - debits = %s", item.Name, balance, credits.Sub(debits))
+		}
+		if !debits.Equal(decimal.New(succeeded, 0)) {
+			t.Errorf("%s debited %s, want one per successful purchase (%d)", item.Name, debits, succeeded)
+		}
+	}
+	// the second item never gets restocked, so exactly its stock can be sold
+	if succeeded != stock {
+		t.Errorf("%d purchases succeeded, want %d", succeeded, stock)
+	}
+}
+
+func TestInventoryUsecaseRepository_PurchaseAllOrNothing(t *testing.T) {
+	// setup
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	for _, item := range fM.Items {
+		if ok, err := repo.Replenish(models.DefaultLocationId, item, decimal.New(5, 0)); !ok || err != nil {
+			t.Fatalf("InventoryUsecaseRepository.Replenish() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		lineItems []models.OrderLineItem
+		wantErr   bool
+		want      []int64
+	}{
+		{
+			name: "Test last line overdraws",
+			lineItems: []models.OrderLineItem{
+				{Item: fM.GetMockedItem(0), Quantity: 2},
+				{Item: fM.GetMockedItem(1), Quantity: 1},
+				{Item: fM.GetMockedItem(2), Quantity: 6},
+			},
+			wantErr: true,
+			want:    []int64{5, 5, 5},
+		},
+		{
+			name: "Test same item overdrawn across lines",
+			lineItems: []models.OrderLineItem{
+				{Item: fM.GetMockedItem(0), Quantity: 3},
+				{Item: fM.GetMockedItem(0), Quantity: 3},
+			},
+			wantErr: true,
+			want:    []int64{5, 5, 5},
+		},
+		{
+			name: "Test every line fits",
+			lineItems: []models.OrderLineItem{
+				{Item: fM.GetMockedItem(0), Quantity: 2},
+				{Item: fM.GetMockedItem(1), Quantity: 1},
+				{Item: fM.GetMockedItem(0), Quantity: 3},
+			},
+			wantErr: false,
+			want:    []int64{0, 4, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(repo.ledger.Entries())
+			_, err := repo.Purchase(models.DefaultLocationId, &tt.lineItems, fM.GetMockedUser(0).Id, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InventoryUsecaseRepository.Purchase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for n, want := range tt.want {
+				got := repo.findItemBalanceInLedger(models.DefaultLocationId, *fM.GetMockedItem(n))
+				if !got.Equal(decimal.New(want, 0)) {
+					t.Errorf("%s balance = %s, want %d", fM.GetMockedItem(n).Name, got, want)
+				}
+			}
+
+			// every line is in the ledger either way, a failed order keeps its trail
+			entries := repo.ledger.Entries()[before:]
+			if len(entries) != len(tt.lineItems) {
+				t.Fatalf("Purchase() appended %d entries, want %d", len(entries), len(tt.lineItems))
+			}
+			for _, entry := range entries {
+				wantOrder, wantEntry := models.CompletedOrderStatus, models.CreatedLedgerEntryStatus
+				if tt.wantErr {
+					wantOrder, wantEntry = models.FailedOrderStatus, models.AbortedLedgerEnryStatus
+				}
+				if entry.Order.Status != wantOrder || entry.Status != wantEntry {
+					t.Errorf("entry status = %s/%s, want %s/%s", entry.Order.Status, entry.Status, wantOrder, wantEntry)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryUsecaseRepository_InventoryAt(t *testing.T) {
+	tuesday := time.Date(2017, 6, 6, 9, 0, 0, 0, time.UTC)
+	now := clock.NewFake(tuesday)
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), now)
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	dora, teddy := fM.GetMockedItem(0), fM.GetMockedItem(1)
+	buy := func(item *models.Item, qty int64) {
+		lineItems := []models.OrderLineItem{{Item: item, Quantity: qty}}
+		if _, err := repo.Purchase(models.DefaultLocationId, &lineItems, fM.GetMockedUser(0).Id, 0); err != nil {
+			t.Fatalf("InventoryUsecaseRepository.Purchase() error = %v", err)
+		}
+	}
+
+	// Tuesday 9:00 stock arrives
+	repo.Replenish(models.DefaultLocationId, *dora, decimal.New(10, 0))
+	repo.Replenish(models.DefaultLocationId, *teddy, decimal.New(4, 0))
+	// 12:00 three sales in the same instant, one of them failing
+	now.Set(tuesday.Add(3 * time.Hour))
+	buy(dora, 3)
+	buy(dora, 2)
+	failed := []models.OrderLineItem{{Item: teddy, Quantity: 1}, {Item: dora, Quantity: 20}}
+	repo.Purchase(models.DefaultLocationId, &failed, fM.GetMockedUser(0).Id, 0)
+	// Wednesday more stock
+	now.Set(tuesday.AddDate(0, 0, 1))
+	repo.Replenish(models.DefaultLocationId, *dora, decimal.New(5, 0))
+	buy(teddy, 4)
+
+	tests := []struct {
+		name        string
+		at          time.Time
+		dora, teddy string
+	}{
+		{name: "Monday", at: tuesday.AddDate(0, 0, -1), dora: "", teddy: ""},
+		{name: "Tuesday stock arrives", at: tuesday, dora: "10", teddy: "4"},
+		{name: "Tuesday before the sales", at: tuesday.Add(3*time.Hour - time.Nanosecond), dora: "10", teddy: "4"},
+		{name: "Tuesday sales", at: tuesday.Add(3 * time.Hour), dora: "5", teddy: "4"},
+		{name: "Wednesday", at: tuesday.AddDate(0, 0, 1), dora: "10", teddy: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := repo.InventoryAt(tt.at)
+			for _, want := range []struct {
+				item     *models.Item
+				quantity string
+			}{{dora, tt.dora}, {teddy, tt.teddy}} {
+				balance, ok := got[want.item.Id]
+				if want.quantity == "" {
+					if ok {
+						t.Errorf("InventoryUsecaseRepository.InventoryAt() %s = %s, want no stock recorded yet", want.item.Name, balance)
+					}
+					continue
+				}
+				if !balance.Equal(mustDecimal(want.quantity)) {
+					t.Errorf("InventoryUsecaseRepository.InventoryAt() %s = %s, want %s", want.item.Name, balance, want.quantity)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryUsecaseRepository_SalesAcrossMidnight(t *testing.T) {
+	midnight := time.Date(2017, 6, 7, 0, 0, 0, 0, time.UTC)
+	now := clock.NewFake(midnight.Add(-time.Hour))
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), now)
+	fM := new(models.Mocks)
+	fM.InitInventory()
+	fM.InitUsers()
+	dora := fM.GetMockedItem(0)
+	repo.Replenish(models.DefaultLocationId, *dora, decimal.New(10, 0))
+
+	// one sale a minute before midnight, two a minute after
+	for _, at := range []time.Duration{-time.Minute, time.Minute, time.Minute} {
+		now.Set(midnight.Add(at))
+		lineItems := []models.OrderLineItem{{Item: dora, Quantity: 1}}
+		if _, err := repo.Purchase(models.DefaultLocationId, &lineItems, fM.GetMockedUser(0).Id, 0); err != nil {
+			t.Fatalf("InventoryUsecaseRepository.Purchase() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		from time.Time
+		sold int64
+	}{
+		{name: "the whole evening", from: midnight.Add(-time.Hour), sold: 3},
+		{name: "since midnight", from: midnight, sold: 2},
+		{name: "after the last sale", from: midnight.Add(time.Hour), sold: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, _ := repo.SaleSummary(tt.from)
+			if got := items[dora.Id]; !got.Equal(decimal.New(tt.sold, 0)) {
+				t.Errorf("InventoryUsecaseRepository.SaleSummary() %s = %s, want %d", dora.Name, got, tt.sold)
+			}
+		})
+	}
+	if got := repo.InventoryAt(midnight)[dora.Id]; !got.Equal(decimal.New(9, 0)) {
+		t.Errorf("InventoryUsecaseRepository.InventoryAt() midnight = %s, want 9", got)
+	}
 }
@@ -0,0 +1,62 @@
+package usecases
+
+import (
+	"bytes"
+	"github.com/satori/go.uuid"
+	"sort"
+	"sync"
+)
+
+// itemLocks serialises ledger updates per item, so a balance check and the entry appended
+// after it can't interleave with another register working on the same item
+type itemLocks struct {
+	mu    sync.Mutex
+	locks map[uuid.UUID]*sync.Mutex
+}
+
+// lock takes the locks of all given items and returns a func releasing them.
+// Locks are always taken in id order so two orders sharing items can't deadlock.
+func (l *itemLocks) lock(ids ...uuid.UUID) func() {
+	ids = uniqueSortedIds(ids)
+
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[uuid.UUID]*sync.Mutex)
+	}
+	held := make([]*sync.Mutex, 0, len(ids))
+	for _, id := range ids {
+		m, ok := l.locks[id]
+		if !ok {
+			m = new(sync.Mutex)
+			l.locks[id] = m
+		}
+		held = append(held, m)
+	}
+	l.mu.Unlock()
+
+	for _, m := range held {
+		m.Lock()
+	}
+
+	return func() {
+		for n := len(held) - 1; n >= 0; n-- {
+			held[n].Unlock()
+		}
+	}
+}
+
+func uniqueSortedIds(ids []uuid.UUID) []uuid.UUID {
+	sorted := make([]uuid.UUID, len(ids))
+	copy(sorted, ids)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Bytes(), sorted[j].Bytes()) < 0
+	})
+
+	unique := make([]uuid.UUID, 0, len(sorted))
+	for _, id := range sorted {
+		if len(unique) == 0 || !uuid.Equal(id, unique[len(unique)-1]) {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,65 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"models"
+	"strings"
+)
+
+// AddLocation adds a shop, warehouse or back room to keep stock at, names must be unique
+func (i *InventoryUsecaseRepository) AddLocation(name string, kind string) (models.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.Location{}, errors.NewError(errors.LocationError, "Location needs a name")
+	}
+	known := false
+	for _, k := range models.LocationKinds {
+		known = known || k == kind
+	}
+	if !known {
+		return models.Location{}, errors.NewError(errors.LocationError, "unknown kind "+kind+", use one of "+
+			strings.Join(models.LocationKinds, ", "))
+	}
+
+	i.locationsMu.Lock()
+	defer i.locationsMu.Unlock()
+	if _, err := i.FindLocation(name); err == nil {
+		return models.Location{}, errors.NewError(errors.LocationError, "there already is a location "+name)
+	}
+	location := models.Location{
+		Name: name,
+		Kind: kind,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  i.clock.Now(),
+			Modified: i.clock.Now(),
+			Status:   models.EnabledLocationStatus,
+		},
+	}
+	if err := i.Locations.SaveLocation(location); err != nil {
+		return models.Location{}, errors.NewError(errors.LocationError, err.Error())
+	}
+	return location, nil
+}
+
+// FindLocation looks a location up by id or, ignoring case, by name
+func (i *InventoryUsecaseRepository) FindLocation(ref string) (models.Location, error) {
+	if location, ok := i.Locations.Location(uuid.FromStringOrNil(ref)); ok {
+		return location, nil
+	}
+	for _, location := range i.Locations.Locations() {
+		if strings.EqualFold(location.Name, strings.TrimSpace(ref)) {
+			return location, nil
+		}
+	}
+	return models.Location{}, errors.NewError(errors.NotFoundError, "location "+ref)
+}
+
+// stock can only be kept at known locations
+func (i *InventoryUsecaseRepository) checkLocation(locationId uuid.UUID) error {
+	if _, ok := i.Locations.Location(locationId); !ok {
+		return errors.NewError(errors.NotFoundError, "location "+locationId.String())
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package usecases
+
+import (
+	"clock"
+	"error"
+	"models"
+	"stores"
+	"testing"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestInventoryUsecaseRepository_Locations(t *testing.T) {
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	user := uuid.NewV4()
+	lego := models.Item{Name: "Lego", Price: decimal.New(5, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+
+	wantError := func(what string, err error, errorType int) {
+		e, ok := err.(errors.ApplicationError)
+		if !ok || e.ErrorType != errors.ErrorMap[errorType].ErrorType {
+			t.Errorf("InventoryUsecaseRepository %s error = %v, want %s", what, err, errors.ErrorMap[errorType].Message)
+		}
+	}
+	warehouse, err := repo.AddLocation(" Warehouse ", models.WarehouseLocationKind)
+	if err != nil {
+		t.Fatalf("InventoryUsecaseRepository.AddLocation() error = %v", err)
+	}
+	_, err = repo.AddLocation("warehouse", models.BackRoomLocationKind)
+	wantError("AddLocation() twice", err, errors.LocationError)
+	_, err = repo.AddLocation("Van", "van")
+	wantError("AddLocation() of an unknown kind", err, errors.LocationError)
+	if found, err := repo.FindLocation("WAREHOUSE"); err != nil || !uuid.Equal(found.Id, warehouse.Id) {
+		t.Errorf("InventoryUsecaseRepository.FindLocation() = %v, %v, want %s", found, err, warehouse.Id)
+	}
+	_, err = repo.Replenish(uuid.NewV4(), lego, decimal.New(1, 0))
+	wantError("Replenish() at an unknown location", err, errors.NotFoundError)
+
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(2, 0))
+	repo.Replenish(warehouse.Id, lego, decimal.New(10, 0))
+	_, err = repo.PurchaseOrder(models.DefaultLocationId, &[]models.OrderLineItem{{Item: &lego, Quantity: 3}}, user, 0)
+	wantError("PurchaseOrder() of stock kept elsewhere", err, errors.OrderError)
+	order, err := repo.PurchaseOrder(warehouse.Id, &[]models.OrderLineItem{{Item: &lego, Quantity: 4}}, user, 0)
+	if err != nil {
+		t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+	}
+	if _, err := repo.Pay(order.Id, models.Tender{Type: models.CardTenderType, Tendered: order.TotalAmount}); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Pay() error = %v", err)
+	}
+	// returns go back where they were sold
+	if _, err := repo.Return(order.Id, &[]models.OrderLineItem{{Item: &lego, Quantity: 1}}); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Return() error = %v", err)
+	}
+
+	now := repo.clock.Now()
+	tests := []struct {
+		name string
+		got  map[uuid.UUID]decimal.Decimal
+		want int64
+	}{
+		{name: "main store", got: repo.InventorySummary(models.DefaultLocationId, now), want: 2},
+		{name: "warehouse", got: repo.InventorySummary(warehouse.Id, now), want: 7},
+		{name: "consolidated", got: repo.InventoryAt(now), want: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.got[lego.Id].Equal(decimal.New(tt.want, 0)) {
+				t.Errorf("stock of Lego = %s, want %d", tt.got[lego.Id], tt.want)
+			}
+		})
+	}
+}
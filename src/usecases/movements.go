@@ -0,0 +1,40 @@
+package usecases
+
+import (
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"time"
+)
+
+// StockMovement is how much stock of an item came in and went out
+type StockMovement struct {
+	In  decimal.Decimal
+	Out decimal.Decimal
+}
+
+// StockMovements breaks the stock moved from from up to but not including till down by order type and item.
+// Aborted entries didn't move stock and are left out.
+func (i *InventoryUsecaseRepository) StockMovements(from time.Time, till time.Time) map[models.OrderType]map[uuid.
+	UUID]StockMovement {
+	movements := make(map[models.OrderType]map[uuid.UUID]StockMovement)
+	after, through := i.sequencesBetween(from, till)
+	for _, entry := range i.ledger.EntriesBetween(after, through) {
+		if entry.Status == models.AbortedLedgerEnryStatus {
+			continue
+		}
+		items, ok := movements[entry.Order.Type]
+		if !ok {
+			items = make(map[uuid.UUID]StockMovement)
+			movements[entry.Order.Type] = items
+		}
+		movement, ok := items[entry.Item.Id]
+		if !ok {
+			movement = StockMovement{In: decimal.Zero, Out: decimal.Zero}
+		}
+		movement.In = movement.In.Add(entry.Credit)
+		movement.Out = movement.Out.Add(entry.Debit)
+		items[entry.Item.Id] = movement
+	}
+	return movements
+}
@@ -0,0 +1,215 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"time"
+)
+
+// Pay takes tenders towards a purchase order, in the order given, all or nothing, so a split payment is one
+// call. Cash handed over beyond what is left to pay is given back as change, cards and store credit can't
+// give change. The order stays pending until it is paid in full.
+func (i *InventoryUsecaseRepository) Pay(orderId uuid.UUID, tenders ...models.Tender) (models.Payment, error) {
+	// check input
+	if len(tenders) == 0 {
+		return models.Payment{}, errors.NewError(errors.PaymentError, "Empty tenders given")
+	}
+	for _, tender := range tenders {
+		if !validTenderType(tender.Type) {
+			return models.Payment{}, errors.NewError(errors.PaymentError, "unknown tender type "+tender.Type)
+		}
+		if tender.Tendered.Sign() <= 0 {
+			return models.Payment{}, errors.NewError(errors.PaymentError, tender.Type+" tendered must be positive")
+		}
+	}
+
+	i.paymentsMu.Lock()
+	defer i.paymentsMu.Unlock()
+	payment, err := i.Payment(orderId)
+	if err != nil {
+		return payment, err
+	}
+	if payment.Status != models.PendingOrderStatus {
+		return payment, errors.NewError(errors.PaymentError, "order "+orderId.String()+" is paid")
+	}
+
+	for _, tender := range tenders {
+		outstanding := payment.Outstanding()
+		if outstanding.Sign() <= 0 {
+			return models.Payment{}, errors.NewError(errors.PaymentError, "nothing left to pay for the "+
+				tender.Type+" tendered")
+		}
+		tender.Amount = tender.Tendered
+		tender.Change = decimal.Zero
+		if tender.Tendered.Cmp(outstanding) > 0 {
+			if tender.Type != models.CashTenderType {
+				return models.Payment{}, errors.NewError(errors.PaymentError, "only "+outstanding.StringFixed(2)+
+					" left to pay, "+tender.Type+" can't give change")
+			}
+			tender.Amount = outstanding
+			tender.Change = tender.Tendered.Sub(outstanding)
+		}
+		tender.Created = i.clock.Now()
+		payment.Tenders = append(payment.Tenders, tender)
+		payment.Paid = payment.Paid.Add(tender.Amount)
+	}
+	if payment.Outstanding().Sign() <= 0 {
+		payment.Status = models.CompletedOrderStatus
+	}
+	payment.Modified = i.clock.Now()
+	if err := i.Payments.SavePayment(payment); err != nil {
+		return models.Payment{}, errors.NewError(errors.PaymentError, err.Error())
+	}
+	return payment, nil
+}
+
+// Payment looks up how a purchase order was paid so far. Its status is the order's: pending until the order is
+// paid in full.
+func (i *InventoryUsecaseRepository) Payment(orderId uuid.UUID) (models.Payment, error) {
+	payment, ok := i.Payments.Payment(orderId)
+	if !ok {
+		return models.Payment{}, errors.NewError(errors.NotFoundError, "order "+orderId.String())
+	}
+	return payment, nil
+}
+
+// UnpaidOrders returns the payments of the purchase orders not paid in full, oldest first
+func (i *InventoryUsecaseRepository) UnpaidOrders() []models.Payment {
+	return i.Payments.Pending()
+}
+
+// SettlePastOrders records the purchase orders placed before payments were taken as paid in full, with no
+// tenders, so they don't count as unpaid. Run it once the payments are opened; orders that have a payment
+// are left alone, so running it again records nothing. It gives the number of orders recorded.
+func (i *InventoryUsecaseRepository) SettlePastOrders() (int, error) {
+	i.paymentsMu.Lock()
+	defer i.paymentsMu.Unlock()
+	settled := 0
+	seen := make(map[uuid.UUID]bool)
+	for _, entry := range i.ledger.Entries() {
+		if entry.Status == models.AbortedLedgerEnryStatus || entry.Order == nil ||
+			entry.Order.Type != models.PurchaseOrderType || seen[entry.Order.Id] {
+			continue
+		}
+		seen[entry.Order.Id] = true
+		if _, ok := i.Payments.Payment(entry.Order.Id); ok {
+			continue
+		}
+		payment := newPayment(entry.Order)
+		payment.Paid = payment.Due
+		payment.Status = models.CompletedOrderStatus
+		if err := i.Payments.SavePayment(payment); err != nil {
+			return settled, errors.NewError(errors.PaymentError, err.Error())
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+// DropOrphanedPayments deletes the pending payments of orders that aren't in the ledger. A payment is saved
+// before its order is appended, so a crash in between leaves one behind for an order that was never placed.
+// Run it once the payments are opened, before any order is placed. It gives the number of payments deleted.
+func (i *InventoryUsecaseRepository) DropOrphanedPayments() (int, error) {
+	i.paymentsMu.Lock()
+	defer i.paymentsMu.Unlock()
+	placed := make(map[uuid.UUID]bool)
+	for _, entry := range i.ledger.Entries() {
+		if entry.Status != models.AbortedLedgerEnryStatus && entry.Order != nil {
+			placed[entry.Order.Id] = true
+		}
+	}
+	dropped := 0
+	for _, payment := range i.Payments.Pending() {
+		if placed[payment.OrderId] {
+			continue
+		}
+		if err := i.Payments.DeletePayment(payment.OrderId); err != nil {
+			return dropped, errors.NewError(errors.PaymentError, err.Error())
+		}
+		dropped++
+	}
+	return dropped, nil
+}
+
+// TenderLine adds up the tenders of one type
+type TenderLine struct {
+	Count int
+	// What went towards orders, and the change given back on top of it
+	Amount decimal.Decimal
+	Change decimal.Decimal
+}
+
+// TenderReport adds up what was taken per tender type in a period
+type TenderReport struct {
+	From    time.Time
+	Till    time.Time
+	Tenders map[string]TenderLine
+	// What all tenders paid
+	Total decimal.Decimal
+	// Left to pay on the purchase orders of the period
+	Unpaid decimal.Decimal
+}
+
+// TenderReport reports the tenders taken from from up to but not including till
+func (i *InventoryUsecaseRepository) TenderReport(from time.Time, till time.Time) TenderReport {
+	report := TenderReport{
+		From:    from,
+		Till:    till,
+		Tenders: make(map[string]TenderLine),
+		Total:   decimal.Zero,
+		Unpaid:  decimal.Zero,
+	}
+	for _, payment := range i.Payments.Payments() {
+		for _, tender := range payment.Tenders {
+			if tender.Created.Before(from) || !tender.Created.Before(till) {
+				continue
+			}
+			line, ok := report.Tenders[tender.Type]
+			if !ok {
+				line = TenderLine{Amount: decimal.Zero, Change: decimal.Zero}
+			}
+			line.Count++
+			line.Amount = line.Amount.Add(tender.Amount)
+			line.Change = line.Change.Add(tender.Change)
+			report.Tenders[tender.Type] = line
+			report.Total = report.Total.Add(tender.Amount)
+		}
+	}
+	for _, payment := range i.Payments.Pending() {
+		if !payment.Created.Before(from) && payment.Created.Before(till) {
+			report.Unpaid = report.Unpaid.Add(payment.Outstanding())
+		}
+	}
+	return report
+}
+
+// the payment of an order just placed, nothing paid yet. An order with nothing to pay is paid already.
+func newPayment(order *models.Order) models.Payment {
+	payment := models.Payment{
+		OrderId: order.Id,
+		UserId:  order.UserId,
+		Due:     order.TotalAmount,
+		Paid:    decimal.Zero,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  order.Created,
+			Modified: order.Created,
+			Status:   models.PendingOrderStatus,
+		},
+	}
+	if payment.Due.Sign() <= 0 {
+		payment.Status = models.CompletedOrderStatus
+	}
+	return payment
+}
+
+func validTenderType(tenderType string) bool {
+	for _, t := range models.TenderTypes {
+		if t == tenderType {
+			return true
+		}
+	}
+	return false
+}
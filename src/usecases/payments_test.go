@@ -0,0 +1,166 @@
+package usecases
+
+import (
+	"clock"
+	"error"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestInventoryUsecaseRepository_Pay(t *testing.T) {
+	start := time.Date(2017, 6, 6, 9, 0, 0, 0, time.UTC)
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.NewFake(start))
+	lego := models.Item{Name: "Lego", Price: decimal.New(10, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(10, 0))
+	user := uuid.NewV4()
+
+	wantError := func(what string, err error, errorType int) {
+		e, ok := err.(errors.ApplicationError)
+		if !ok || e.ErrorType != errors.ErrorMap[errorType].ErrorType {
+			t.Errorf("InventoryUsecaseRepository %s error = %v, want %s", what, err, errors.ErrorMap[errorType].Message)
+		}
+	}
+	buy := func(quantity int64) models.Order {
+		order, err := repo.PurchaseOrder(models.DefaultLocationId, &[]models.OrderLineItem{{Item: &lego,
+			Quantity: quantity}}, user, 0)
+		if err != nil {
+			t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+		}
+		return order
+	}
+	tender := func(tenderType string, tendered int64) models.Tender {
+		return models.Tender{Type: tenderType, Tendered: decimal.New(tendered, 0)}
+	}
+
+	order := buy(3)
+	if order.Status != models.PendingOrderStatus {
+		t.Errorf("InventoryUsecaseRepository.PurchaseOrder() status = %s, want it pending until paid", order.Status)
+	}
+	if payment, err := repo.Payment(order.Id); err != nil || payment.Status != models.PendingOrderStatus ||
+		!payment.Outstanding().Equal(decimal.New(30, 0)) {
+		t.Errorf("InventoryUsecaseRepository.Payment() of a new order = %+v, %v, want 30 pending", payment, err)
+	}
+	_, err := repo.Payment(uuid.NewV4())
+	wantError("Payment() of an unknown order", err, errors.NotFoundError)
+	_, err = repo.Pay(order.Id, tender("cheque", 30))
+	wantError("Pay() by cheque", err, errors.PaymentError)
+	_, err = repo.Pay(order.Id, tender(models.CardTenderType, 31))
+	wantError("Pay() more than owed by card", err, errors.PaymentError)
+
+	// a partial payment leaves the order pending
+	payment, err := repo.Pay(order.Id, tender(models.StoreCreditTenderType, 5))
+	if err != nil || payment.Status != models.PendingOrderStatus || !payment.Outstanding().Equal(decimal.New(25, 0)) {
+		t.Fatalf("InventoryUsecaseRepository.Pay() with store credit = %+v, %v, want 25 pending", payment, err)
+	}
+	if unpaid := repo.UnpaidOrders(); len(unpaid) != 1 || !uuid.Equal(unpaid[0].OrderId, order.Id) {
+		t.Errorf("InventoryUsecaseRepository.UnpaidOrders() = %+v, want the order", unpaid)
+	}
+	// the rest is split between card and cash, with change for the cash
+	_, err = repo.Pay(order.Id, tender(models.CardTenderType, 20), tender(models.CashTenderType, 10),
+		tender(models.CardTenderType, 1))
+	wantError("Pay() of a tender after the order is paid", err, errors.PaymentError)
+	payment, err = repo.Pay(order.Id, tender(models.CardTenderType, 20), tender(models.CashTenderType, 10))
+	if err != nil || payment.Status != models.CompletedOrderStatus || len(payment.Tenders) != 3 {
+		t.Fatalf("InventoryUsecaseRepository.Pay() split = %+v, %v, want it paid with three tenders", payment, err)
+	}
+	if cash := payment.Tenders[2]; !cash.Amount.Equal(decimal.New(5, 0)) || !cash.Change.Equal(decimal.New(5, 0)) {
+		t.Errorf("InventoryUsecaseRepository.Pay() cash tender = %+v, want 5 paid and 5 change", cash)
+	}
+	_, err = repo.Pay(order.Id, tender(models.CashTenderType, 1))
+	wantError("Pay() of a paid order", err, errors.PaymentError)
+
+	// the day's tenders, and what the second order still owes
+	unpaid := buy(1)
+	report := repo.SalesReport(start, start.Add(time.Hour))
+	tenders := report.Tenders
+	if card := tenders.Tenders[models.CardTenderType]; card.Count != 1 || !card.Amount.Equal(decimal.New(20, 0)) {
+		t.Errorf("SalesReport() card tenders = %+v, want one of 20", card)
+	}
+	if cash := tenders.Tenders[models.CashTenderType]; !cash.Amount.Equal(decimal.New(5, 0)) ||
+		!cash.Change.Equal(decimal.New(5, 0)) {
+		t.Errorf("SalesReport() cash tenders = %+v, want 5 and 5 change", cash)
+	}
+	if !tenders.Total.Equal(decimal.New(30, 0)) || !tenders.Unpaid.Equal(unpaid.TotalAmount) {
+		t.Errorf("SalesReport() tenders total %s and unpaid %s, want 30 and %s", tenders.Total, tenders.Unpaid,
+			unpaid.TotalAmount)
+	}
+}
+
+func TestInventoryUsecaseRepository_SettlePastOrders(t *testing.T) {
+	ledger := &failingLedgerStore{LedgerStore: stores.NewMemoryLedgerStore()}
+	before := NewInventoryUsecaseRepository(ledger, clock.System)
+	lego := models.Item{Name: "Lego", Price: decimal.New(10, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	before.Replenish(models.DefaultLocationId, lego, decimal.New(10, 0))
+	buy := func(repo *InventoryUsecaseRepository) (models.Order, error) {
+		return repo.PurchaseOrder(models.DefaultLocationId, &[]models.OrderLineItem{{Item: &lego, Quantity: 1}},
+			uuid.NewV4(), 0)
+	}
+	past, err := buy(before)
+	if err != nil {
+		t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+	}
+
+	// the same ledger with a payment store that never heard of the order
+	repo := NewInventoryUsecaseRepository(ledger, clock.System)
+	if settled, err := repo.SettlePastOrders(); err != nil || settled != 1 {
+		t.Fatalf("InventoryUsecaseRepository.SettlePastOrders() = %d, %v, want 1", settled, err)
+	}
+	if payment, err := repo.Payment(past.Id); err != nil || payment.Status != models.CompletedOrderStatus ||
+		payment.Outstanding().Sign() != 0 || len(payment.Tenders) != 0 {
+		t.Errorf("InventoryUsecaseRepository.Payment() of a past order = %+v, %v, want it paid", payment, err)
+	}
+	pending, err := buy(repo)
+	if err != nil {
+		t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+	}
+	if settled, err := repo.SettlePastOrders(); err != nil || settled != 0 {
+		t.Errorf("InventoryUsecaseRepository.SettlePastOrders() again = %d, %v, want 0", settled, err)
+	}
+	if unpaid := repo.UnpaidOrders(); len(unpaid) != 1 || !uuid.Equal(unpaid[0].OrderId, pending.Id) {
+		t.Errorf("InventoryUsecaseRepository.UnpaidOrders() = %+v, want the new order only", unpaid)
+	}
+
+	// an order that couldn't be placed leaves no payment behind
+	ledger.fail = true
+	if _, err := buy(repo); err == nil {
+		t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() with a failing ledger succeeded")
+	}
+	if payments := repo.Payments.Payments(); len(payments) != 2 {
+		t.Errorf("InventoryUsecaseRepository.Payments has %d payments, want 2", len(payments))
+	}
+}
+
+func TestInventoryUsecaseRepository_DropOrphanedPayments(t *testing.T) {
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	lego := models.Item{Name: "Lego", Price: decimal.New(10, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(10, 0))
+	placed, err := repo.PurchaseOrder(models.DefaultLocationId, &[]models.OrderLineItem{{Item: &lego, Quantity: 1}},
+		uuid.NewV4(), 0)
+	if err != nil {
+		t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+	}
+	// what a crash between saving the payment and appending the order leaves behind
+	never := models.Order{Id: uuid.NewV4(), UserId: uuid.NewV4(), TotalAmount: decimal.New(10, 0),
+		Created: clock.System.Now()}
+	if err := repo.Payments.SavePayment(newPayment(&never)); err != nil {
+		t.Fatalf("MemoryPaymentStore.SavePayment() error = %v", err)
+	}
+
+	if dropped, err := repo.DropOrphanedPayments(); err != nil || dropped != 1 {
+		t.Fatalf("InventoryUsecaseRepository.DropOrphanedPayments() = %d, %v, want 1", dropped, err)
+	}
+	if unpaid := repo.UnpaidOrders(); len(unpaid) != 1 || !uuid.Equal(unpaid[0].OrderId, placed.Id) {
+		t.Errorf("InventoryUsecaseRepository.UnpaidOrders() = %+v, want the placed order only", unpaid)
+	}
+	if dropped, err := repo.DropOrphanedPayments(); err != nil || dropped != 0 {
+		t.Errorf("InventoryUsecaseRepository.DropOrphanedPayments() again = %d, %v, want 0", dropped, err)
+	}
+}
@@ -0,0 +1,161 @@
+package usecases
+
+import (
+	"error"
+	"github.com/shopspring/decimal"
+	"models"
+)
+
+// DiscountSource says who offers a discount
+type DiscountSource string
+
+const (
+	ItemDiscount         DiscountSource = "item"
+	SKUDiscount          DiscountSource = "sku"
+	ProductGroupDiscount DiscountSource = "product group"
+	UserDiscount         DiscountSource = "user"
+)
+
+// DefaultDiscountPrecedence is the order discounts are offered in, most specific first
+var DefaultDiscountPrecedence = []DiscountSource{ItemDiscount, SKUDiscount, ProductGroupDiscount, UserDiscount}
+
+// Discount is a percentage off offered by one source
+type Discount struct {
+	Source     DiscountSource
+	Percentage int
+}
+
+// DiscountPolicy decides which of the discounts offered on an order line apply.
+// Offers come in DefaultDiscountPrecedence order and only sources offering something are included.
+// The discounts returned are applied one after the other, each on what is left after the previous one.
+type DiscountPolicy interface {
+	Resolve(offers []Discount) []Discount
+}
+
+// BestOfDiscountPolicy applies only the biggest discount offered
+type BestOfDiscountPolicy struct{}
+
+func (BestOfDiscountPolicy) Resolve(offers []Discount) []Discount {
+	var best []Discount
+	for _, offer := range offers {
+		if len(best) == 0 || offer.Percentage > best[0].Percentage {
+			best = []Discount{offer}
+		}
+	}
+	return best
+}
+
+// StackedDiscountPolicy applies every discount offered, compounding them
+type StackedDiscountPolicy struct{}
+
+func (StackedDiscountPolicy) Resolve(offers []Discount) []Discount {
+	return offers
+}
+
+// FirstMatchDiscountPolicy applies the discount of the first source in Precedence that offers one,
+// DefaultDiscountPrecedence if none is set
+type FirstMatchDiscountPolicy struct {
+	Precedence []DiscountSource
+}
+
+func (p FirstMatchDiscountPolicy) Resolve(offers []Discount) []Discount {
+	precedence := p.Precedence
+	if len(precedence) == 0 {
+		precedence = DefaultDiscountPrecedence
+	}
+	for _, source := range precedence {
+		for _, offer := range offers {
+			if offer.Source == source {
+				return []Discount{offer}
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultDiscountPolicy stacks product and user discounts, like the store always has
+var DefaultDiscountPolicy DiscountPolicy = StackedDiscountPolicy{}
+
+// NewDiscountPolicy picks a policy by name: best-of, stacked or first-match
+func NewDiscountPolicy(name string) (DiscountPolicy, error) {
+	switch name {
+	case "best-of":
+		return BestOfDiscountPolicy{}, nil
+	case "stacked":
+		return StackedDiscountPolicy{}, nil
+	case "first-match":
+		return FirstMatchDiscountPolicy{}, nil
+	}
+	return nil, errors.NewError(errors.InvalidInputError, "unknown discount policy "+name)
+}
+
+// discounts offered on a line, in DefaultDiscountPrecedence order
+func discountOffers(item *models.Item, userDiscount int) []Discount {
+	var offers []Discount
+	candidates := []Discount{
+		{Source: ItemDiscount, Percentage: item.DiscountPercentage},
+		{Source: SKUDiscount, Percentage: item.SKU.DiscountPercentage},
+		{Source: ProductGroupDiscount, Percentage: item.ProductGroup.DiscountPercentage},
+		{Source: UserDiscount, Percentage: userDiscount},
+	}
+	for _, candidate := range candidates {
+		if candidate.Percentage > 0 {
+			offers = append(offers, candidate)
+		}
+	}
+	return offers
+}
+
+// Cents line amounts are rounded to
+const centPlaces = 2
+
+// Price every line with a positive quantity. Discounts are worked out exactly, each on what is
+// left after the previous one, and only the discounted line amount is rounded to cents.
+func priceLines(policy DiscountPolicy, lineItems *[]models.OrderLineItem, userDiscount int) []models.PriceBreakdown {
+	breakdowns := make([]models.PriceBreakdown, 0, len(*lineItems))
+	for _, line := range *lineItems {
+		if line.Quantity <= 0 {
+			// skip negative/zero item qty
+			continue
+		}
+
+		breakdown := models.PriceBreakdown{
+			ItemId:    line.Item.Id,
+			UnitPrice: line.Item.Price,
+			Quantity:  line.Quantity,
+			Gross:     line.Item.Price.Mul(decimal.New(line.Quantity, 0)),
+			TaxClass:  itemTaxClass(line.Item),
+		}
+		remaining := breakdown.Gross
+		for _, discount := range policy.Resolve(discountOffers(line.Item, userDiscount)) {
+			amount := remaining.Mul(decimal.New(int64(discount.Percentage), -2))
+			remaining = remaining.Sub(amount)
+			breakdown.Discounts = append(breakdown.Discounts, models.AppliedDiscount{
+				Source:     string(discount.Source),
+				Percentage: discount.Percentage,
+				Amount:     amount,
+			})
+		}
+		breakdown.Net = remaining.Round(centPlaces)
+		breakdown.Rounding = breakdown.Net.Sub(remaining)
+		breakdowns = append(breakdowns, breakdown)
+	}
+	return breakdowns
+}
+
+// Calculate net and gross amount for line items with the given discount policy
+func calcOrderAmounts(policy DiscountPolicy, lineItems *[]models.OrderLineItem, userDiscount int) (decimal.Decimal,
+	decimal.Decimal) {
+	return sumBreakdowns(priceLines(policy, lineItems, userDiscount))
+}
+
+// Net and gross amount of priced lines
+func sumBreakdowns(breakdowns []models.PriceBreakdown) (decimal.Decimal, decimal.Decimal) {
+	netAmount := decimal.Zero
+	grossAmount := decimal.Zero
+	for _, breakdown := range breakdowns {
+		netAmount = netAmount.Add(breakdown.Net)
+		grossAmount = grossAmount.Add(breakdown.Gross)
+	}
+	return netAmount, grossAmount
+}
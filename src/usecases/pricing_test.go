@@ -0,0 +1,159 @@
+package usecases
+
+import (
+	"models"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestDiscountPolicies(t *testing.T) {
+	// 2 units at 50 so every discount has to apply to the whole line, not one unit
+	discountedItem := func(item, sku, group int) *models.Item {
+		return &models.Item{
+			Price:              decimal.New(50, 0),
+			DiscountPercentage: item,
+			SKU:                models.SKU{DiscountPercentage: sku},
+			ProductGroup:       models.ProductGroup{DiscountPercentage: group},
+		}
+	}
+
+	tests := []struct {
+		name                        string
+		item, sku, group, user      int
+		bestOf, stacked, firstMatch string
+	}{
+		{name: "none", bestOf: "100", stacked: "100", firstMatch: "100"},
+		{name: "item", item: 10, bestOf: "90", stacked: "90", firstMatch: "90"},
+		{name: "sku", sku: 20, bestOf: "80", stacked: "80", firstMatch: "80"},
+		{name: "item+sku", item: 10, sku: 20, bestOf: "80", stacked: "72", firstMatch: "90"},
+		{name: "group", group: 5, bestOf: "95", stacked: "95", firstMatch: "95"},
+		{name: "item+group", item: 10, group: 5, bestOf: "90", stacked: "85.5", firstMatch: "90"},
+		{name: "sku+group", sku: 20, group: 5, bestOf: "80", stacked: "76", firstMatch: "80"},
+		{name: "item+sku+group", item: 10, sku: 20, group: 5, bestOf: "80", stacked: "68.4", firstMatch: "90"},
+		{name: "user", user: 15, bestOf: "85", stacked: "85", firstMatch: "85"},
+		{name: "item+user", item: 10, user: 15, bestOf: "85", stacked: "76.5", firstMatch: "90"},
+		{name: "sku+user", sku: 20, user: 15, bestOf: "80", stacked: "68", firstMatch: "80"},
+		{name: "item+sku+user", item: 10, sku: 20, user: 15, bestOf: "80", stacked: "61.2", firstMatch: "90"},
+		{name: "group+user", group: 5, user: 15, bestOf: "85", stacked: "80.75", firstMatch: "95"},
+		{name: "item+group+user", item: 10, group: 5, user: 15, bestOf: "85", stacked: "72.68", firstMatch: "90"},
+		{name: "sku+group+user", sku: 20, group: 5, user: 15, bestOf: "80", stacked: "64.6", firstMatch: "80"},
+		{name: "all", item: 10, sku: 20, group: 5, user: 15, bestOf: "80", stacked: "58.14", firstMatch: "90"},
+	}
+	policies := []struct {
+		name   string
+		policy DiscountPolicy
+		want   func(n int) string
+	}{
+		{name: "best-of", policy: BestOfDiscountPolicy{}, want: func(n int) string { return tests[n].bestOf }},
+		{name: "stacked", policy: StackedDiscountPolicy{}, want: func(n int) string { return tests[n].stacked }},
+		{name: "first-match", policy: FirstMatchDiscountPolicy{}, want: func(n int) string { return tests[n].firstMatch }},
+	}
+	for _, p := range policies {
+		for n, tt := range tests {
+			t.Run(p.name+"/"+tt.name, func(t *testing.T) {
+				lineItems := &[]models.OrderLineItem{{Item: discountedItem(tt.item, tt.sku, tt.group), Quantity: 2}}
+				net, gross := calcOrderAmounts(p.policy, lineItems, tt.user)
+				want, _ := decimal.NewFromString(p.want(n))
+				if !net.Equal(want) {
+					t.Errorf("calcOrderAmounts() net = %s, want %s", net, want)
+				}
+				if !gross.Equal(decimal.New(100, 0)) {
+					t.Errorf("calcOrderAmounts() gross = %s, want 100", gross)
+				}
+			})
+		}
+	}
+}
+
+func TestPriceLines_Breakdown(t *testing.T) {
+	// 10 Batmen, every discount has to come off all ten, not just one
+	batman := &models.Item{
+		Name:               "Batman",
+		Price:              mustDecimal("12.99"),
+		DiscountPercentage: 10,
+		SKU:                models.SKU{Name: "SuperHeroToy", DiscountPercentage: 5},
+	}
+	lineItems := &[]models.OrderLineItem{{Item: batman, Quantity: 10}, {Item: batman, Quantity: 0}}
+
+	got := priceLines(StackedDiscountPolicy{}, lineItems, 15)
+	if len(got) != 1 {
+		t.Fatalf("priceLines() = %d lines, want 1, zero quantities are skipped", len(got))
+	}
+	line := got[0]
+	if line.Quantity != 10 || !line.UnitPrice.Equal(batman.Price) || !line.Gross.Equal(mustDecimal("129.9")) {
+		t.Errorf("priceLines() = %s x %d = %s, want 12.99 x 10 = 129.9", line.UnitPrice, line.Quantity, line.Gross)
+	}
+
+	wantDiscounts := []models.AppliedDiscount{
+		{Source: "item", Percentage: 10, Amount: mustDecimal("12.99")},
+		{Source: "sku", Percentage: 5, Amount: mustDecimal("5.8455")},
+		{Source: "user", Percentage: 15, Amount: mustDecimal("16.659675")},
+	}
+	if len(line.Discounts) != len(wantDiscounts) {
+		t.Fatalf("priceLines() discounts = %v, want %v", line.Discounts, wantDiscounts)
+	}
+	for n, want := range wantDiscounts {
+		d := line.Discounts[n]
+		if d.Source != want.Source || d.Percentage != want.Percentage || !d.Amount.Equal(want.Amount) {
+			t.Errorf("priceLines() discount %d = %s %d%% %s, want %s %d%% %s", n, d.Source, d.Percentage, d.Amount,
+				want.Source, want.Percentage, want.Amount)
+		}
+	}
+
+	// 129.9 - 12.99 - 5.8455 - 16.659675 = 94.404825, rounded to cents
+	if !line.Net.Equal(mustDecimal("94.4")) || !line.Rounding.Equal(mustDecimal("-0.004825")) {
+		t.Errorf("priceLines() net = %s rounding %s, want 94.40 rounding -0.004825", line.Net, line.Rounding)
+	}
+
+	net, gross := calcOrderAmounts(StackedDiscountPolicy{}, lineItems, 15)
+	if !net.Equal(line.Net) || !gross.Equal(line.Gross) {
+		t.Errorf("calcOrderAmounts() = %s, %s, want the breakdown totals %s, %s", net, gross, line.Net, line.Gross)
+	}
+}
+
+func TestFirstMatchDiscountPolicy_Precedence(t *testing.T) {
+	offers := []Discount{{Source: ItemDiscount, Percentage: 10}, {Source: ProductGroupDiscount, Percentage: 5},
+		{Source: UserDiscount, Percentage: 15}}
+
+	tests := []struct {
+		name       string
+		precedence []DiscountSource
+		want       DiscountSource
+	}{
+		{name: "default", want: ItemDiscount},
+		{name: "user first", precedence: []DiscountSource{UserDiscount, ItemDiscount}, want: UserDiscount},
+		{name: "first listed source not offered", precedence: []DiscountSource{SKUDiscount, ProductGroupDiscount}, want: ProductGroupDiscount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FirstMatchDiscountPolicy{Precedence: tt.precedence}.Resolve(offers)
+			if len(got) != 1 || got[0].Source != tt.want {
+				t.Errorf("FirstMatchDiscountPolicy.Resolve() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if got := (FirstMatchDiscountPolicy{Precedence: []DiscountSource{SKUDiscount}}).Resolve(offers); len(got) != 0 {
+		t.Errorf("FirstMatchDiscountPolicy.Resolve() = %v, want nothing when no listed source offers", got)
+	}
+}
+
+func TestNewDiscountPolicy(t *testing.T) {
+	for _, name := range []string{"best-of", "stacked", "first-match"} {
+		if _, err := NewDiscountPolicy(name); err != nil {
+			t.Errorf("NewDiscountPolicy(%q) error = %v", name, err)
+		}
+	}
+	if _, err := NewDiscountPolicy("cheapest"); err == nil {
+		t.Errorf("NewDiscountPolicy(%q) succeeded", "cheapest")
+	}
+}
+
+func mustDecimal(value string) decimal.Decimal {
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
@@ -0,0 +1,124 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"sort"
+	"time"
+)
+
+// Sales window and cover suggested purchase orders use unless told otherwise
+const (
+	DefaultReorderWindow = 14 * 24 * time.Hour
+	DefaultReorderCover  = 7 * 24 * time.Hour
+)
+
+// LowStockAlert tells that a purchase took an item below its reorder point at a location
+type LowStockAlert struct {
+	Item       models.Item
+	LocationId uuid.UUID
+	// Balance at the location after the purchase
+	Balance decimal.Decimal
+	OrderId uuid.UUID
+}
+
+// ReorderSuggestion is one line of a suggested purchase order to the suppliers
+type ReorderSuggestion struct {
+	Item    models.Item
+	Balance decimal.Decimal
+	// Units sold per day over the sales window, net of returns
+	Velocity decimal.Decimal
+	Quantity decimal.Decimal
+}
+
+// SuggestReorders works out what to buy in for items with a reorder point. Sales over the last window tell how
+// fast an item sells, an item is suggested when at that pace its stock would drop below the reorder point within
+// cover. It is then bought up to the reorder point plus what sells during cover, and at least its reorder
+// quantity. Stock and sales at every location count. Suggestions are sorted by item name.
+func (i *InventoryUsecaseRepository) SuggestReorders(items []models.Item, window time.Duration,
+	cover time.Duration) ([]ReorderSuggestion, error) {
+	if window <= 0 || cover < 0 {
+		return nil, errors.NewError(errors.InvalidInputError, "Sales window must be positive and cover not negative")
+	}
+
+	// balances and sales from the same snapshot of the ledger
+	through := i.ledger.Sequence()
+	balances := i.ledger.BalancesAsOf(through)
+	sold := make(map[uuid.UUID]decimal.Decimal)
+	from := i.clock.Now().Add(-window)
+	for _, entry := range i.ledger.EntriesBetween(i.ledger.SequenceAt(from.Add(-time.Nanosecond)), through) {
+		if entry.Status == models.AbortedLedgerEnryStatus {
+			continue
+		}
+		switch entry.Order.Type {
+		case models.PurchaseOrderType:
+			sold[entry.Item.Id] = sold[entry.Item.Id].Add(entry.Debit)
+		case models.ReturnOrderType:
+			sold[entry.Item.Id] = sold[entry.Item.Id].Sub(entry.Credit)
+		}
+	}
+
+	day := decimal.New(int64(24*time.Hour), 0)
+	var suggestions []ReorderSuggestion
+	for _, item := range items {
+		if item.ReorderPoint.Sign() <= 0 || item.Status == models.RetiredItemStatus {
+			continue
+		}
+		balance := balances[item.Id]
+		// what sells during cover at the pace of the window, returns can make it negative
+		demand := sold[item.Id].Mul(decimal.New(int64(cover), 0)).Div(decimal.New(int64(window), 0))
+		if demand.Sign() < 0 {
+			demand = decimal.Zero
+		}
+		if balance.Sub(demand).Cmp(item.ReorderPoint) >= 0 {
+			continue
+		}
+
+		quantity := item.ReorderPoint.Add(demand).Sub(balance).Ceil()
+		if quantity.Cmp(item.ReorderQuantity) < 0 {
+			quantity = item.ReorderQuantity
+		}
+		suggestions = append(suggestions, ReorderSuggestion{
+			Item:     item,
+			Balance:  balance,
+			Velocity: sold[item.Id].Mul(day).Div(decimal.New(int64(window), 0)).Round(2),
+			Quantity: quantity,
+		})
+	}
+	sort.Slice(suggestions, func(a, b int) bool {
+		return suggestions[a].Item.Name < suggestions[b].Item.Name
+	})
+	return suggestions, nil
+}
+
+// an alert for every item of a purchase whose balance crossed its reorder point
+func lowStockAlerts(order *models.Order, lineItems []models.OrderLineItem, startBalances map[uuid.UUID]decimal.Decimal,
+	balances map[uuid.UUID]decimal.Decimal) []LowStockAlert {
+	var alerts []LowStockAlert
+	alerted := make(map[uuid.UUID]bool)
+	for _, line := range lineItems {
+		item := line.Item
+		if alerted[item.Id] || item.ReorderPoint.Sign() <= 0 {
+			continue
+		}
+		// items already below it alerted with the purchase that took them there
+		if startBalances[item.Id].Cmp(item.ReorderPoint) < 0 || balances[item.Id].Cmp(item.ReorderPoint) >= 0 {
+			continue
+		}
+		alerted[item.Id] = true
+		alerts = append(alerts, LowStockAlert{Item: *item, LocationId: order.LocationId, Balance: balances[item.Id],
+			OrderId: order.Id})
+	}
+	return alerts
+}
+
+func (i *InventoryUsecaseRepository) alertLowStock(alerts []LowStockAlert) {
+	if i.LowStock == nil {
+		return
+	}
+	for _, alert := range alerts {
+		i.LowStock(alert)
+	}
+}
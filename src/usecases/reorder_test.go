@@ -0,0 +1,89 @@
+package usecases
+
+import (
+	"clock"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestInventoryUsecaseRepository_Reorder(t *testing.T) {
+	start := time.Date(2017, 6, 6, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), fake)
+	var alerts []LowStockAlert
+	repo.LowStock = func(alert LowStockAlert) {
+		// the purchase has released its locks
+		unlock := repo.locks.lock(alert.Item.Id)
+		unlock()
+		alerts = append(alerts, alert)
+	}
+
+	newItem := func(name string, point, quantity int64) models.Item {
+		return models.Item{Name: name, Price: decimal.New(5, 0), ReorderPoint: decimal.New(point, 0),
+			ReorderQuantity: decimal.New(quantity, 0),
+			BaseFields:      models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	}
+	lego := newItem("Lego", 5, 10)
+	yoyo := newItem("Yoyo", 2, 4)
+	kite := newItem("Kite", 0, 0)
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(20, 0))
+	repo.Replenish(models.DefaultLocationId, yoyo, decimal.New(10, 0))
+	repo.Replenish(models.DefaultLocationId, kite, decimal.New(1, 0))
+
+	user := uuid.NewV4()
+	buy := func(item models.Item, quantity int64) {
+		repo.PurchaseOrder(models.DefaultLocationId, &[]models.OrderLineItem{{Item: &item, Quantity: quantity}}, user, 0)
+	}
+	fake.Advance(24 * time.Hour)
+	buy(lego, 7)
+	fake.Advance(24 * time.Hour)
+	buy(lego, 7)
+	buy(lego, 2)
+	buy(lego, 1)
+	buy(lego, 10)
+	buy(yoyo, 8)
+	buy(kite, 1)
+
+	if len(alerts) != 1 || !uuid.Equal(alerts[0].Item.Id, lego.Id) || !alerts[0].Balance.Equal(decimal.New(4, 0)) {
+		t.Fatalf("InventoryUsecaseRepository.LowStock got %v, want one alert for Lego down to 4", alerts)
+	}
+
+	fake.Set(start.Add(14 * 24 * time.Hour))
+	tests := []struct {
+		name   string
+		window time.Duration
+		want   map[string]string
+	}{
+		{name: "at the pace of two weeks", window: 14 * 24 * time.Hour, want: map[string]string{"Lego": "11", "Yoyo": "4"}},
+		{name: "no sales in the window", window: time.Hour, want: map[string]string{"Lego": "10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions, err := repo.SuggestReorders([]models.Item{yoyo, kite, lego}, tt.window, 7*24*time.Hour)
+			if err != nil {
+				t.Fatalf("InventoryUsecaseRepository.SuggestReorders() error = %v", err)
+			}
+			if len(suggestions) != len(tt.want) {
+				t.Fatalf("InventoryUsecaseRepository.SuggestReorders() = %v, want %v", suggestions, tt.want)
+			}
+			for n, suggestion := range suggestions {
+				if n > 0 && suggestions[n-1].Item.Name > suggestion.Item.Name {
+					t.Errorf("InventoryUsecaseRepository.SuggestReorders() isn't sorted by name")
+				}
+				if want := tt.want[suggestion.Item.Name]; !suggestion.Quantity.Equal(mustDecimal(want)) {
+					t.Errorf("InventoryUsecaseRepository.SuggestReorders() %s = %s, want %s", suggestion.Item.Name,
+						suggestion.Quantity, want)
+				}
+			}
+		})
+	}
+
+	if _, err := repo.SuggestReorders([]models.Item{lego}, 0, time.Hour); err == nil {
+		t.Errorf("InventoryUsecaseRepository.SuggestReorders() without a window should fail")
+	}
+}
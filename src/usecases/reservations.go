@@ -0,0 +1,258 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"time"
+)
+
+// DefaultReservationTTL is how long stock stays held for a cart nobody touches
+const DefaultReservationTTL = 15 * time.Minute
+
+// StockLevel is the stock of an item and how much of it carts hold
+type StockLevel struct {
+	OnHand   decimal.Decimal
+	Reserved decimal.Decimal
+	// Available to sell, on hand less reserved
+	Available decimal.Decimal
+}
+
+// Reserve holds quantity more of an item at a location for holder, eg. a cart, against the stock available to
+// sell: what is on hand less what live reservations of other holders have. The holder's reservation of the item,
+// expired or not, is renewed and expires ReservationTTL from now.
+func (i *InventoryUsecaseRepository) Reserve(holderId uuid.UUID, locationId uuid.UUID, item models.Item,
+	quantity int64) (models.Reservation, error) {
+	// check input
+	if uuid.Equal(holderId, uuid.Nil) || uuid.Equal(item.Id, uuid.Nil) {
+		return models.Reservation{}, errors.NewError(errors.ReservationError, "Empty holder/item given")
+	}
+	if quantity <= 0 {
+		return models.Reservation{}, errors.NewError(errors.ReservationError, "Quantity of "+item.Name+
+			" must be positive")
+	}
+	if !i.forSale(item) {
+		return models.Reservation{}, errors.NewError(errors.ReservationError, item.Name+" is not available for sale")
+	}
+	if err := i.checkLocation(locationId); err != nil {
+		return models.Reservation{}, err
+	}
+
+	unlock := i.locks.lock(item.Id)
+	defer unlock()
+	i.reservationsMu.Lock()
+	defer i.reservationsMu.Unlock()
+	i.purgeReservations(holderId)
+
+	reservation := models.Reservation{
+		HolderId:   holderId,
+		LocationId: locationId,
+		Item:       item,
+		Quantity:   decimal.Zero,
+		BaseFields: models.BaseFields{
+			Id:      uuid.NewV4(),
+			Created: i.clock.Now(),
+		},
+	}
+	for _, r := range i.Reservations.Reservations() {
+		if uuid.Equal(r.HolderId, holderId) && uuid.Equal(r.LocationId, locationId) && uuid.Equal(r.Item.Id, item.Id) {
+			reservation = r
+		}
+	}
+	reservation.Quantity = reservation.Quantity.Add(decimal.New(quantity, 0))
+	available := i.findItemBalanceInLedger(locationId, item).Sub(i.reserved(locationId, holderId)[item.Id])
+	if reservation.Quantity.Cmp(available) > 0 {
+		return models.Reservation{}, errors.NewError(errors.ReservationError, "only "+available.String()+" of "+
+			item.Name+" available")
+	}
+	reservation.Expires = i.clock.Now().Add(i.ReservationTTL)
+	reservation.Modified = i.clock.Now()
+	if err := i.Reservations.SaveReservation(reservation); err != nil {
+		return models.Reservation{}, errors.NewError(errors.ReservationError, err.Error())
+	}
+	return reservation, nil
+}
+
+// Release frees everything held for holder, eg. when a cart is abandoned
+func (i *InventoryUsecaseRepository) Release(holderId uuid.UUID) error {
+	i.reservationsMu.Lock()
+	defer i.reservationsMu.Unlock()
+	return i.release(holderId)
+}
+
+// Renew holds the quantities of lineItems at a location for holder again, eg. for a cart that was parked: each
+// of its reservations, expired or not, is topped up to the quantity of its line and expires ReservationTTL from
+// now. The quantities are checked against the stock available to sell before any reservation changes, so if
+// some of it was sold meanwhile the holder keeps what it had.
+func (i *InventoryUsecaseRepository) Renew(holderId uuid.UUID, locationId uuid.UUID,
+	lineItems []models.OrderLineItem) ([]models.Reservation, error) {
+	// check input
+	if uuid.Equal(holderId, uuid.Nil) {
+		return nil, errors.NewError(errors.ReservationError, "Empty holder given")
+	}
+	wanted := make(map[uuid.UUID]decimal.Decimal)
+	items := make(map[uuid.UUID]models.Item)
+	for _, line := range lineItems {
+		if line.Quantity <= 0 {
+			return nil, errors.NewError(errors.ReservationError, "Quantity of "+line.Item.Name+" must be positive")
+		}
+		if !i.forSale(*line.Item) {
+			return nil, errors.NewError(errors.ReservationError, line.Item.Name+" is not available for sale")
+		}
+		wanted[line.Item.Id] = wanted[line.Item.Id].Add(decimal.New(line.Quantity, 0))
+		items[line.Item.Id] = *line.Item
+	}
+	if err := i.checkLocation(locationId); err != nil {
+		return nil, err
+	}
+
+	itemIds := make([]uuid.UUID, 0, len(items))
+	for id := range items {
+		itemIds = append(itemIds, id)
+	}
+	unlock := i.locks.lock(itemIds...)
+	defer unlock()
+	i.reservationsMu.Lock()
+	defer i.reservationsMu.Unlock()
+	i.purgeReservations(holderId)
+
+	// the holder's own reservations, to be renewed in place
+	held := make(map[uuid.UUID]models.Reservation)
+	for _, r := range i.Reservations.Reservations() {
+		if uuid.Equal(r.HolderId, holderId) && uuid.Equal(r.LocationId, locationId) {
+			held[r.Item.Id] = r
+		}
+	}
+	reserved := i.reserved(locationId, holderId)
+	renewed := make([]models.Reservation, 0, len(itemIds))
+	for _, id := range uniqueSortedIds(itemIds) {
+		available := i.findItemBalanceInLedger(locationId, items[id]).Sub(reserved[id])
+		if wanted[id].Cmp(available) > 0 {
+			return nil, errors.NewError(errors.ReservationError, "only "+available.String()+" of "+
+				items[id].Name+" available")
+		}
+		reservation, ok := held[id]
+		if !ok {
+			reservation = models.Reservation{
+				HolderId:   holderId,
+				LocationId: locationId,
+				Item:       items[id],
+				BaseFields: models.BaseFields{
+					Id:      uuid.NewV4(),
+					Created: i.clock.Now(),
+				},
+			}
+		}
+		reservation.Quantity = wanted[id]
+		reservation.Expires = i.clock.Now().Add(i.ReservationTTL)
+		reservation.Modified = i.clock.Now()
+		renewed = append(renewed, reservation)
+	}
+
+	for n, reservation := range renewed {
+		if err := i.Reservations.SaveReservation(reservation); err != nil {
+			// put back what was saved, a reservation that can't be put back lapses
+			for _, saved := range renewed[:n] {
+				if before, ok := held[saved.Item.Id]; ok {
+					i.Reservations.SaveReservation(before)
+				} else {
+					i.Reservations.DeleteReservation(saved.Id)
+				}
+			}
+			return nil, errors.NewError(errors.ReservationError, err.Error())
+		}
+	}
+	return renewed, nil
+}
+
+// Held returns the live reservations of holder, oldest first
+func (i *InventoryUsecaseRepository) Held(holderId uuid.UUID) []models.Reservation {
+	var held []models.Reservation
+	for _, reservation := range i.Reservations.Reservations() {
+		if uuid.Equal(reservation.HolderId, holderId) && i.live(reservation) {
+			held = append(held, reservation)
+		}
+	}
+	return held
+}
+
+// Checkout places the order for lines holder reserved: the stock it holds counts as available to the order, and
+// once the order is debited its reservations are released. Stock whose reservation expired can still be bought if
+// nobody else holds it. An order placed whose reservations couldn't be released is returned with the error.
+func (i *InventoryUsecaseRepository) Checkout(holderId uuid.UUID, locationId uuid.UUID,
+	lineItems *[]models.OrderLineItem, userId uuid.UUID, userDiscount int) (models.Order, error) {
+	if uuid.Equal(holderId, uuid.Nil) {
+		return models.Order{}, errors.NewError(errors.OrderError, "Empty holder given")
+	}
+	return i.purchaseOrder(holderId, locationId, lineItems, userId, userDiscount)
+}
+
+// StockLevels reports the stock at the given locations now, summed across every location when none are given,
+// with what live reservations hold of it
+func (i *InventoryUsecaseRepository) StockLevels(locationIds ...uuid.UUID) map[uuid.UUID]StockLevel {
+	onHand := make(map[uuid.UUID]decimal.Decimal)
+	reserved := make(map[uuid.UUID]decimal.Decimal)
+	if len(locationIds) == 0 {
+		onHand = i.ledger.BalancesAsOf(i.ledger.Sequence())
+		reserved = i.reserved(uuid.Nil, uuid.Nil)
+	}
+	for _, locationId := range locationIds {
+		for id, balance := range i.ledger.LocationBalancesAsOf(locationId, i.ledger.Sequence()) {
+			onHand[id] = onHand[id].Add(balance)
+		}
+		for id, quantity := range i.reserved(locationId, uuid.Nil) {
+			reserved[id] = reserved[id].Add(quantity)
+		}
+	}
+
+	levels := make(map[uuid.UUID]StockLevel, len(onHand))
+	for id, balance := range onHand {
+		levels[id] = StockLevel{OnHand: balance, Reserved: reserved[id], Available: balance.Sub(reserved[id])}
+	}
+	return levels
+}
+
+// what live reservations of holders other than holderId hold at a location by item, at every location when
+// locationId is Nil
+func (i *InventoryUsecaseRepository) reserved(locationId uuid.UUID, holderId uuid.UUID) map[uuid.UUID]decimal.
+	Decimal {
+	reserved := make(map[uuid.UUID]decimal.Decimal)
+	for _, reservation := range i.Reservations.Reservations() {
+		if !i.live(reservation) || uuid.Equal(reservation.HolderId, holderId) {
+			continue
+		}
+		if !uuid.Equal(locationId, uuid.Nil) && !uuid.Equal(reservation.LocationId, locationId) {
+			continue
+		}
+		reserved[reservation.Item.Id] = reserved[reservation.Item.Id].Add(reservation.Quantity)
+	}
+	return reserved
+}
+
+func (i *InventoryUsecaseRepository) live(reservation models.Reservation) bool {
+	return reservation.Expires.After(i.clock.Now())
+}
+
+// drop expired reservations, except holderId's which are about to be renewed. Callers hold reservationsMu.
+func (i *InventoryUsecaseRepository) purgeReservations(holderId uuid.UUID) {
+	for _, reservation := range i.Reservations.Reservations() {
+		if !i.live(reservation) && !uuid.Equal(reservation.HolderId, holderId) {
+			// a reservation that can't be dropped now is dropped next time
+			i.Reservations.DeleteReservation(reservation.Id)
+		}
+	}
+}
+
+// Callers hold reservationsMu.
+func (i *InventoryUsecaseRepository) release(holderId uuid.UUID) error {
+	for _, reservation := range i.Reservations.Reservations() {
+		if !uuid.Equal(reservation.HolderId, holderId) {
+			continue
+		}
+		if err := i.Reservations.DeleteReservation(reservation.Id); err != nil {
+			return errors.NewError(errors.ReservationError, err.Error())
+		}
+	}
+	return nil
+}
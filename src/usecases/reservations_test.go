@@ -0,0 +1,125 @@
+package usecases
+
+import (
+	"clock"
+	"error"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestInventoryUsecaseRepository_Reservations(t *testing.T) {
+	fake := clock.NewFake(time.Date(2017, 6, 6, 9, 0, 0, 0, time.UTC))
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), fake)
+	repo.ReservationTTL = 10 * time.Minute
+	lego := models.Item{Name: "Lego", Price: decimal.New(5, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(5, 0))
+	annasCart, borisCart := uuid.NewV4(), uuid.NewV4()
+	user := uuid.NewV4()
+
+	wantError := func(what string, err error, errorType int) {
+		e, ok := err.(errors.ApplicationError)
+		if !ok || e.ErrorType != errors.ErrorMap[errorType].ErrorType {
+			t.Errorf("InventoryUsecaseRepository %s error = %v, want %s", what, err, errors.ErrorMap[errorType].Message)
+		}
+	}
+	level := func() StockLevel {
+		return repo.StockLevels(models.DefaultLocationId)[lego.Id]
+	}
+	buy := func(quantity int64) *[]models.OrderLineItem {
+		return &[]models.OrderLineItem{{Item: &lego, Quantity: quantity}}
+	}
+
+	if _, err := repo.Reserve(annasCart, models.DefaultLocationId, lego, 2); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Reserve() error = %v", err)
+	}
+	if reservation, err := repo.Reserve(annasCart, models.DefaultLocationId, lego, 1); err != nil ||
+		!reservation.Quantity.Equal(decimal.New(3, 0)) {
+		t.Fatalf("InventoryUsecaseRepository.Reserve() again = %v, %v, want 3 held", reservation, err)
+	}
+	_, err := repo.Reserve(borisCart, models.DefaultLocationId, lego, 3)
+	wantError("Reserve() of stock held for another cart", err, errors.ReservationError)
+	if got := level(); !got.OnHand.Equal(decimal.New(5, 0)) || !got.Reserved.Equal(decimal.New(3, 0)) ||
+		!got.Available.Equal(decimal.New(2, 0)) {
+		t.Errorf("InventoryUsecaseRepository.StockLevels() = %+v, want 5 on hand, 3 reserved and 2 available", got)
+	}
+
+	// a walk-in purchase can only have what isn't held
+	_, err = repo.PurchaseOrder(models.DefaultLocationId, buy(3), user, 0)
+	wantError("PurchaseOrder() of reserved stock", err, errors.OrderError)
+	if _, err := repo.PurchaseOrder(models.DefaultLocationId, buy(1), user, 0); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+	}
+
+	// the cart's own reservation counts towards its checkout, and is gone after it
+	if _, err := repo.Checkout(annasCart, models.DefaultLocationId, buy(3), user, 0); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Checkout() error = %v", err)
+	}
+	if held := repo.Held(annasCart); len(held) != 0 {
+		t.Errorf("InventoryUsecaseRepository.Held() after checkout = %v, want nothing", held)
+	}
+	if got := level(); !got.OnHand.Equal(decimal.New(1, 0)) || !got.Reserved.Equal(decimal.Zero) {
+		t.Errorf("InventoryUsecaseRepository.StockLevels() after checkout = %+v, want 1 on hand, none reserved", got)
+	}
+
+	// reservations lapse after the TTL
+	if _, err := repo.Reserve(borisCart, models.DefaultLocationId, lego, 1); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Reserve() error = %v", err)
+	}
+	_, err = repo.PurchaseOrder(models.DefaultLocationId, buy(1), user, 0)
+	wantError("PurchaseOrder() of stock reserved a moment ago", err, errors.OrderError)
+	fake.Advance(10 * time.Minute)
+	if held := repo.Held(borisCart); len(held) != 0 {
+		t.Errorf("InventoryUsecaseRepository.Held() after the TTL = %v, want nothing", held)
+	}
+	if _, err := repo.PurchaseOrder(models.DefaultLocationId, buy(1), user, 0); err != nil {
+		t.Errorf("InventoryUsecaseRepository.PurchaseOrder() after the reservation expired error = %v", err)
+	}
+	if err := repo.Release(borisCart); err != nil || len(repo.Reservations.Reservations()) != 0 {
+		t.Errorf("InventoryUsecaseRepository.Release() = %v, left %v", err, repo.Reservations.Reservations())
+	}
+}
+
+func TestInventoryUsecaseRepository_ReservedStockStays(t *testing.T) {
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	transfers := NewTransferUsecaseRepository(repo, stores.NewMemoryTransferStore())
+	warehouse, _ := repo.AddLocation("Warehouse", models.WarehouseLocationKind)
+	lego := models.Item{Name: "Lego", Price: decimal.New(5, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(5, 0))
+	anna := uuid.NewV4()
+	if _, err := repo.Reserve(uuid.NewV4(), models.DefaultLocationId, lego, 3); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Reserve() error = %v", err)
+	}
+
+	wantError := func(what string, err error, errorType int) {
+		e, ok := err.(errors.ApplicationError)
+		if !ok || e.ErrorType != errors.ErrorMap[errorType].ErrorType {
+			t.Errorf("%s error = %v, want %s", what, err, errors.ErrorMap[errorType].Message)
+		}
+	}
+	_, err := repo.Adjust(models.DefaultLocationId, lego, decimal.New(-3, 0), models.DamageAdjustmentReason, anna)
+	wantError("Adjust() of reserved stock", err, errors.AdjustmentError)
+	_, err = transfers.Ship(models.DefaultLocationId, warehouse.Id, anna, []models.OrderLineItem{{Item: &lego,
+		Quantity: 3}})
+	wantError("Ship() of reserved stock", err, errors.TransferError)
+
+	// what isn't held can go
+	if _, err := repo.Adjust(models.DefaultLocationId, lego, decimal.New(-1, 0), models.DamageAdjustmentReason,
+		anna); err != nil {
+		t.Errorf("InventoryUsecaseRepository.Adjust() error = %v", err)
+	}
+	if _, err := transfers.Ship(models.DefaultLocationId, warehouse.Id, anna, []models.OrderLineItem{{Item: &lego,
+		Quantity: 1}}); err != nil {
+		t.Errorf("TransferUsecaseRepository.Ship() error = %v", err)
+	}
+	if got := repo.StockLevels(models.DefaultLocationId)[lego.Id]; !got.OnHand.Equal(decimal.New(3, 0)) ||
+		got.Available.Sign() != 0 {
+		t.Errorf("InventoryUsecaseRepository.StockLevels() = %+v, want the 3 reserved left", got)
+	}
+}
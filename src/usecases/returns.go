@@ -0,0 +1,235 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+)
+
+// Return gives items of a purchase order back to the stock of the location they were sold from. Quantities
+// are checked against what the order bought less what was returned from it before, and the refund uses the
+// prices, discounts and tax the items were sold with. The return order is linked to the purchase order and
+// its TotalAmount is the refund. An order still pending payment can't be returned, there is nothing to refund
+// yet.
+func (i *InventoryUsecaseRepository) Return(orderId uuid.UUID, lineItems *[]models.OrderLineItem) (models.Order,
+	error) {
+	// check input
+	if len(*lineItems) == 0 {
+		return models.Order{}, errors.NewError(errors.ReturnError, "Empty line items given")
+	}
+	for _, line := range *lineItems {
+		if line.Quantity <= 0 {
+			return models.Order{}, errors.NewError(errors.ReturnError, "Quantity of "+line.Item.Name+" must be positive")
+		}
+	}
+
+	original, _ := i.findReturnableOrder(orderId)
+	if original == nil {
+		return models.Order{}, errors.NewError(errors.NotFoundError, "purchase order "+orderId.String())
+	}
+	// orders with no payment were placed before payments were taken, and are paid
+	if payment, ok := i.Payments.Payment(orderId); ok && payment.Status == models.PendingOrderStatus {
+		return models.Order{}, errors.NewError(errors.ReturnError, "order "+orderId.String()+
+			" isn't paid yet, take its payment first")
+	}
+	sold := mergeBreakdowns(original.Breakdown)
+
+	// lock everything the order bought, so returns of other lines can't race the remaining quantities
+	itemIds := make([]uuid.UUID, 0, len(sold)+len(*lineItems))
+	for itemId := range sold {
+		itemIds = append(itemIds, itemId)
+	}
+	for _, line := range *lineItems {
+		itemIds = append(itemIds, line.Item.Id)
+	}
+	unlock := i.locks.lock(itemIds...)
+	defer unlock()
+
+	_, returns := i.findReturnableOrder(orderId)
+	refunded := make(map[uuid.UUID]models.PriceBreakdown)
+	refundedTax := decimal.Zero
+	for _, previous := range returns {
+		refundedTax = refundedTax.Add(previous.TaxAmount)
+		for itemId, line := range mergeBreakdowns(previous.Breakdown) {
+			refunded[itemId] = addBreakdown(refunded[itemId], line)
+		}
+	}
+
+	// quantities asked for per item, in the order items first appear
+	requested := make(map[uuid.UUID]int64)
+	var requestOrder []*models.Item
+	for _, line := range *lineItems {
+		if _, ok := requested[line.Item.Id]; !ok {
+			requestOrder = append(requestOrder, line.Item)
+		}
+		requested[line.Item.Id] += line.Quantity
+	}
+
+	breakdown := make([]models.PriceBreakdown, 0, len(requestOrder))
+	for _, item := range requestOrder {
+		line, ok := sold[item.Id]
+		if !ok {
+			return models.Order{}, errors.NewError(errors.ReturnError, item.Name+" was not bought on this order")
+		}
+		left := line.Quantity - refunded[item.Id].Quantity
+		if requested[item.Id] > left {
+			return models.Order{}, errors.NewError(errors.ReturnError, item.Name+" has only "+
+				decimal.New(left, 0).String()+" left to return")
+		}
+		breakdown = append(breakdown, refundLine(line, refunded[item.Id], requested[item.Id]))
+	}
+
+	// the last return of an order refunds whatever tax is left, so refunds add up to the order exactly
+	complete := true
+	for itemId, line := range sold {
+		if refunded[itemId].Quantity+requested[itemId] < line.Quantity {
+			complete = false
+		}
+	}
+	priced, gross := sumBreakdowns(breakdown)
+	tax := decimal.Zero
+	for _, line := range breakdown {
+		tax = tax.Add(line.Tax)
+	}
+	tax = tax.Round(centPlaces)
+	if complete {
+		tax = original.TaxAmount.Sub(refundedTax)
+	}
+	net := priced
+	if original.TaxInclusive {
+		net = priced.Sub(tax)
+	}
+
+	order := models.Order{
+		UserId:          original.UserId,
+		LineItems:       *lineItems,
+		NetAmount:       net,
+		GrossAmount:     gross,
+		TaxAmount:       tax,
+		TotalAmount:     net.Add(tax),
+		TaxInclusive:    original.TaxInclusive,
+		Breakdown:       breakdown,
+		OriginalOrderId: original.Id,
+		Type:            models.ReturnOrderType,
+		LocationId:      original.LocationId,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  i.clock.Now(),
+			Modified: i.clock.Now(),
+			Status:   models.CompletedOrderStatus,
+		},
+	}
+
+	// credit the returned items back
+	entries := make([]models.LedgerEntry, 0, len(requestOrder))
+	for _, item := range requestOrder {
+		itemQty := decimal.New(requested[item.Id], 0)
+		entries = append(entries, models.LedgerEntry{
+			Order:      &order,
+			Item:       item,
+			LocationId: order.LocationId,
+			Credit:     itemQty,
+			Debit:      decimal.Zero,
+			Balance:    i.findItemBalanceInLedger(order.LocationId, *item).Add(itemQty),
+			BaseFields: models.BaseFields{
+				Id:       uuid.UUID{},
+				Created:  i.clock.Now(),
+				Modified: i.clock.Now(),
+				Status:   models.CreatedLedgerEntryStatus,
+			},
+		})
+	}
+	if err := i.ledger.Append(entries...); err != nil {
+		return models.Order{}, errors.NewError(errors.ReturnError, err.Error())
+	}
+
+	// we are done
+	return order, nil
+}
+
+// A completed purchase order and the orders returning items from it so far
+func (i *InventoryUsecaseRepository) findReturnableOrder(orderId uuid.UUID) (*models.Order, []*models.Order) {
+	var original *models.Order
+	var returns []*models.Order
+	seen := make(map[uuid.UUID]bool)
+	for _, entry := range i.ledger.Entries() {
+		if entry.Status == models.AbortedLedgerEnryStatus || entry.Order == nil || seen[entry.Order.Id] {
+			continue
+		}
+		switch {
+		case uuid.Equal(entry.Order.Id, orderId) && entry.Order.Type == models.PurchaseOrderType:
+			original = entry.Order
+		case uuid.Equal(entry.Order.OriginalOrderId, orderId) && entry.Order.Type == models.ReturnOrderType:
+			returns = append(returns, entry.Order)
+		default:
+			continue
+		}
+		seen[entry.Order.Id] = true
+	}
+	return original, returns
+}
+
+// Breakdown lines of the same item added up, an order can list an item more than once
+func mergeBreakdowns(breakdowns []models.PriceBreakdown) map[uuid.UUID]models.PriceBreakdown {
+	merged := make(map[uuid.UUID]models.PriceBreakdown)
+	for _, line := range breakdowns {
+		merged[line.ItemId] = addBreakdown(merged[line.ItemId], line)
+	}
+	return merged
+}
+
+func addBreakdown(sum models.PriceBreakdown, line models.PriceBreakdown) models.PriceBreakdown {
+	if sum.Quantity == 0 {
+		sum = line
+		sum.Discounts = append([]models.AppliedDiscount(nil), line.Discounts...)
+		return sum
+	}
+	sum.Quantity += line.Quantity
+	sum.Gross = sum.Gross.Add(line.Gross)
+	sum.Rounding = sum.Rounding.Add(line.Rounding)
+	sum.Net = sum.Net.Add(line.Net)
+	sum.Tax = sum.Tax.Add(line.Tax)
+	for n := range sum.Discounts {
+		if n < len(line.Discounts) {
+			sum.Discounts[n].Amount = sum.Discounts[n].Amount.Add(line.Discounts[n].Amount)
+		}
+	}
+	return sum
+}
+
+// The share of a sold line refunded for qty units. Returning the last units refunds whatever is left
+// of the line, so rounding never refunds more or less than was paid.
+func refundLine(sold models.PriceBreakdown, refunded models.PriceBreakdown, qty int64) models.PriceBreakdown {
+	line := sold
+	line.Quantity = qty
+	line.Discounts = make([]models.AppliedDiscount, len(sold.Discounts))
+	copy(line.Discounts, sold.Discounts)
+
+	if qty == sold.Quantity-refunded.Quantity {
+		line.Gross = sold.Gross.Sub(refunded.Gross)
+		line.Net = sold.Net.Sub(refunded.Net)
+		line.Tax = sold.Tax.Sub(refunded.Tax)
+		for n := range line.Discounts {
+			if n < len(refunded.Discounts) {
+				line.Discounts[n].Amount = sold.Discounts[n].Amount.Sub(refunded.Discounts[n].Amount)
+			}
+		}
+	} else {
+		share := func(amount decimal.Decimal) decimal.Decimal {
+			return amount.Mul(decimal.New(qty, 0)).Div(decimal.New(sold.Quantity, 0))
+		}
+		line.Gross = sold.UnitPrice.Mul(decimal.New(qty, 0))
+		line.Net = share(sold.Net).Round(centPlaces)
+		line.Tax = share(sold.Tax).Round(centPlaces)
+		for n := range line.Discounts {
+			line.Discounts[n].Amount = share(sold.Discounts[n].Amount)
+		}
+	}
+
+	line.Rounding = line.Net.Sub(line.Gross)
+	for _, discount := range line.Discounts {
+		line.Rounding = line.Rounding.Add(discount.Amount)
+	}
+	return line
+}
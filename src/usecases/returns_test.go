@@ -0,0 +1,118 @@
+package usecases
+
+import (
+	"clock"
+	"error"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestInventoryUsecaseRepository_Return(t *testing.T) {
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	repo.TaxCalculator = TaxCalculator{Rates: map[string]decimal.Decimal{models.StandardTaxClass: decimal.New(20, 0)},
+		Rounding: PerLineTaxRounding}
+	fM := new(models.Mocks)
+	fM.InitUsers()
+	user := fM.GetMockedUser(1)
+
+	newItem := func(name, price string, discount int) models.Item {
+		return models.Item{Name: name, Price: mustDecimal(price), DiscountPercentage: discount,
+			BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	}
+	batman := newItem("Batman", "9.99", 10)
+	robin := newItem("Robin", "4.49", 0)
+	joker := newItem("Joker", "7", 0)
+	for _, item := range []models.Item{batman, robin, joker} {
+		repo.Replenish(models.DefaultLocationId, item, decimal.New(10, 0))
+	}
+
+	from := time.Now().UTC().Add(-time.Second)
+	bought := []models.OrderLineItem{{Item: &batman, Quantity: 3}, {Item: &robin, Quantity: 2}}
+	order, err := repo.PurchaseOrder(models.DefaultLocationId, &bought, user.Id, 15)
+	if err != nil {
+		t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+	}
+	// nothing is refunded before the order is paid
+	_, err = repo.Return(order.Id, &[]models.OrderLineItem{{Item: &batman, Quantity: 1}})
+	if e, ok := err.(errors.ApplicationError); !ok || e.ErrorType != errors.ErrorMap[errors.ReturnError].ErrorType {
+		t.Errorf("InventoryUsecaseRepository.Return() of an unpaid order error = %v, want a return error", err)
+	}
+	if _, err := repo.Pay(order.Id, models.Tender{Type: models.CashTenderType, Tendered: order.TotalAmount}); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Pay() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		orderId   uuid.UUID
+		lineItems []models.OrderLineItem
+		wantErr   int
+	}{
+		{name: "unknown order", orderId: uuid.NewV4(), lineItems: []models.OrderLineItem{{Item: &batman, Quantity: 1}},
+			wantErr: errors.NotFoundError},
+		{name: "item not on the order", orderId: order.Id, lineItems: []models.OrderLineItem{{Item: &joker, Quantity: 1}},
+			wantErr: errors.ReturnError},
+		{name: "more than bought", orderId: order.Id, lineItems: []models.OrderLineItem{{Item: &batman, Quantity: 2},
+			{Item: &batman, Quantity: 2}}, wantErr: errors.ReturnError},
+		{name: "zero quantity", orderId: order.Id, lineItems: []models.OrderLineItem{{Item: &robin, Quantity: 0}},
+			wantErr: errors.ReturnError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.Return(tt.orderId, &tt.lineItems)
+			e, ok := err.(errors.ApplicationError)
+			if !ok || e.ErrorType != errors.ErrorMap[tt.wantErr].ErrorType {
+				t.Errorf("InventoryUsecaseRepository.Return() error = %v, want code %d", err, errors.ErrorMap[tt.wantErr].ErrorType)
+			}
+		})
+	}
+
+	// one Batman back: a third of what the line cost, with the same discounts
+	first, err := repo.Return(order.Id, &[]models.OrderLineItem{{Item: &batman, Quantity: 1}})
+	if err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Return() error = %v", err)
+	}
+	line := first.Breakdown[0]
+	if !uuid.Equal(first.OriginalOrderId, order.Id) || line.Quantity != 1 || len(line.Discounts) != 2 {
+		t.Errorf("InventoryUsecaseRepository.Return() = %+v, want one Batman refunded with item and user discounts", first)
+	}
+	wantNet := order.Breakdown[0].Net.Div(decimal.New(3, 0)).Round(centPlaces)
+	if !first.NetAmount.Equal(wantNet) {
+		t.Errorf("InventoryUsecaseRepository.Return() net = %s, want %s", first.NetAmount, wantNet)
+	}
+	if got := repo.InventorySummary(models.DefaultLocationId, time.Now().UTC())[batman.Id]; !got.Equal(decimal.New(8, 0)) {
+		t.Errorf("InventoryUsecaseRepository.InventorySummary() Batman = %s, want 8 after returning one", got)
+	}
+
+	if _, err := repo.Return(order.Id, &[]models.OrderLineItem{{Item: &batman, Quantity: 3}}); err == nil {
+		t.Errorf("InventoryUsecaseRepository.Return() of 3 more Batmen succeeded, only 2 are left")
+	}
+
+	// the rest back: refunds add up to exactly what was paid
+	rest, err := repo.Return(order.Id, &[]models.OrderLineItem{{Item: &batman, Quantity: 2}, {Item: &robin, Quantity: 2}})
+	if err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Return() error = %v", err)
+	}
+	if refunds := first.TotalAmount.Add(rest.TotalAmount); !refunds.Equal(order.TotalAmount) {
+		t.Errorf("InventoryUsecaseRepository.Return() refunds = %s, want the %s paid", refunds, order.TotalAmount)
+	}
+	if tax := first.TaxAmount.Add(rest.TaxAmount); !tax.Equal(order.TaxAmount) {
+		t.Errorf("InventoryUsecaseRepository.Return() refunded tax = %s, want the %s paid", tax, order.TaxAmount)
+	}
+
+	// returns are reported apart from the sale
+	items, totals := repo.SaleSummary(from)
+	if !totals.Net.Equal(order.NetAmount) || !totals.Refunds.Equal(order.TotalAmount) ||
+		!totals.RefundedTax.Equal(order.TaxAmount) {
+		t.Errorf("InventoryUsecaseRepository.SaleSummary() = net %s refunds %s refunded tax %s, want %s, %s, %s",
+			totals.Net, totals.Refunds, totals.RefundedTax, order.NetAmount, order.TotalAmount, order.TaxAmount)
+	}
+	if !items[batman.Id].Equal(decimal.New(3, 0)) || !totals.Returned[batman.Id].Equal(decimal.New(3, 0)) {
+		t.Errorf("InventoryUsecaseRepository.SaleSummary() Batman sold %s returned %s, want 3 and 3", items[batman.Id],
+			totals.Returned[batman.Id])
+	}
+}
@@ -0,0 +1,138 @@
+package usecases
+
+import (
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"time"
+)
+
+// SalesLine adds up what was sold of one item, SKU or product group, or to one customer
+type SalesLine struct {
+	// Name of the item, SKU or product group as it was when sold, empty for customers
+	Name  string
+	Units decimal.Decimal
+	Gross decimal.Decimal
+	// Taken off gross by discounts and rounding, so Gross - Discounts is Net
+	Discounts decimal.Decimal
+	// Revenue without tax
+	Net decimal.Decimal
+}
+
+func (l SalesLine) add(line models.PriceBreakdown) SalesLine {
+	l.Units = l.Units.Add(decimal.New(line.Quantity, 0))
+	l.Gross = l.Gross.Add(line.Gross)
+	l.Discounts = l.Discounts.Add(line.Gross.Sub(line.Net))
+	l.Net = l.Net.Add(line.Net)
+	return l
+}
+
+// SalesReport breaks the purchases of a period down by item, SKU, product group and customer. Returns and
+// replenishments are kept apart from them by order type.
+type SalesReport struct {
+	From          time.Time
+	Till          time.Time
+	Items         map[uuid.UUID]SalesLine
+	SKUs          map[uuid.UUID]SalesLine
+	ProductGroups map[uuid.UUID]SalesLine
+	Customers     map[uuid.UUID]SalesLine
+	// All purchases, and the tax collected on them
+	Total SalesLine
+	Tax   decimal.Decimal
+	// Returned per item, refunded at what it was sold for, and the tax refunded
+	Returns     map[uuid.UUID]SalesLine
+	Refunds     SalesLine
+	RefundedTax decimal.Decimal
+	// Stock received per item, only Name and Units are set
+	Replenished map[uuid.UUID]SalesLine
+	// What was taken per tender type, and what the purchases still owe
+	Tenders TenderReport
+}
+
+// SalesReport reports the orders made from from up to but not including till. Net figures come from the
+// price breakdowns of the orders, so they match the receipts.
+func (i *InventoryUsecaseRepository) SalesReport(from time.Time, till time.Time) SalesReport {
+	report := SalesReport{
+		From:          from,
+		Till:          till,
+		Items:         make(map[uuid.UUID]SalesLine),
+		SKUs:          make(map[uuid.UUID]SalesLine),
+		ProductGroups: make(map[uuid.UUID]SalesLine),
+		Customers:     make(map[uuid.UUID]SalesLine),
+		Total:         newSalesLine(""),
+		Tax:           decimal.Zero,
+		Returns:       make(map[uuid.UUID]SalesLine),
+		Refunds:       newSalesLine(""),
+		RefundedTax:   decimal.Zero,
+		Replenished:   make(map[uuid.UUID]SalesLine),
+	}
+	after, through := i.sequencesBetween(from, till)
+
+	// the breakdown has item ids only, entries tell which SKU and product group the item was in
+	items := make(map[uuid.UUID]*models.Item)
+	var orders []*models.Order
+	seen := make(map[uuid.UUID]bool)
+	for _, entry := range i.ledger.EntriesBetween(after, through) {
+		if entry.Status == models.AbortedLedgerEnryStatus {
+			continue
+		}
+		items[entry.Item.Id] = entry.Item
+		if entry.Order.Type == models.ReplenishmentOrderType {
+			line := salesLine(report.Replenished, entry.Item.Id, entry.Item.Name)
+			line.Units = line.Units.Add(entry.Credit)
+			report.Replenished[entry.Item.Id] = line
+		}
+		if !seen[entry.Order.Id] {
+			seen[entry.Order.Id] = true
+			orders = append(orders, entry.Order)
+		}
+	}
+
+	for _, order := range orders {
+		switch order.Type {
+		case models.PurchaseOrderType:
+			report.Tax = report.Tax.Add(order.TaxAmount)
+			for _, line := range order.Breakdown {
+				item := items[line.ItemId]
+				report.Items[line.ItemId] = salesLine(report.Items, line.ItemId, item.Name).add(line)
+				if !uuid.Equal(item.SkuId, uuid.Nil) {
+					report.SKUs[item.SkuId] = salesLine(report.SKUs, item.SkuId, item.SKU.Name).add(line)
+				}
+				if !uuid.Equal(item.ProductGroupId, uuid.Nil) {
+					report.ProductGroups[item.ProductGroupId] = salesLine(report.ProductGroups, item.ProductGroupId,
+						item.ProductGroup.Name).add(line)
+				}
+				report.Customers[order.UserId] = salesLine(report.Customers, order.UserId, "").add(line)
+				report.Total = report.Total.add(line)
+			}
+		case models.ReturnOrderType:
+			report.RefundedTax = report.RefundedTax.Add(order.TaxAmount)
+			for _, line := range order.Breakdown {
+				report.Returns[line.ItemId] = salesLine(report.Returns, line.ItemId, items[line.ItemId].Name).add(line)
+				report.Refunds = report.Refunds.add(line)
+			}
+		}
+	}
+	report.Tenders = i.TenderReport(from, till)
+	return report
+}
+
+// SalesReportFor reports the business day, week or month at falls in
+func (i *InventoryUsecaseRepository) SalesReportFor(period ReportPeriod, at time.Time) (SalesReport, error) {
+	from, till, err := i.Calendar.Period(period, at)
+	if err != nil {
+		return SalesReport{}, err
+	}
+	return i.SalesReport(from, till), nil
+}
+
+func newSalesLine(name string) SalesLine {
+	return SalesLine{Name: name, Units: decimal.Zero, Gross: decimal.Zero, Discounts: decimal.Zero, Net: decimal.Zero}
+}
+
+func salesLine(lines map[uuid.UUID]SalesLine, id uuid.UUID, name string) SalesLine {
+	if line, ok := lines[id]; ok {
+		return line
+	}
+	return newSalesLine(name)
+}
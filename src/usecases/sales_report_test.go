@@ -0,0 +1,100 @@
+package usecases
+
+import (
+	"clock"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestInventoryUsecaseRepository_SalesReport(t *testing.T) {
+	start := time.Date(2017, 6, 6, 9, 0, 0, 0, time.UTC)
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.NewFake(start))
+
+	heroes := models.ProductGroup{ProductGroupId: uuid.NewV4(), Name: "Heroes"}
+	capes := models.SKU{SkuId: uuid.NewV4(), Name: "Capes"}
+	newItem := func(name, price string, discount int, sku models.SKU) models.Item {
+		return models.Item{Name: name, Price: mustDecimal(price), DiscountPercentage: discount, SKU: sku,
+			ProductGroup: heroes, BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	}
+	batman := newItem("Batman", "10", 10, capes)
+	superman := newItem("Superman", "20", 0, capes)
+	robin := newItem("Robin", "5", 0, models.SKU{})
+	joker := newItem("Joker", "7", 0, models.SKU{})
+	for _, item := range []models.Item{batman, superman, robin, joker} {
+		repo.Replenish(models.DefaultLocationId, item, decimal.New(10, 0))
+	}
+
+	alice, bob := uuid.NewV4(), uuid.NewV4()
+	buy := func(user uuid.UUID, lineItems ...models.OrderLineItem) models.Order {
+		order, err := repo.PurchaseOrder(models.DefaultLocationId, &lineItems, user, 0)
+		if err != nil {
+			t.Fatalf("InventoryUsecaseRepository.PurchaseOrder() error = %v", err)
+		}
+		return order
+	}
+	first := buy(alice, models.OrderLineItem{Item: &batman, Quantity: 2}, models.OrderLineItem{Item: &robin, Quantity: 1})
+	buy(bob, models.OrderLineItem{Item: &superman, Quantity: 1}, models.OrderLineItem{Item: &batman, Quantity: 1})
+	if _, err := repo.Pay(first.Id, models.Tender{Type: models.CardTenderType, Tendered: first.TotalAmount}); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Pay() error = %v", err)
+	}
+	if _, err := repo.Return(first.Id, &[]models.OrderLineItem{{Item: &robin, Quantity: 1}}); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Return() error = %v", err)
+	}
+
+	report := repo.SalesReport(start, start.Add(time.Nanosecond))
+	want := func(what string, line SalesLine, units int64, gross, discounts, net string) {
+		if !line.Units.Equal(decimal.New(units, 0)) || !line.Gross.Equal(mustDecimal(gross)) ||
+			!line.Discounts.Equal(mustDecimal(discounts)) || !line.Net.Equal(mustDecimal(net)) {
+			t.Errorf("InventoryUsecaseRepository.SalesReport() %s = %s units %s - %s = %s, want %d units %s - %s = %s",
+				what, line.Units, line.Gross, line.Discounts, line.Net, units, gross, discounts, net)
+		}
+	}
+	want("Batman", report.Items[batman.Id], 3, "30", "3", "27")
+	want("Capes", report.SKUs[capes.SkuId], 4, "50", "3", "47")
+	want("Heroes", report.ProductGroups[heroes.ProductGroupId], 5, "55", "3", "52")
+	want("Alice", report.Customers[alice], 3, "25", "2", "23")
+	want("Bob", report.Customers[bob], 2, "30", "1", "29")
+	want("total", report.Total, 5, "55", "3", "52")
+	want("Robin returned", report.Returns[robin.Id], 1, "5", "0", "5")
+	if report.Items[batman.Id].Name != "Batman" || report.SKUs[capes.SkuId].Name != "Capes" {
+		t.Errorf("InventoryUsecaseRepository.SalesReport() names = %q, %q", report.Items[batman.Id].Name,
+			report.SKUs[capes.SkuId].Name)
+	}
+
+	// stock that came in isn't a sale
+	if _, ok := report.Items[joker.Id]; ok {
+		t.Errorf("InventoryUsecaseRepository.SalesReport() reports the replenished Joker as sold")
+	}
+	if got := report.Replenished[joker.Id]; got.Name != "Joker" || !got.Units.Equal(decimal.New(10, 0)) {
+		t.Errorf("InventoryUsecaseRepository.SalesReport() Joker replenished = %+v, want 10", got)
+	}
+	if summary, _ := repo.SaleSummary(start.Add(-time.Second)); len(summary) != 3 {
+		t.Errorf("InventoryUsecaseRepository.SaleSummary() = %v, want the 3 items sold only", summary)
+	}
+
+	movements := repo.StockMovements(start, start.Add(time.Nanosecond))
+	for _, want := range []struct {
+		orderType models.OrderType
+		item      models.Item
+		in, out   int64
+	}{
+		{models.ReplenishmentOrderType, joker, 10, 0},
+		{models.PurchaseOrderType, batman, 0, 3},
+		{models.ReturnOrderType, robin, 1, 0},
+	} {
+		got := movements[want.orderType][want.item.Id]
+		if !got.In.Equal(decimal.New(want.in, 0)) || !got.Out.Equal(decimal.New(want.out, 0)) {
+			t.Errorf("InventoryUsecaseRepository.StockMovements() %s %s = %+v, want in %d out %d", want.orderType,
+				want.item.Name, got, want.in, want.out)
+		}
+	}
+
+	if later := repo.SalesReport(start.Add(time.Nanosecond), start.Add(time.Hour)); len(later.Items) != 0 {
+		t.Errorf("InventoryUsecaseRepository.SalesReport() an hour later = %v, want no sales", later.Items)
+	}
+}
@@ -0,0 +1,208 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"stores"
+	"sync"
+)
+
+// StockTakeUsecaseRepository runs stock counts against the inventory. Counts are kept in a store, so a count
+// can be recorded over several sessions, and sales can go on while the count runs: every line remembers
+// the ledger balance at the moment its item was counted.
+type StockTakeUsecaseRepository struct {
+	inventory *InventoryUsecaseRepository
+	counts    stores.StockCountStore
+	// a count is read, changed and saved, one change at a time
+	mu sync.Mutex
+}
+
+// NewStockTakeUsecaseRepository creates the stock take usecases for an inventory
+func NewStockTakeUsecaseRepository(inventory *InventoryUsecaseRepository,
+	counts stores.StockCountStore) *StockTakeUsecaseRepository {
+	return &StockTakeUsecaseRepository{
+		inventory: inventory,
+		counts:    counts,
+	}
+}
+
+// StartCount opens a count of the stock at a location for an employee
+func (s *StockTakeUsecaseRepository) StartCount(locationId uuid.UUID, employeeId uuid.UUID) (models.StockCount,
+	error) {
+	if uuid.Equal(employeeId, uuid.Nil) {
+		return models.StockCount{}, errors.NewError(errors.StockTakeError, "Empty employee given")
+	}
+	if err := s.inventory.checkLocation(locationId); err != nil {
+		return models.StockCount{}, err
+	}
+	count := models.StockCount{
+		EmployeeId: employeeId,
+		LocationId: locationId,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  s.inventory.clock.Now(),
+			Modified: s.inventory.clock.Now(),
+			Status:   models.OpenStockCountStatus,
+		},
+	}
+	if err := s.counts.SaveCount(count); err != nil {
+		return models.StockCount{}, errors.NewError(errors.StockTakeError, err.Error())
+	}
+	return count, nil
+}
+
+// RecordCount sets how many of an item are on the shelves, replacing an earlier count of it. The item's ledger
+// balance is read at the same moment, holding the item's lock, so sales before the count are in the expected
+// quantity and sales after it are not.
+func (s *StockTakeUsecaseRepository) RecordCount(countId uuid.UUID, item models.Item,
+	counted decimal.Decimal) (models.StockCountLine, error) {
+	if counted.Sign() < 0 {
+		return models.StockCountLine{}, errors.NewError(errors.StockTakeError, "Counted "+item.Name+
+			" can't be negative")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count, err := s.openCount(countId)
+	if err != nil {
+		return models.StockCountLine{}, err
+	}
+
+	unlock := s.inventory.locks.lock(item.Id)
+	line := models.StockCountLine{
+		Item:      item,
+		Counted:   counted,
+		Sequence:  s.inventory.ledger.Sequence(),
+		Expected:  s.inventory.findItemBalanceInLedger(count.LocationId, item),
+		CountedAt: s.inventory.clock.Now(),
+	}
+	unlock()
+
+	replaced := false
+	for n := range count.Lines {
+		if uuid.Equal(count.Lines[n].Item.Id, item.Id) {
+			count.Lines[n] = line
+			replaced = true
+		}
+	}
+	if !replaced {
+		count.Lines = append(count.Lines, line)
+	}
+	count.Modified = s.inventory.clock.Now()
+	if err := s.counts.SaveCount(count); err != nil {
+		return models.StockCountLine{}, errors.NewError(errors.StockTakeError, err.Error())
+	}
+	return line, nil
+}
+
+// ApproveCount posts the variances of an open count as one count-correction adjustment order, all or nothing,
+// and closes the count. A count without variances closes without an order.
+func (s *StockTakeUsecaseRepository) ApproveCount(countId uuid.UUID, employeeId uuid.UUID) (models.StockCount,
+	error) {
+	if uuid.Equal(employeeId, uuid.Nil) {
+		return models.StockCount{}, errors.NewError(errors.StockTakeError, "Empty employee given")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count, err := s.openCount(countId)
+	if err != nil {
+		return models.StockCount{}, err
+	}
+
+	order := models.Order{
+		UserId:      employeeId,
+		NetAmount:   decimal.Zero,
+		GrossAmount: decimal.Zero,
+		Type:        models.AdjustmentOrderType,
+		Reason:      models.CountCorrectionAdjustmentReason,
+		LocationId:  count.LocationId,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  s.inventory.clock.Now(),
+			Modified: s.inventory.clock.Now(),
+			Status:   models.CompletedOrderStatus,
+		},
+	}
+
+	itemIds := make([]uuid.UUID, 0, len(count.Lines))
+	for _, line := range count.Lines {
+		itemIds = append(itemIds, line.Item.Id)
+	}
+	unlock := s.inventory.locks.lock(itemIds...)
+	defer unlock()
+
+	// variances apply to the balance now, so sales made since an item was counted stay posted
+	var entries []models.LedgerEntry
+	for _, line := range count.Lines {
+		if line.Variance().Sign() == 0 {
+			continue
+		}
+		entry, err := s.inventory.adjustmentEntry(&order, line.Item, line.Variance())
+		if err != nil {
+			return models.StockCount{}, errors.NewError(errors.StockTakeError, err.Error())
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) > 0 {
+		if err := s.inventory.ledger.Append(entries...); err != nil {
+			return models.StockCount{}, errors.NewError(errors.StockTakeError, err.Error())
+		}
+		count.AdjustmentOrderId = order.Id
+	}
+
+	count.ApprovedBy = employeeId
+	count.Status = models.ApprovedStockCountStatus
+	count.Modified = s.inventory.clock.Now()
+	if err := s.counts.SaveCount(count); err != nil {
+		return models.StockCount{}, errors.NewError(errors.StockTakeError, err.Error())
+	}
+	return count, nil
+}
+
+// CancelCount closes an open count without touching stock
+func (s *StockTakeUsecaseRepository) CancelCount(countId uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count, err := s.openCount(countId)
+	if err != nil {
+		return err
+	}
+	count.Status = models.CancelledStockCountStatus
+	count.Modified = s.inventory.clock.Now()
+	if err := s.counts.SaveCount(count); err != nil {
+		return errors.NewError(errors.StockTakeError, err.Error())
+	}
+	return nil
+}
+
+// Count looks a count up by id
+func (s *StockTakeUsecaseRepository) Count(countId uuid.UUID) (models.StockCount, error) {
+	count, ok := s.counts.Count(countId)
+	if !ok {
+		return models.StockCount{}, errors.NewError(errors.NotFoundError, "stock count "+countId.String())
+	}
+	return count, nil
+}
+
+// OpenCounts returns the counts still in progress, oldest first
+func (s *StockTakeUsecaseRepository) OpenCounts() []models.StockCount {
+	var open []models.StockCount
+	for _, count := range s.counts.Counts() {
+		if count.Status == models.OpenStockCountStatus {
+			open = append(open, count)
+		}
+	}
+	return open
+}
+
+func (s *StockTakeUsecaseRepository) openCount(countId uuid.UUID) (models.StockCount, error) {
+	count, err := s.Count(countId)
+	if err != nil {
+		return count, err
+	}
+	if count.Status != models.OpenStockCountStatus {
+		return count, errors.NewError(errors.StockTakeError, "count "+countId.String()+" is "+count.Status)
+	}
+	return count, nil
+}
@@ -0,0 +1,95 @@
+package usecases
+
+import (
+	"clock"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestStockTakeUsecaseRepository_Count(t *testing.T) {
+	now := clock.NewFake(time.Date(2017, 6, 6, 9, 0, 0, 0, time.UTC))
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), now)
+	stockTake := NewStockTakeUsecaseRepository(repo, stores.NewMemoryStockCountStore())
+	fM := new(models.Mocks)
+	fM.InitUsers()
+	anna, boris := fM.Employees[0].Id, fM.Employees[1].Id
+
+	newItem := func(name string) models.Item {
+		return models.Item{Name: name, Price: decimal.New(5, 0),
+			BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	}
+	lego, yoyo, kite := newItem("Lego"), newItem("Yoyo"), newItem("Kite")
+	repo.Replenish(models.DefaultLocationId, lego, decimal.New(10, 0))
+	repo.Replenish(models.DefaultLocationId, yoyo, decimal.New(5, 0))
+	repo.Replenish(models.DefaultLocationId, kite, decimal.New(3, 0))
+
+	count, err := stockTake.StartCount(models.DefaultLocationId, anna)
+	if err != nil {
+		t.Fatalf("StockTakeUsecaseRepository.StartCount() error = %v", err)
+	}
+	record := func(item models.Item, counted int64) models.StockCountLine {
+		now.Advance(time.Minute)
+		line, err := stockTake.RecordCount(count.Id, item, decimal.New(counted, 0))
+		if err != nil {
+			t.Fatalf("StockTakeUsecaseRepository.RecordCount() error = %v", err)
+		}
+		return line
+	}
+
+	// one Lego missing, then two sold while the count goes on
+	if line := record(lego, 9); !line.Variance().Equal(decimal.New(-1, 0)) {
+		t.Errorf("StockTakeUsecaseRepository.RecordCount() Lego variance = %s, want -1", line.Variance())
+	}
+	lineItems := []models.OrderLineItem{{Item: &lego, Quantity: 2}}
+	if _, err := repo.Purchase(models.DefaultLocationId, &lineItems, fM.GetMockedUser(0).Id, 0); err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Purchase() error = %v", err)
+	}
+	// a Yoyo too many, miscounted first
+	record(yoyo, 4)
+	record(yoyo, 6)
+	record(kite, 3)
+
+	if _, err := stockTake.RecordCount(count.Id, kite, decimal.New(-1, 0)); err == nil {
+		t.Errorf("StockTakeUsecaseRepository.RecordCount() of -1 succeeded")
+	}
+	if open := stockTake.OpenCounts(); len(open) != 1 || len(open[0].Lines) != 3 {
+		t.Errorf("StockTakeUsecaseRepository.OpenCounts() = %+v, want the count with 3 lines", open)
+	}
+
+	approved, err := stockTake.ApproveCount(count.Id, boris)
+	if err != nil {
+		t.Fatalf("StockTakeUsecaseRepository.ApproveCount() error = %v", err)
+	}
+	if approved.Status != models.ApprovedStockCountStatus || !uuid.Equal(approved.ApprovedBy, boris) ||
+		uuid.Equal(approved.AdjustmentOrderId, uuid.Nil) {
+		t.Errorf("StockTakeUsecaseRepository.ApproveCount() = %+v, want it approved by Boris with an order", approved)
+	}
+	for _, want := range []struct {
+		item  models.Item
+		stock int64
+	}{{lego, 7}, {yoyo, 6}, {kite, 3}} {
+		if got := repo.findItemBalanceInLedger(models.DefaultLocationId, want.item); !got.Equal(decimal.New(want.stock, 0)) {
+			t.Errorf("stock of %s after approval = %s, want %d", want.item.Name, got, want.stock)
+		}
+	}
+	movements := repo.StockMovements(count.Created, now.Now().Add(time.Second))
+	if len(movements[models.AdjustmentOrderType]) != 2 {
+		t.Errorf("InventoryUsecaseRepository.StockMovements() adjustments = %v, want Lego and Yoyo",
+			movements[models.AdjustmentOrderType])
+	}
+
+	if _, err := stockTake.ApproveCount(count.Id, boris); err == nil {
+		t.Errorf("StockTakeUsecaseRepository.ApproveCount() approved the count twice")
+	}
+	if _, err := stockTake.RecordCount(count.Id, kite, decimal.New(1, 0)); err == nil {
+		t.Errorf("StockTakeUsecaseRepository.RecordCount() on an approved count succeeded")
+	}
+	if err := stockTake.CancelCount(uuid.NewV4()); err == nil {
+		t.Errorf("StockTakeUsecaseRepository.CancelCount() of an unknown count succeeded")
+	}
+}
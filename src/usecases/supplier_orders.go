@@ -0,0 +1,308 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"stores"
+	"strings"
+	"sync"
+)
+
+// SupplierUsecaseRepository orders stock from suppliers. An order is drafted, sent and then received in one or
+// more deliveries, each delivery credits the inventory with what actually arrived.
+type SupplierUsecaseRepository struct {
+	inventory *InventoryUsecaseRepository
+	suppliers stores.SupplierStore
+	// an order or supplier is read, changed and saved, one change at a time
+	mu sync.Mutex
+}
+
+// NewSupplierUsecaseRepository creates the supplier usecases for an inventory
+func NewSupplierUsecaseRepository(inventory *InventoryUsecaseRepository,
+	suppliers stores.SupplierStore) *SupplierUsecaseRepository {
+	return &SupplierUsecaseRepository{
+		inventory: inventory,
+		suppliers: suppliers,
+	}
+}
+
+// AddSupplier adds a supplier, names must be unique
+func (s *SupplierUsecaseRepository) AddSupplier(name string, contact string) (models.Supplier, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.Supplier{}, errors.NewError(errors.SupplierError, "Supplier needs a name")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.FindSupplier(name); err == nil {
+		return models.Supplier{}, errors.NewError(errors.SupplierError, "there already is a supplier "+name)
+	}
+	supplier := models.Supplier{
+		Name:    name,
+		Contact: contact,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  s.inventory.clock.Now(),
+			Modified: s.inventory.clock.Now(),
+			Status:   models.EnabledSupplierStatus,
+		},
+	}
+	if err := s.suppliers.SaveSupplier(supplier); err != nil {
+		return models.Supplier{}, errors.NewError(errors.SupplierError, err.Error())
+	}
+	return supplier, nil
+}
+
+// Suppliers returns every supplier sorted by name
+func (s *SupplierUsecaseRepository) Suppliers() []models.Supplier {
+	return s.suppliers.Suppliers()
+}
+
+// FindSupplier looks a supplier up by id or, ignoring case, by name
+func (s *SupplierUsecaseRepository) FindSupplier(ref string) (models.Supplier, error) {
+	if supplier, ok := s.suppliers.Supplier(uuid.FromStringOrNil(ref)); ok {
+		return supplier, nil
+	}
+	for _, supplier := range s.suppliers.Suppliers() {
+		if strings.EqualFold(supplier.Name, strings.TrimSpace(ref)) {
+			return supplier, nil
+		}
+	}
+	return models.Supplier{}, errors.NewError(errors.NotFoundError, "supplier "+ref)
+}
+
+// DraftOrder drafts an order to a supplier for delivery to a location, lines of the same item are added up
+func (s *SupplierUsecaseRepository) DraftOrder(supplierId uuid.UUID, locationId uuid.UUID, employeeId uuid.UUID,
+	lineItems []models.OrderLineItem) (models.SupplierOrder, error) {
+	if uuid.Equal(employeeId, uuid.Nil) || len(lineItems) == 0 {
+		return models.SupplierOrder{}, errors.NewError(errors.SupplierError, "Empty line items/employee given")
+	}
+	if _, ok := s.suppliers.Supplier(supplierId); !ok {
+		return models.SupplierOrder{}, errors.NewError(errors.NotFoundError, "supplier "+supplierId.String())
+	}
+	if err := s.inventory.checkLocation(locationId); err != nil {
+		return models.SupplierOrder{}, err
+	}
+
+	order := models.SupplierOrder{
+		SupplierId: supplierId,
+		EmployeeId: employeeId,
+		LocationId: locationId,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  s.inventory.clock.Now(),
+			Modified: s.inventory.clock.Now(),
+			Status:   models.DraftSupplierOrderStatus,
+		},
+	}
+	for _, line := range lineItems {
+		if line.Quantity <= 0 {
+			return models.SupplierOrder{}, errors.NewError(errors.SupplierError, "Quantity of "+line.Item.Name+
+				" must be positive")
+		}
+		if line.Item.Status == models.RetiredItemStatus {
+			return models.SupplierOrder{}, errors.NewError(errors.SupplierError, line.Item.Name+" is retired")
+		}
+		quantity := decimal.New(line.Quantity, 0)
+		if n := orderLine(order, line.Item.Id); n >= 0 {
+			order.Lines[n].Ordered = order.Lines[n].Ordered.Add(quantity)
+			continue
+		}
+		order.Lines = append(order.Lines, models.SupplierOrderLine{Item: *line.Item, Ordered: quantity,
+			Received: decimal.Zero})
+	}
+
+	if err := s.suppliers.SaveOrder(order); err != nil {
+		return models.SupplierOrder{}, errors.NewError(errors.SupplierError, err.Error())
+	}
+	return order, nil
+}
+
+// SendOrder marks a draft as sent to the supplier, only sent orders can be received
+func (s *SupplierUsecaseRepository) SendOrder(orderId uuid.UUID) (models.SupplierOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, err := s.orderIn(orderId, models.DraftSupplierOrderStatus)
+	if err != nil {
+		return order, err
+	}
+	order.Status = models.SentSupplierOrderStatus
+	if err := s.save(&order); err != nil {
+		return models.SupplierOrder{}, err
+	}
+	return order, nil
+}
+
+// ReceiveOrder books a delivery for a sent order: the quantities received are credited to the inventory on one
+// replenishment order, all or nothing, and the order is received once nothing is outstanding. A delivery can't
+// bring more of an item than is outstanding or items that weren't ordered. The order is saved with the delivery
+// before the stock is credited, so a delivery that couldn't be saved can't be received twice.
+func (s *SupplierUsecaseRepository) ReceiveOrder(orderId uuid.UUID, lineItems []models.OrderLineItem,
+	employeeId uuid.UUID) (models.SupplierOrder, error) {
+	if uuid.Equal(employeeId, uuid.Nil) || len(lineItems) == 0 {
+		return models.SupplierOrder{}, errors.NewError(errors.SupplierError, "Empty line items/employee given")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, err := s.orderIn(orderId, models.SentSupplierOrderStatus, models.PartiallyReceivedSupplierOrderStatus)
+	if err != nil {
+		return order, err
+	}
+
+	received := make(map[uuid.UUID]decimal.Decimal)
+	itemIds := make([]uuid.UUID, 0, len(lineItems))
+	for _, line := range lineItems {
+		n := orderLine(order, line.Item.Id)
+		if n < 0 {
+			return order, errors.NewError(errors.SupplierError, line.Item.Name+" is not on order "+orderId.String())
+		}
+		if line.Quantity <= 0 {
+			return order, errors.NewError(errors.SupplierError, "Quantity of "+line.Item.Name+" must be positive")
+		}
+		if _, ok := received[line.Item.Id]; !ok {
+			itemIds = append(itemIds, line.Item.Id)
+		}
+		received[line.Item.Id] = received[line.Item.Id].Add(decimal.New(line.Quantity, 0))
+		if outstanding := order.Lines[n].Outstanding(); received[line.Item.Id].Cmp(outstanding) > 0 {
+			return order, errors.NewError(errors.SupplierError, "only "+outstanding.String()+" of "+
+				line.Item.Name+" outstanding")
+		}
+	}
+
+	receipt := models.Order{
+		UserId:          employeeId,
+		NetAmount:       decimal.Zero,
+		GrossAmount:     decimal.Zero,
+		Type:            models.ReplenishmentOrderType,
+		SupplierOrderId: order.Id,
+		LocationId:      order.LocationId,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  s.inventory.clock.Now(),
+			Modified: s.inventory.clock.Now(),
+			Status:   models.CompletedOrderStatus,
+		},
+	}
+
+	before := order
+	updated := order
+	updated.Lines = append([]models.SupplierOrderLine(nil), order.Lines...)
+	updated.Status = models.ReceivedSupplierOrderStatus
+	for n := range updated.Lines {
+		updated.Lines[n].Received = updated.Lines[n].Received.Add(received[updated.Lines[n].Item.Id])
+		if updated.Lines[n].Outstanding().Sign() > 0 {
+			updated.Status = models.PartiallyReceivedSupplierOrderStatus
+		}
+	}
+	updated.ReceiptOrderIds = append(append([]uuid.UUID(nil), order.ReceiptOrderIds...), receipt.Id)
+	if err := s.save(&updated); err != nil {
+		return order, err
+	}
+
+	unlock := s.inventory.locks.lock(itemIds...)
+	entries := make([]models.LedgerEntry, 0, len(itemIds))
+	for _, id := range itemIds {
+		entries = append(entries, s.inventory.replenishmentEntry(&receipt, order.Lines[orderLine(order, id)].Item,
+			received[id]))
+	}
+	err = s.inventory.ledger.Append(entries...)
+	unlock()
+	if err != nil {
+		// nothing was credited, the delivery is still outstanding
+		if rollbackErr := s.suppliers.SaveOrder(before); rollbackErr != nil {
+			return order, errors.NewError(errors.SupplierError, err.Error()+", and the order still counts the "+
+				"delivery as received: "+rollbackErr.Error())
+		}
+		return order, errors.NewError(errors.SupplierError, err.Error())
+	}
+	return updated, nil
+}
+
+// CancelOrder closes an order that isn't fully received, what was received stays in stock
+func (s *SupplierUsecaseRepository) CancelOrder(orderId uuid.UUID) (models.SupplierOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, err := s.orderIn(orderId, models.DraftSupplierOrderStatus, models.SentSupplierOrderStatus,
+		models.PartiallyReceivedSupplierOrderStatus)
+	if err != nil {
+		return order, err
+	}
+	order.Status = models.CancelledSupplierOrderStatus
+	if err := s.save(&order); err != nil {
+		return models.SupplierOrder{}, err
+	}
+	return order, nil
+}
+
+// Order looks an order up by id
+func (s *SupplierUsecaseRepository) Order(orderId uuid.UUID) (models.SupplierOrder, error) {
+	order, ok := s.suppliers.Order(orderId)
+	if !ok {
+		return models.SupplierOrder{}, errors.NewError(errors.NotFoundError, "supplier order "+orderId.String())
+	}
+	return order, nil
+}
+
+// Orders returns every order, oldest first
+func (s *SupplierUsecaseRepository) Orders() []models.SupplierOrder {
+	return s.suppliers.Orders()
+}
+
+// OutstandingLine is stock a supplier still has to deliver on an order
+type OutstandingLine struct {
+	OrderId uuid.UUID
+	models.SupplierOrderLine
+}
+
+// Outstanding lists what every supplier still has to deliver on sent and partially received orders, by supplier
+// id and oldest order first. Drafts aren't with the supplier yet and cancelled orders won't be delivered.
+func (s *SupplierUsecaseRepository) Outstanding() map[uuid.UUID][]OutstandingLine {
+	outstanding := make(map[uuid.UUID][]OutstandingLine)
+	for _, order := range s.suppliers.Orders() {
+		if order.Status != models.SentSupplierOrderStatus &&
+			order.Status != models.PartiallyReceivedSupplierOrderStatus {
+			continue
+		}
+		for _, line := range order.Lines {
+			if line.Outstanding().Sign() > 0 {
+				outstanding[order.SupplierId] = append(outstanding[order.SupplierId],
+					OutstandingLine{OrderId: order.Id, SupplierOrderLine: line})
+			}
+		}
+	}
+	return outstanding
+}
+
+// the order with the given id, if it is in one of the given statuses
+func (s *SupplierUsecaseRepository) orderIn(orderId uuid.UUID, statuses ...string) (models.SupplierOrder, error) {
+	order, err := s.Order(orderId)
+	if err != nil {
+		return order, err
+	}
+	for _, status := range statuses {
+		if order.Status == status {
+			return order, nil
+		}
+	}
+	return order, errors.NewError(errors.SupplierError, "order "+orderId.String()+" is "+order.Status)
+}
+
+func (s *SupplierUsecaseRepository) save(order *models.SupplierOrder) error {
+	order.Modified = s.inventory.clock.Now()
+	if err := s.suppliers.SaveOrder(*order); err != nil {
+		return errors.NewError(errors.SupplierError, err.Error())
+	}
+	return nil
+}
+
+// index of the order's line for an item, -1 when it has none
+func orderLine(order models.SupplierOrder, itemId uuid.UUID) int {
+	for n, line := range order.Lines {
+		if uuid.Equal(line.Item.Id, itemId) {
+			return n
+		}
+	}
+	return -1
+}
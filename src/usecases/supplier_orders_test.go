@@ -0,0 +1,201 @@
+package usecases
+
+import (
+	"clock"
+	"error"
+	"fmt"
+	"models"
+	"stores"
+	"testing"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestSupplierUsecaseRepository_ReceiveOrder(t *testing.T) {
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	suppliers := NewSupplierUsecaseRepository(repo, stores.NewMemorySupplierStore())
+	anna := uuid.NewV4()
+
+	newItem := func(name string) models.Item {
+		return models.Item{Name: name, Price: decimal.New(5, 0),
+			BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	}
+	lego := newItem("Lego")
+	yoyo := newItem("Yoyo")
+	kite := newItem("Kite")
+
+	toys, err := suppliers.AddSupplier("Toys Ltd", "orders@toys.example")
+	if err != nil {
+		t.Fatalf("SupplierUsecaseRepository.AddSupplier() error = %v", err)
+	}
+	if _, err := suppliers.AddSupplier("toys ltd", ""); err == nil {
+		t.Errorf("SupplierUsecaseRepository.AddSupplier() took a supplier twice")
+	}
+	order, err := suppliers.DraftOrder(toys.Id, models.DefaultLocationId, anna, []models.OrderLineItem{
+		{Item: &lego, Quantity: 6}, {Item: &yoyo, Quantity: 4}, {Item: &lego, Quantity: 4}})
+	if err != nil {
+		t.Fatalf("SupplierUsecaseRepository.DraftOrder() error = %v", err)
+	}
+
+	wantError := func(what string, err error, errorType int) {
+		e, ok := err.(errors.ApplicationError)
+		if !ok || e.ErrorType != errors.ErrorMap[errorType].ErrorType {
+			t.Errorf("SupplierUsecaseRepository %s error = %v, want %s", what, err, errors.ErrorMap[errorType].Message)
+		}
+	}
+	_, err = suppliers.ReceiveOrder(order.Id, []models.OrderLineItem{{Item: &lego, Quantity: 1}}, anna)
+	wantError("receiving a draft", err, errors.SupplierError)
+	if _, err := suppliers.SendOrder(order.Id); err != nil {
+		t.Fatalf("SupplierUsecaseRepository.SendOrder() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		lines      []models.OrderLineItem
+		wantStatus string
+		wantStock  map[string]int64
+	}{
+		{name: "more than outstanding", lines: []models.OrderLineItem{{Item: &yoyo, Quantity: 2}, {Item: &lego, Quantity: 11}}},
+		{name: "not ordered", lines: []models.OrderLineItem{{Item: &kite, Quantity: 1}}},
+		{name: "first delivery", lines: []models.OrderLineItem{{Item: &lego, Quantity: 7}, {Item: &yoyo, Quantity: 4}},
+			wantStatus: models.PartiallyReceivedSupplierOrderStatus, wantStock: map[string]int64{"Lego": 7, "Yoyo": 4}},
+		{name: "yoyos done", lines: []models.OrderLineItem{{Item: &yoyo, Quantity: 1}}},
+		{name: "rest of the lego", lines: []models.OrderLineItem{{Item: &lego, Quantity: 3}},
+			wantStatus: models.ReceivedSupplierOrderStatus, wantStock: map[string]int64{"Lego": 10, "Yoyo": 4}},
+		{name: "after the last delivery", lines: []models.OrderLineItem{{Item: &lego, Quantity: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := suppliers.ReceiveOrder(order.Id, tt.lines, anna)
+			if tt.wantStatus == "" {
+				wantError("ReceiveOrder()", err, errors.SupplierError)
+				return
+			}
+			if err != nil {
+				t.Fatalf("SupplierUsecaseRepository.ReceiveOrder() error = %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("SupplierUsecaseRepository.ReceiveOrder() status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			for _, item := range []models.Item{lego, yoyo} {
+				stock := repo.findItemBalanceInLedger(models.DefaultLocationId, item)
+				if !stock.Equal(decimal.New(tt.wantStock[item.Name], 0)) {
+					t.Errorf("stock of %s = %s, want %d", item.Name, stock, tt.wantStock[item.Name])
+				}
+			}
+		})
+	}
+
+	// receipts are replenishments linked to the order
+	order, _ = suppliers.Order(order.Id)
+	if len(order.ReceiptOrderIds) != 2 {
+		t.Errorf("SupplierUsecaseRepository.Order() has %d receipts, want 2", len(order.ReceiptOrderIds))
+	}
+	for _, entry := range repo.ledger.EntriesBetween(0, repo.ledger.Sequence()) {
+		if entry.Order.Type != models.ReplenishmentOrderType || !uuid.Equal(entry.Order.SupplierOrderId, order.Id) {
+			t.Errorf("ledger entry for order %s is a %s, want a replenishment for the supplier order", entry.Order.Id,
+				entry.Order.Type)
+		}
+	}
+}
+
+func TestSupplierUsecaseRepository_Outstanding(t *testing.T) {
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	suppliers := NewSupplierUsecaseRepository(repo, stores.NewMemorySupplierStore())
+	anna := uuid.NewV4()
+	lego := models.Item{Name: "Lego", BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+
+	toys, _ := suppliers.AddSupplier("Toys Ltd", "")
+	bricks, _ := suppliers.AddSupplier("Bricks Inc", "")
+	draft := func(supplier models.Supplier, quantity int64) models.SupplierOrder {
+		order, err := suppliers.DraftOrder(supplier.Id, models.DefaultLocationId, anna,
+			[]models.OrderLineItem{{Item: &lego, Quantity: quantity}})
+		if err != nil {
+			t.Fatalf("SupplierUsecaseRepository.DraftOrder() error = %v", err)
+		}
+		return order
+	}
+	partial := draft(toys, 10)
+	suppliers.SendOrder(partial.Id)
+	suppliers.ReceiveOrder(partial.Id, []models.OrderLineItem{{Item: &lego, Quantity: 4}}, anna)
+	cancelled := draft(toys, 5)
+	suppliers.SendOrder(cancelled.Id)
+	if _, err := suppliers.CancelOrder(cancelled.Id); err != nil {
+		t.Fatalf("SupplierUsecaseRepository.CancelOrder() error = %v", err)
+	}
+	draft(bricks, 3)
+
+	outstanding := suppliers.Outstanding()
+	if len(outstanding) != 1 || len(outstanding[toys.Id]) != 1 {
+		t.Fatalf("SupplierUsecaseRepository.Outstanding() = %v, want the partly received order only", outstanding)
+	}
+	if line := outstanding[toys.Id][0]; !uuid.Equal(line.OrderId, partial.Id) ||
+		!line.Outstanding().Equal(decimal.New(6, 0)) {
+		t.Errorf("SupplierUsecaseRepository.Outstanding() = %s of order %s, want 6 of %s", line.Outstanding(),
+			line.OrderId, partial.Id)
+	}
+}
+
+// fails every append while fail is set
+type failingLedgerStore struct {
+	stores.LedgerStore
+	fail bool
+}
+
+func (s *failingLedgerStore) Append(entries ...models.LedgerEntry) error {
+	if s.fail {
+		return fmt.Errorf("disk full")
+	}
+	return s.LedgerStore.Append(entries...)
+}
+
+// fails every order saved while fail is set
+type failingSupplierStore struct {
+	stores.SupplierStore
+	fail bool
+}
+
+func (s *failingSupplierStore) SaveOrder(order models.SupplierOrder) error {
+	if s.fail {
+		return fmt.Errorf("disk full")
+	}
+	return s.SupplierStore.SaveOrder(order)
+}
+
+func TestSupplierUsecaseRepository_ReceiveOrderFailing(t *testing.T) {
+	ledger := &failingLedgerStore{LedgerStore: stores.NewMemoryLedgerStore()}
+	orders := &failingSupplierStore{SupplierStore: stores.NewMemorySupplierStore()}
+	repo := NewInventoryUsecaseRepository(ledger, clock.System)
+	suppliers := NewSupplierUsecaseRepository(repo, orders)
+	anna := uuid.NewV4()
+	lego := models.Item{Name: "Lego", Price: decimal.New(5, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	toys, _ := suppliers.AddSupplier("Toys Ltd", "")
+	order, _ := suppliers.DraftOrder(toys.Id, models.DefaultLocationId, anna, []models.OrderLineItem{{Item: &lego,
+		Quantity: 5}})
+	suppliers.SendOrder(order.Id)
+	delivery := []models.OrderLineItem{{Item: &lego, Quantity: 5}}
+
+	// neither a delivery that couldn't be saved nor one that couldn't be credited counts
+	for _, failing := range []*bool{&orders.fail, &ledger.fail} {
+		*failing = true
+		if _, err := suppliers.ReceiveOrder(order.Id, delivery, anna); err == nil {
+			t.Errorf("SupplierUsecaseRepository.ReceiveOrder() with a failing store succeeded")
+		}
+		*failing = false
+		if got, _ := suppliers.Order(order.Id); got.Status != models.SentSupplierOrderStatus ||
+			got.Lines[0].Received.Sign() != 0 || len(got.ReceiptOrderIds) != 0 {
+			t.Errorf("SupplierUsecaseRepository.Order() after a failed delivery = %+v, want nothing received", got)
+		}
+	}
+	if _, err := suppliers.ReceiveOrder(order.Id, delivery, anna); err != nil {
+		t.Fatalf("SupplierUsecaseRepository.ReceiveOrder() error = %v", err)
+	}
+	if _, err := suppliers.ReceiveOrder(order.Id, delivery, anna); err == nil {
+		t.Errorf("SupplierUsecaseRepository.ReceiveOrder() took the delivery twice")
+	}
+	if stock := repo.findItemBalanceInLedger(models.DefaultLocationId, lego); !stock.Equal(decimal.New(5, 0)) {
+		t.Errorf("stock of Lego = %s, want 5", stock)
+	}
+}
@@ -0,0 +1,118 @@
+package usecases
+
+import (
+	"error"
+	"github.com/shopspring/decimal"
+	"models"
+	"strings"
+)
+
+// TaxRounding says where tax is rounded to cents
+type TaxRounding string
+
+const (
+	// Round the tax of every line, the order pays the sum of rounded line taxes
+	PerLineTaxRounding TaxRounding = "line"
+	// Keep line taxes exact and round only the tax of the whole order
+	PerInvoiceTaxRounding TaxRounding = "invoice"
+)
+
+// TaxClasses an item or SKU can be in
+var TaxClasses = []string{models.StandardTaxClass, models.ReducedTaxClass, models.ExemptTaxClass}
+
+var hundred = decimal.New(100, 0)
+
+// TaxCalculator taxes priced order lines by the tax class of their item.
+// The zero value taxes nothing and rounds per line.
+type TaxCalculator struct {
+	// Percentage per tax class, classes without a rate aren't taxed
+	Rates map[string]decimal.Decimal
+	// Prices already include tax, tax is taken out of them instead of added on top
+	Inclusive bool
+	Rounding  TaxRounding
+}
+
+// NewTaxCalculator checks the rates and rounding and creates a calculator
+func NewTaxCalculator(rates map[string]decimal.Decimal, inclusive bool, rounding TaxRounding) (TaxCalculator, error) {
+	switch rounding {
+	case PerLineTaxRounding, PerInvoiceTaxRounding:
+	default:
+		return TaxCalculator{}, errors.NewError(errors.InvalidInputError, "unknown tax rounding "+string(rounding))
+	}
+	for class, rate := range rates {
+		if err := checkTaxClass(class); err != nil {
+			return TaxCalculator{}, err
+		}
+		if rate.Sign() < 0 {
+			return TaxCalculator{}, errors.NewError(errors.InvalidInputError, "tax rate of "+class+" can't be negative")
+		}
+	}
+	return TaxCalculator{Rates: rates, Inclusive: inclusive, Rounding: rounding}, nil
+}
+
+// ParseTaxRates reads rates written as class=percentage pairs separated by commas, eg. standard=20,reduced=5
+func ParseTaxRates(value string) (map[string]decimal.Decimal, error) {
+	rates := make(map[string]decimal.Decimal)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		sep := strings.Index(pair, "=")
+		if sep < 0 {
+			return nil, errors.NewError(errors.InvalidInputError, "tax rate "+pair+" is not <class>=<percentage>")
+		}
+		rate, err := decimal.NewFromString(strings.TrimSpace(pair[sep+1:]))
+		if err != nil {
+			return nil, errors.NewError(errors.InvalidInputError, "tax rate "+pair+" - "+err.Error())
+		}
+		rates[strings.TrimSpace(pair[:sep])] = rate
+	}
+	return rates, nil
+}
+
+// Apply sets the tax class, rate and tax of every line and gives the order's net amount without tax
+// and its tax. What the customer pays is the two added up.
+func (c TaxCalculator) Apply(breakdowns []models.PriceBreakdown) (decimal.Decimal, decimal.Decimal) {
+	priced := decimal.Zero
+	tax := decimal.Zero
+	for n := range breakdowns {
+		line := &breakdowns[n]
+		line.TaxRate = c.Rates[line.TaxClass]
+		if c.Inclusive {
+			line.Tax = line.Net.Mul(line.TaxRate).Div(line.TaxRate.Add(hundred))
+		} else {
+			line.Tax = line.Net.Mul(line.TaxRate).Div(hundred)
+		}
+		if c.Rounding != PerInvoiceTaxRounding {
+			line.Tax = line.Tax.Round(centPlaces)
+		}
+		priced = priced.Add(line.Net)
+		tax = tax.Add(line.Tax)
+	}
+
+	tax = tax.Round(centPlaces)
+	if c.Inclusive {
+		return priced.Sub(tax), tax
+	}
+	return priced, tax
+}
+
+// the tax class an item is sold in
+func itemTaxClass(item *models.Item) string {
+	if item.TaxClass != "" {
+		return item.TaxClass
+	}
+	if item.SKU.TaxClass != "" {
+		return item.SKU.TaxClass
+	}
+	return models.StandardTaxClass
+}
+
+func checkTaxClass(class string) error {
+	for _, known := range TaxClasses {
+		if class == known {
+			return nil
+		}
+	}
+	return errors.NewError(errors.InvalidInputError, "unknown tax class "+class)
+}
@@ -0,0 +1,119 @@
+package usecases
+
+import (
+	"clock"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestTaxCalculator_Apply(t *testing.T) {
+	rates := map[string]decimal.Decimal{models.StandardTaxClass: decimal.New(20, 0), models.ReducedTaxClass: decimal.New(5, 0)}
+	// two reduced lines whose tax ends on half a cent, so rounding per line and per invoice differ
+	lines := func() []models.PriceBreakdown {
+		return []models.PriceBreakdown{
+			{Net: mustDecimal("30"), TaxClass: models.StandardTaxClass},
+			{Net: mustDecimal("10.10"), TaxClass: models.ReducedTaxClass},
+			{Net: mustDecimal("10.10"), TaxClass: models.ReducedTaxClass},
+			{Net: mustDecimal("5"), TaxClass: models.ExemptTaxClass},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		calculator TaxCalculator
+		net, tax   string
+		lineTax    string
+	}{
+		{name: "exclusive per line", calculator: TaxCalculator{Rates: rates, Rounding: PerLineTaxRounding},
+			net: "55.2", tax: "7.02", lineTax: "0.51"},
+		{name: "exclusive per invoice", calculator: TaxCalculator{Rates: rates, Rounding: PerInvoiceTaxRounding},
+			net: "55.2", tax: "7.01", lineTax: "0.505"},
+		{name: "inclusive per line", calculator: TaxCalculator{Rates: rates, Inclusive: true, Rounding: PerLineTaxRounding},
+			net: "49.24", tax: "5.96", lineTax: "0.48"},
+		{name: "zero value", net: "55.2", tax: "0", lineTax: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breakdowns := lines()
+			net, tax := tt.calculator.Apply(breakdowns)
+			if !net.Equal(mustDecimal(tt.net)) || !tax.Equal(mustDecimal(tt.tax)) {
+				t.Errorf("TaxCalculator.Apply() = %s, %s, want %s, %s", net, tax, tt.net, tt.tax)
+			}
+			if !breakdowns[1].Tax.Equal(mustDecimal(tt.lineTax)) {
+				t.Errorf("TaxCalculator.Apply() reduced line tax = %s, want %s", breakdowns[1].Tax, tt.lineTax)
+			}
+			if breakdowns[3].Tax.Sign() != 0 {
+				t.Errorf("TaxCalculator.Apply() exempt line tax = %s, want 0", breakdowns[3].Tax)
+			}
+		})
+	}
+}
+
+func TestNewTaxCalculator(t *testing.T) {
+	rates, err := ParseTaxRates("standard=20, reduced=5.5")
+	if err != nil || !rates[models.ReducedTaxClass].Equal(mustDecimal("5.5")) {
+		t.Fatalf("ParseTaxRates() = %v, %v", rates, err)
+	}
+	if _, err := NewTaxCalculator(rates, false, PerInvoiceTaxRounding); err != nil {
+		t.Errorf("NewTaxCalculator() error = %v", err)
+	}
+
+	for _, value := range []string{"standard", "standard=lots"} {
+		if _, err := ParseTaxRates(value); err == nil {
+			t.Errorf("ParseTaxRates(%q) succeeded", value)
+		}
+	}
+	bad := []struct {
+		name     string
+		rates    map[string]decimal.Decimal
+		rounding TaxRounding
+	}{
+		{name: "unknown class", rates: map[string]decimal.Decimal{"luxury": decimal.New(30, 0)}, rounding: PerLineTaxRounding},
+		{name: "negative rate", rates: map[string]decimal.Decimal{models.StandardTaxClass: decimal.New(-1, 0)}, rounding: PerLineTaxRounding},
+		{name: "unknown rounding", rounding: "order"},
+	}
+	for _, tt := range bad {
+		if _, err := NewTaxCalculator(tt.rates, false, tt.rounding); err == nil {
+			t.Errorf("NewTaxCalculator() with %s succeeded", tt.name)
+		}
+	}
+}
+
+func TestInventoryUsecaseRepository_PurchaseTax(t *testing.T) {
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.System)
+	repo.TaxCalculator = TaxCalculator{Rates: map[string]decimal.Decimal{models.StandardTaxClass: decimal.New(20, 0),
+		models.ReducedTaxClass: decimal.New(5, 0)}, Rounding: PerLineTaxRounding}
+	fM := new(models.Mocks)
+	fM.InitUsers()
+
+	// the item's own class wins over its SKU's
+	toy := models.Item{Name: "Lego", Price: decimal.New(10, 0), SKU: models.SKU{TaxClass: models.ReducedTaxClass},
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	book := models.Item{Name: "Comic", Price: decimal.New(4, 0), TaxClass: models.ExemptTaxClass,
+		SKU:        models.SKU{TaxClass: models.ReducedTaxClass},
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	repo.Replenish(models.DefaultLocationId, toy, decimal.New(5, 0))
+	repo.Replenish(models.DefaultLocationId, book, decimal.New(5, 0))
+
+	from := time.Now().UTC().Add(-time.Second)
+	lineItems := []models.OrderLineItem{{Item: &toy, Quantity: 2}, {Item: &book, Quantity: 1}}
+	total, err := repo.Purchase(models.DefaultLocationId, &lineItems, fM.GetMockedUser(0).Id, 0)
+	if err != nil {
+		t.Fatalf("InventoryUsecaseRepository.Purchase() error = %v", err)
+	}
+	// 20 at 5% reduced plus 4 exempt
+	if !total.Equal(mustDecimal("25")) {
+		t.Errorf("InventoryUsecaseRepository.Purchase() = %s, want 25", total)
+	}
+
+	_, totals := repo.SaleSummary(from)
+	if !totals.Net.Equal(mustDecimal("24")) || !totals.Tax.Equal(mustDecimal("1")) || !totals.Total.Equal(mustDecimal("25")) {
+		t.Errorf("InventoryUsecaseRepository.SaleSummary() = net %s tax %s total %s, want 24, 1, 25", totals.Net,
+			totals.Tax, totals.Total)
+	}
+}
@@ -0,0 +1,281 @@
+package usecases
+
+import (
+	"error"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"models"
+	"stores"
+	"sync"
+)
+
+// TransferUsecaseRepository moves stock between locations. Shipping a transfer debits the source at once, the
+// stock is in transit until the destination receives it and what arrived is credited there.
+type TransferUsecaseRepository struct {
+	inventory *InventoryUsecaseRepository
+	transfers stores.TransferStore
+	// a transfer is read, changed and saved, one change at a time
+	mu sync.Mutex
+}
+
+// NewTransferUsecaseRepository creates the transfer usecases for an inventory
+func NewTransferUsecaseRepository(inventory *InventoryUsecaseRepository,
+	transfers stores.TransferStore) *TransferUsecaseRepository {
+	return &TransferUsecaseRepository{
+		inventory: inventory,
+		transfers: transfers,
+	}
+}
+
+// Ship sends stock from one location to another: the lines are debited from the source on one transfer order,
+// all or nothing, and stay in transit until received. Lines of the same item are added up. Stock reserved for
+// carts can't be shipped.
+func (t *TransferUsecaseRepository) Ship(fromLocationId uuid.UUID, toLocationId uuid.UUID, employeeId uuid.UUID,
+	lineItems []models.OrderLineItem) (models.Transfer, error) {
+	if uuid.Equal(employeeId, uuid.Nil) || len(lineItems) == 0 {
+		return models.Transfer{}, errors.NewError(errors.TransferError, "Empty line items/employee given")
+	}
+	if uuid.Equal(fromLocationId, toLocationId) {
+		return models.Transfer{}, errors.NewError(errors.TransferError, "stock can't be sent where it is")
+	}
+	for _, locationId := range []uuid.UUID{fromLocationId, toLocationId} {
+		if err := t.inventory.checkLocation(locationId); err != nil {
+			return models.Transfer{}, err
+		}
+	}
+
+	transfer := models.Transfer{
+		FromLocationId: fromLocationId,
+		ToLocationId:   toLocationId,
+		ShippedBy:      employeeId,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  t.inventory.clock.Now(),
+			Modified: t.inventory.clock.Now(),
+			Status:   models.InTransitTransferStatus,
+		},
+	}
+	itemIds := make([]uuid.UUID, 0, len(lineItems))
+	for _, line := range lineItems {
+		if line.Quantity <= 0 {
+			return models.Transfer{}, errors.NewError(errors.TransferError, "Quantity of "+line.Item.Name+
+				" must be positive")
+		}
+		quantity := decimal.New(line.Quantity, 0)
+		if n := transferLine(transfer, line.Item.Id); n >= 0 {
+			transfer.Lines[n].Shipped = transfer.Lines[n].Shipped.Add(quantity)
+			continue
+		}
+		transfer.Lines = append(transfer.Lines, models.TransferLine{Item: *line.Item, Shipped: quantity,
+			Received: decimal.Zero})
+		itemIds = append(itemIds, line.Item.Id)
+	}
+
+	shipment := t.transferOrder(transfer, employeeId, fromLocationId)
+	transfer.ShipmentOrderId = shipment.Id
+	unlock := t.inventory.locks.lock(itemIds...)
+	defer unlock()
+	// stock reserved for carts stays where it is
+	reserved := t.inventory.reserved(fromLocationId, uuid.Nil)
+	entries := make([]models.LedgerEntry, 0, len(transfer.Lines))
+	for _, line := range transfer.Lines {
+		balance := t.inventory.findItemBalanceInLedger(fromLocationId, line.Item).Sub(line.Shipped)
+		if balance.Cmp(reserved[line.Item.Id]) < 0 {
+			return models.Transfer{}, errors.NewError(errors.TransferError, "only "+balance.Add(line.Shipped).
+				Sub(reserved[line.Item.Id]).String()+" of "+line.Item.Name+" available, "+
+				reserved[line.Item.Id].String()+" is reserved for carts")
+		}
+		entries = append(entries, t.entry(&shipment, line.Item, line.Shipped.Neg(), balance))
+	}
+
+	// the transfer is saved before the source is debited, so no stock leaves without a transfer on record
+	if err := t.transfers.SaveTransfer(transfer); err != nil {
+		return models.Transfer{}, errors.NewError(errors.TransferError, err.Error())
+	}
+	if err := t.inventory.ledger.Append(entries...); err != nil {
+		// nothing was shipped
+		if rollbackErr := t.transfers.DeleteTransfer(transfer.Id); rollbackErr != nil {
+			return models.Transfer{}, errors.NewError(errors.TransferError, err.Error()+", and the transfer "+
+				"is left in transit: "+rollbackErr.Error())
+		}
+		return models.Transfer{}, errors.NewError(errors.TransferError, err.Error())
+	}
+	return transfer, nil
+}
+
+// Receive books the arrival of a transfer at its destination. The lines are what arrived, items shipped but left
+// out didn't arrive at all. Everything shipped is credited to the destination on one transfer order and every
+// difference with what arrived is posted on a transfer-discrepancy adjustment, all or nothing.
+func (t *TransferUsecaseRepository) Receive(transferId uuid.UUID, lineItems []models.OrderLineItem,
+	employeeId uuid.UUID) (models.Transfer, error) {
+	if uuid.Equal(employeeId, uuid.Nil) {
+		return models.Transfer{}, errors.NewError(errors.TransferError, "Empty employee given")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	transfer, err := t.Transfer(transferId)
+	if err != nil {
+		return transfer, err
+	}
+	if transfer.Status != models.InTransitTransferStatus {
+		return transfer, errors.NewError(errors.TransferError, "transfer "+transferId.String()+" is "+
+			transfer.Status)
+	}
+
+	before := transfer
+	transfer.Lines = append([]models.TransferLine(nil), transfer.Lines...)
+	for _, line := range lineItems {
+		n := transferLine(transfer, line.Item.Id)
+		if n < 0 {
+			return transfer, errors.NewError(errors.TransferError, line.Item.Name+" wasn't shipped on transfer "+
+				transferId.String())
+		}
+		if line.Quantity < 0 {
+			return transfer, errors.NewError(errors.TransferError, "Quantity of "+line.Item.Name+
+				" can't be negative")
+		}
+		transfer.Lines[n].Received = transfer.Lines[n].Received.Add(decimal.New(line.Quantity, 0))
+	}
+
+	receipt := t.transferOrder(transfer, employeeId, transfer.ToLocationId)
+	adjustment := t.transferOrder(transfer, employeeId, transfer.ToLocationId)
+	adjustment.Type = models.AdjustmentOrderType
+	adjustment.Reason = models.TransferDiscrepancyAdjustmentReason
+
+	itemIds := make([]uuid.UUID, 0, len(transfer.Lines))
+	for _, line := range transfer.Lines {
+		itemIds = append(itemIds, line.Item.Id)
+	}
+	transfer.Status = models.ReceivedTransferStatus
+	transfer.ReceivedBy = employeeId
+	transfer.ReceiptOrderId = receipt.Id
+	for _, line := range transfer.Lines {
+		if line.Discrepancy().Sign() != 0 {
+			transfer.AdjustmentOrderId = adjustment.Id
+		}
+	}
+	transfer.Modified = t.inventory.clock.Now()
+	// the transfer is saved as received before the destination is credited, so a transfer that couldn't be
+	// saved can't be received twice
+	if err := t.transfers.SaveTransfer(transfer); err != nil {
+		return before, errors.NewError(errors.TransferError, err.Error())
+	}
+
+	unlock := t.inventory.locks.lock(itemIds...)
+	var entries, discrepancies []models.LedgerEntry
+	for _, line := range transfer.Lines {
+		balance := t.inventory.findItemBalanceInLedger(transfer.ToLocationId, line.Item).Add(line.Shipped)
+		entries = append(entries, t.entry(&receipt, line.Item, line.Shipped, balance))
+		if line.Discrepancy().Sign() != 0 {
+			discrepancies = append(discrepancies, t.entry(&adjustment, line.Item, line.Discrepancy(),
+				balance.Add(line.Discrepancy())))
+		}
+	}
+	err = t.inventory.ledger.Append(append(entries, discrepancies...)...)
+	unlock()
+	if err != nil {
+		// nothing was credited, the stock is still in transit
+		if rollbackErr := t.transfers.SaveTransfer(before); rollbackErr != nil {
+			return before, errors.NewError(errors.TransferError, err.Error()+", and the transfer still counts "+
+				"as received: "+rollbackErr.Error())
+		}
+		return before, errors.NewError(errors.TransferError, err.Error())
+	}
+	return transfer, nil
+}
+
+// Transfer looks a transfer up by id
+func (t *TransferUsecaseRepository) Transfer(transferId uuid.UUID) (models.Transfer, error) {
+	transfer, ok := t.transfers.Transfer(transferId)
+	if !ok {
+		return models.Transfer{}, errors.NewError(errors.NotFoundError, "transfer "+transferId.String())
+	}
+	return transfer, nil
+}
+
+// Transfers returns every transfer, oldest first
+func (t *TransferUsecaseRepository) Transfers() []models.Transfer {
+	return t.transfers.Transfers()
+}
+
+// Route is the way stock travels between two locations
+type Route struct {
+	FromLocationId uuid.UUID
+	ToLocationId   uuid.UUID
+}
+
+// InTransit adds up the stock shipped on transfers that haven't been received yet, by route and item. It is
+// stock neither location has, so it is left out of their inventory.
+func (t *TransferUsecaseRepository) InTransit() map[Route]map[uuid.UUID]decimal.Decimal {
+	inTransit := make(map[Route]map[uuid.UUID]decimal.Decimal)
+	for _, transfer := range t.transfers.Transfers() {
+		if transfer.Status != models.InTransitTransferStatus {
+			continue
+		}
+		route := Route{FromLocationId: transfer.FromLocationId, ToLocationId: transfer.ToLocationId}
+		items, ok := inTransit[route]
+		if !ok {
+			items = make(map[uuid.UUID]decimal.Decimal)
+			inTransit[route] = items
+		}
+		for _, line := range transfer.Lines {
+			items[line.Item.Id] = items[line.Item.Id].Add(line.Shipped)
+		}
+	}
+	return inTransit
+}
+
+// a transfer order moving the transfer's stock in or out at a location
+func (t *TransferUsecaseRepository) transferOrder(transfer models.Transfer, employeeId uuid.UUID,
+	locationId uuid.UUID) models.Order {
+	return models.Order{
+		UserId:      employeeId,
+		NetAmount:   decimal.Zero,
+		GrossAmount: decimal.Zero,
+		Type:        models.TransferOrderType,
+		TransferId:  transfer.Id,
+		LocationId:  locationId,
+		BaseFields: models.BaseFields{
+			Id:       uuid.NewV4(),
+			Created:  t.inventory.clock.Now(),
+			Modified: t.inventory.clock.Now(),
+			Status:   models.CompletedOrderStatus,
+		},
+	}
+}
+
+// The entry moving quantity of item in or out at the order's location, leaving balance there. Several entries
+// for an item go into one append, so callers work the balance out and hold the item's lock.
+func (t *TransferUsecaseRepository) entry(order *models.Order, item models.Item, quantity decimal.Decimal,
+	balance decimal.Decimal) models.LedgerEntry {
+	entry := models.LedgerEntry{
+		Order:      order,
+		Item:       &item,
+		LocationId: order.LocationId,
+		Credit:     decimal.Zero,
+		Debit:      decimal.Zero,
+		Balance:    balance,
+		BaseFields: models.BaseFields{
+			Id:       uuid.UUID{},
+			Created:  t.inventory.clock.Now(),
+			Modified: t.inventory.clock.Now(),
+			Status:   models.CreatedLedgerEntryStatus,
+		},
+	}
+	if quantity.Sign() > 0 {
+		entry.Credit = quantity
+	} else {
+		entry.Debit = quantity.Neg()
+	}
+	return entry
+}
+
+// index of the transfer's line for an item, -1 when it has none
+func transferLine(transfer models.Transfer, itemId uuid.UUID) int {
+	for n, line := range transfer.Lines {
+		if uuid.Equal(line.Item.Id, itemId) {
+			return n
+		}
+	}
+	return -1
+}
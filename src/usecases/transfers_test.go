@@ -0,0 +1,172 @@
+package usecases
+
+import (
+	"clock"
+	"error"
+	"fmt"
+	"models"
+	"stores"
+	"testing"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestTransferUsecaseRepository_ShipAndReceive(t *testing.T) {
+	start := time.Date(2017, 6, 6, 9, 0, 0, 0, time.UTC)
+	repo := NewInventoryUsecaseRepository(stores.NewMemoryLedgerStore(), clock.NewFake(start))
+	transfers := NewTransferUsecaseRepository(repo, stores.NewMemoryTransferStore())
+	anna := uuid.NewV4()
+	warehouse, _ := repo.AddLocation("Warehouse", models.WarehouseLocationKind)
+	shop := models.DefaultLocationId
+
+	newItem := func(name string) models.Item {
+		return models.Item{Name: name, Price: decimal.New(5, 0),
+			BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	}
+	lego := newItem("Lego")
+	yoyo := newItem("Yoyo")
+	kite := newItem("Kite")
+	repo.Replenish(warehouse.Id, lego, decimal.New(10, 0))
+	repo.Replenish(warehouse.Id, yoyo, decimal.New(5, 0))
+
+	wantError := func(what string, err error, errorType int) {
+		e, ok := err.(errors.ApplicationError)
+		if !ok || e.ErrorType != errors.ErrorMap[errorType].ErrorType {
+			t.Errorf("TransferUsecaseRepository %s error = %v, want %s", what, err, errors.ErrorMap[errorType].Message)
+		}
+	}
+	_, err := transfers.Ship(warehouse.Id, warehouse.Id, anna, []models.OrderLineItem{{Item: &lego, Quantity: 1}})
+	wantError("Ship() to where the stock is", err, errors.TransferError)
+	_, err = transfers.Ship(warehouse.Id, uuid.NewV4(), anna, []models.OrderLineItem{{Item: &lego, Quantity: 1}})
+	wantError("Ship() to an unknown location", err, errors.NotFoundError)
+	_, err = transfers.Ship(warehouse.Id, shop, anna, []models.OrderLineItem{{Item: &lego, Quantity: 4},
+		{Item: &yoyo, Quantity: 6}})
+	wantError("Ship() of more than in stock", err, errors.TransferError)
+
+	transfer, err := transfers.Ship(warehouse.Id, shop, anna, []models.OrderLineItem{{Item: &lego, Quantity: 4},
+		{Item: &yoyo, Quantity: 3}, {Item: &lego, Quantity: 2}})
+	if err != nil {
+		t.Fatalf("TransferUsecaseRepository.Ship() error = %v", err)
+	}
+	stock := func(locationId uuid.UUID, item models.Item) int64 {
+		return repo.findItemBalanceInLedger(locationId, item).IntPart()
+	}
+	if stock(warehouse.Id, lego) != 4 || stock(warehouse.Id, yoyo) != 2 || stock(shop, lego) != 0 {
+		t.Errorf("stock after shipping = %d Lego, %d Yoyo at the warehouse and %d Lego at the shop, want 4, 2 and 0",
+			stock(warehouse.Id, lego), stock(warehouse.Id, yoyo), stock(shop, lego))
+	}
+	route := Route{FromLocationId: warehouse.Id, ToLocationId: shop}
+	inTransit := transfers.InTransit()
+	if len(inTransit) != 1 || !inTransit[route][lego.Id].Equal(decimal.New(6, 0)) ||
+		!inTransit[route][yoyo.Id].Equal(decimal.New(3, 0)) {
+		t.Errorf("TransferUsecaseRepository.InTransit() = %v, want 6 Lego and 3 Yoyo from the warehouse", inTransit)
+	}
+
+	_, err = transfers.Receive(transfer.Id, []models.OrderLineItem{{Item: &kite, Quantity: 1}}, anna)
+	wantError("Receive() of an item not shipped", err, errors.TransferError)
+	// a Lego went missing and there was one Yoyo too many
+	received, err := transfers.Receive(transfer.Id, []models.OrderLineItem{{Item: &lego, Quantity: 5},
+		{Item: &yoyo, Quantity: 4}}, anna)
+	if err != nil {
+		t.Fatalf("TransferUsecaseRepository.Receive() error = %v", err)
+	}
+	if received.Status != models.ReceivedTransferStatus || uuid.Equal(received.AdjustmentOrderId, uuid.Nil) {
+		t.Errorf("TransferUsecaseRepository.Receive() = %+v, want a received transfer with an adjustment", received)
+	}
+	if stock(shop, lego) != 5 || stock(shop, yoyo) != 4 {
+		t.Errorf("stock after receiving = %d Lego and %d Yoyo at the shop, want 5 and 4", stock(shop, lego),
+			stock(shop, yoyo))
+	}
+	if inTransit := transfers.InTransit(); len(inTransit) != 0 {
+		t.Errorf("TransferUsecaseRepository.InTransit() = %v after receiving, want nothing", inTransit)
+	}
+	_, err = transfers.Receive(transfer.Id, nil, anna)
+	wantError("Receive() twice", err, errors.TransferError)
+
+	// what went missing is shrinkage, the transfer orders even out
+	shrinkage := repo.ShrinkageReport(start, start.Add(time.Hour))
+	if line := shrinkage.Reasons[models.TransferDiscrepancyAdjustmentReason]; !line.Units.Equal(decimal.Zero) {
+		t.Errorf("ShrinkageReport() transfer discrepancies = %s units, want 0 (one lost, one found)", line.Units)
+	}
+	if line := shrinkage.Items[lego.Id]; !line.Units.Equal(decimal.New(1, 0)) {
+		t.Errorf("ShrinkageReport() Lego = %s units, want 1", line.Units)
+	}
+	movements := repo.StockMovements(start, start.Add(time.Hour))[models.TransferOrderType]
+	if movement := movements[lego.Id]; !movement.In.Equal(movement.Out) {
+		t.Errorf("StockMovements() Lego transferred in %s and out %s, want them even", movement.In, movement.Out)
+	}
+	_, err = repo.Adjust(shop, lego, decimal.New(-1, 0), models.TransferDiscrepancyAdjustmentReason, anna)
+	wantError("Adjust() for a transfer discrepancy", err, errors.AdjustmentError)
+}
+
+// fails every transfer saved while fail is set
+type failingTransferStore struct {
+	stores.TransferStore
+	fail bool
+}
+
+func (s *failingTransferStore) SaveTransfer(transfer models.Transfer) error {
+	if s.fail {
+		return fmt.Errorf("disk full")
+	}
+	return s.TransferStore.SaveTransfer(transfer)
+}
+
+func TestTransferUsecaseRepository_ShipAndReceiveFailing(t *testing.T) {
+	ledger := &failingLedgerStore{LedgerStore: stores.NewMemoryLedgerStore()}
+	store := &failingTransferStore{TransferStore: stores.NewMemoryTransferStore()}
+	repo := NewInventoryUsecaseRepository(ledger, clock.System)
+	transfers := NewTransferUsecaseRepository(repo, store)
+	anna := uuid.NewV4()
+	warehouse, _ := repo.AddLocation("Warehouse", models.WarehouseLocationKind)
+	shop := models.DefaultLocationId
+	lego := models.Item{Name: "Lego", Price: decimal.New(5, 0),
+		BaseFields: models.BaseFields{Id: uuid.NewV4(), Status: models.AvailableItemStatus}}
+	repo.Replenish(warehouse.Id, lego, decimal.New(10, 0))
+	lines := []models.OrderLineItem{{Item: &lego, Quantity: 4}}
+	stock := func(locationId uuid.UUID) decimal.Decimal {
+		return repo.findItemBalanceInLedger(locationId, lego)
+	}
+
+	// neither a shipment that couldn't be saved nor one that couldn't be debited leaves anything behind
+	for _, failing := range []*bool{&store.fail, &ledger.fail} {
+		*failing = true
+		if _, err := transfers.Ship(warehouse.Id, shop, anna, lines); err == nil {
+			t.Errorf("TransferUsecaseRepository.Ship() with a failing store succeeded")
+		}
+		*failing = false
+		if got := transfers.Transfers(); len(got) != 0 || !stock(warehouse.Id).Equal(decimal.New(10, 0)) {
+			t.Errorf("after a failed shipment %d transfers and %s in the warehouse, want none and 10", len(got),
+				stock(warehouse.Id))
+		}
+	}
+	transfer, err := transfers.Ship(warehouse.Id, shop, anna, lines)
+	if err != nil {
+		t.Fatalf("TransferUsecaseRepository.Ship() error = %v", err)
+	}
+
+	// neither does a receipt
+	for _, failing := range []*bool{&store.fail, &ledger.fail} {
+		*failing = true
+		if _, err := transfers.Receive(transfer.Id, lines, anna); err == nil {
+			t.Errorf("TransferUsecaseRepository.Receive() with a failing store succeeded")
+		}
+		*failing = false
+		if got, _ := transfers.Transfer(transfer.Id); got.Status != models.InTransitTransferStatus ||
+			got.Lines[0].Received.Sign() != 0 || stock(shop).Sign() != 0 {
+			t.Errorf("TransferUsecaseRepository.Transfer() after a failed receipt = %+v with %s in the shop, "+
+				"want it in transit", got, stock(shop))
+		}
+	}
+	if _, err := transfers.Receive(transfer.Id, lines, anna); err != nil {
+		t.Fatalf("TransferUsecaseRepository.Receive() error = %v", err)
+	}
+	if _, err := transfers.Receive(transfer.Id, lines, anna); err == nil {
+		t.Errorf("TransferUsecaseRepository.Receive() took the transfer twice")
+	}
+	if !stock(shop).Equal(decimal.New(4, 0)) {
+		t.Errorf("stock in the shop = %s, want 4", stock(shop))
+	}
+}